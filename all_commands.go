@@ -13,7 +13,7 @@ type cliCommand struct {
 }
 
 // getCommand returns a map of available CLI commands for the TCAS-simulator.
-func getCommand(cfg *config.Config, simState *aviation.SimulationState, argument2 string) map[string]cliCommand {
+func getCommand(cfg *config.Config, simState *aviation.SimulationState, gui *fyneGUI, argument2 string) map[string]cliCommand {
 	commands := map[string]cliCommand{
 		"exit": {
 			name:        "exit",
@@ -26,30 +26,83 @@ func getCommand(cfg *config.Config, simState *aviation.SimulationState, argument
 			name:        "help",
 			description: "Display usage of the application",
 			callback: func() {
-				helpFunc(cfg, simState, argument2)
+				helpFunc(cfg, simState, gui, argument2)
 			},
 		},
 		"run": {
 			name:        "run",
-			description: "Initializes and runs the simulation",
+			description: "Initializes and runs the simulation, use 'run --headless' to run it without a window",
 			callback: func() {
-				StartFyne(cfg, simState)
+				if argument2 == "--headless" {
+					runHeadless(cfg, simState)
+					return
+				}
+				StartFyne(cfg, simState, gui)
 			},
 		},
 		"get": {
 			name:        "get",
-			description: "prints details of the simulation such as airports, Planes and flights to the console",
+			description: "prints details of the simulation such as airports, Planes, flights, encounters, STCA alerts and predicted conflicts to the console",
 			callback: func() {
 				getDetails(simState, argument2)
 			},
 		},
 		"log": {
 			name:        "log",
-			description: "logs details of the simulation such as airports, Planes and flights to an appropriate file",
+			description: "logs details of the simulation such as airports, Planes, flights, encounters, STCA alerts and predicted conflicts to an appropriate file",
 			callback: func() {
 				logDetails(simState, argument2)
 			},
 		},
+		"speed": {
+			name:        "speed",
+			description: "Sets the simulation speed, e.g. 'speed 10', 'speed 100' or 'speed max'",
+			callback: func() {
+				setSimSpeed(cfg, simState, argument2)
+			},
+		},
+		"pause": {
+			name:        "pause",
+			description: "Freezes the running simulation",
+			callback: func() {
+				pauseSimulation(simState)
+			},
+		},
+		"resume": {
+			name:        "resume",
+			description: "Resumes a paused simulation",
+			callback: func() {
+				resumeSimulation(simState)
+			},
+		},
+		"step": {
+			name:        "step",
+			description: "Advances the simulation by a duration and pauses it, e.g. 'step 10s' or 'step 500ms'",
+			callback: func() {
+				stepSimulation(simState, argument2)
+			},
+		},
+		"save": {
+			name:        "save",
+			description: "Saves the whole state of the running simulation to a file, e.g. 'save logs/crossing.json'",
+			callback: func() {
+				saveSnapshot(simState, argument2)
+			},
+		},
+		"load": {
+			name:        "load",
+			description: "Loads a simulation saved with 'save' and carries it on, paused, e.g. 'load logs/crossing.json'",
+			callback: func() {
+				loadSnapshot(cfg, simState, gui, argument2)
+			},
+		},
+		"replay": {
+			name:        "replay",
+			description: "Plays back a recorded simulation, every run is recorded to logs/replay.jsonl, e.g. 'replay logs/replay.jsonl'",
+			callback: func() {
+				replaySimulation(cfg, simState, gui, argument2)
+			},
+		},
 		"q": {
 			name:        "q",
 			description: "Immediately halts the active simulation.",
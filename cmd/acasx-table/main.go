@@ -0,0 +1,197 @@
+// Command acasx-table builds the cost table of the ACAS X style collision avoidance logic of the simulator
+// (see aviation.TableLogic) and writes it to assets/acasx_costs.json, or the file given with -out.
+//
+// The real ACAS Xa table is optimised by dynamic programming over a probabilistic model of encounters. This one is
+// much simpler: in every state of the grid each advisory is flown until the closest approach, by a crew with the
+// standard pilot model of TCAS II, against an intruder whose vertical rate may change a little. Its cost is the
+// expected lack of vertical separation at the closest approach, plus the cost of alerting the crew. It is enough to
+// give the logic the behaviour of a table logic: advisories come from costs, not thresholds, and depend on the
+// advisory given before.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+)
+
+// Grids of the table, in feet, ft/min and seconds
+var (
+	relativeAltitudes = []float64{-2000, -1400, -1000, -700, -400, -200, 0, 200, 400, 700, 1000, 1400, 2000}
+	verticalRates     = []float64{-2500, -1500, 0, 1500, 2500}
+	taus              = []float64{0, 5, 10, 15, 20, 25, 30, 35, 40}
+)
+
+// Advisories, in the order of the table
+const (
+	coc = iota
+	climb
+	descend
+	advisoryCount
+)
+
+// Model of the encounters
+const (
+	horizontalMiss = 1.1  // nautical miles, an intruder predicted to pass further away is no threat
+	taMargin       = 0.3  // a TA is issued when an RA costs at most this much more than none
+	clearMargin    = 0.05 // an RA ends once clear of conflict costs at most this much
+	step           = 0.5  // seconds, the integration step of the vertical profiles
+	responseDelay  = 5.0  // seconds before a crew flies a new RA
+	reversalDelay  = 2.5  // seconds before a crew flies a reversed RA
+	acceleration   = 0.25 // g
+	raRate         = 1500 // ft/min
+	rateNoise      = 400  // ft/min, how much the intruder may change its vertical rate
+	nmac           = 100  // feet of vertical separation below which the planes are taken to collide
+	safeSeparation = 600  // feet of vertical separation from which the closest approach costs nothing
+	certainTau     = 30   // seconds, closest approaches further away than this are discounted
+)
+
+// Costs of alerting the crew
+const (
+	newAlertCost      = 0.1
+	keptAlertCost     = 0.01
+	reversalAlertCost = 0.5
+)
+
+func main() {
+	out := flag.String("out", aviation.DefaultCostTablePath, "path of the cost table to write")
+	flag.Parse()
+
+	table := aviation.CostTable{
+		Version:          aviation.CostTableVersion,
+		RelativeAltitude: relativeAltitudes,
+		OwnRate:          verticalRates,
+		IntruderRate:     verticalRates,
+		Tau:              taus,
+		HorizontalMiss:   horizontalMiss,
+		TAMargin:         taMargin,
+		ClearMargin:      clearMargin,
+	}
+	for _, h := range relativeAltitudes {
+		for _, ownRate := range verticalRates {
+			for _, intruderRate := range verticalRates {
+				for _, tau := range taus {
+					for previous := 0; previous < advisoryCount; previous++ {
+						for action := 0; action < advisoryCount; action++ {
+							table.Costs = append(table.Costs, round(cost(h, ownRate, intruderRate, tau, previous, action)))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(table)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d costs to %s\n", len(table.Costs), *out)
+}
+
+// cost returns the expected cost of an advisory in a state: h is the altitude of the intruder above the own plane.
+func cost(h, ownRate, intruderRate, tau float64, previous, action int) float64 {
+	climbed := ownDisplacement(ownRate, tau, previous, action)
+	intruderRates := []float64{intruderRate}
+	if action == coc && previous != coc {
+		// The intruder may have been in the RA as well and stop its manoeuvre, the worse of both is assumed
+		intruderRates = append(intruderRates, 0)
+	}
+	separationCost := 0.0
+	for _, rate := range intruderRates {
+		expected := 0.0
+		for _, noise := range []struct{ rate, weight float64 }{{-rateNoise, 0.25}, {0, 0.5}, {rateNoise, 0.25}} {
+			separation := math.Abs(h + (rate+noise.rate)*tau/60 - climbed)
+			expected += noise.weight * separationPenalty(separation)
+		}
+		separationCost = math.Max(separationCost, expected)
+	}
+	return separationCost*certainty(tau) + alertCost(previous, action)
+}
+
+// ownDisplacement returns how many feet the own plane climbs until the closest approach, tau seconds away.
+func ownDisplacement(rate, tau float64, previous, action int) float64 {
+	target, delay := rate, 0.0
+	switch {
+	case action == coc && previous != coc:
+		// Clear of conflict, the crew stops the manoeuvre
+		target = 0
+	case action == climb:
+		target = math.Max(rate, raRate)
+	case action == descend:
+		target = math.Min(rate, -raRate)
+	}
+	switch {
+	case action == coc || action == previous:
+	case previous == coc:
+		delay = responseDelay
+	default:
+		delay = reversalDelay
+	}
+
+	accel := acceleration * 32.174 * 60 // ft/min per second
+	displacement := 0.0
+	for t := 0.0; t < tau; t += step {
+		dt := math.Min(step, tau-t)
+		if t >= delay {
+			switch {
+			case rate < target:
+				rate = math.Min(target, rate+accel*dt)
+			case rate > target:
+				rate = math.Max(target, rate-accel*dt)
+			}
+		}
+		displacement += rate * dt / 60
+	}
+	return displacement
+}
+
+// separationPenalty returns the cost of passing the intruder with the given vertical separation, in feet.
+func separationPenalty(separation float64) float64 {
+	penalty := math.Max(0, (safeSeparation-separation)/safeSeparation)
+	if separation < nmac {
+		penalty += 10
+	}
+	return penalty
+}
+
+// certainty returns the weight of a closest approach tau seconds away, the further away the less the state says
+// about it.
+func certainty(tau float64) float64 {
+	last := taus[len(taus)-1]
+	if tau <= certainTau {
+		return 1
+	}
+	return math.Max(0, (last-tau)/(last-certainTau))
+}
+
+// alertCost returns the cost of giving the crew an advisory after the previous one.
+func alertCost(previous, action int) float64 {
+	switch {
+	case action == coc:
+		return 0
+	case previous == coc:
+		return newAlertCost
+	case previous == action:
+		return keptAlertCost
+	default:
+		return reversalAlertCost
+	}
+}
+
+// round keeps three significant figures, the table need not be more precise than its model.
+func round(x float64) float64 {
+	if x == 0 {
+		return 0
+	}
+	scale := math.Pow(10, 2-math.Floor(math.Log10(math.Abs(x))))
+	return math.Round(x*scale) / scale
+}
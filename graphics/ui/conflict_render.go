@@ -0,0 +1,119 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+)
+
+// conflictColor is the color of the conflicts predicted from the flight plans, apart from the STCA and TCAS colors
+var conflictColor = color.RGBA{R: 255, G: 255, A: 255}
+
+// ConflictRender is the overlay of a conflict predicted from the flight plans: a circle as wide as the horizontal
+// minimum where the planes come closest, the projected track of each plane to it, and a label naming them with the time
+// left before they lose separation.
+type ConflictRender struct {
+	Circle *canvas.Circle
+	Tracks [2]*canvas.Line
+	Label  *canvas.Text
+}
+
+// newConflictRender creates the hidden overlay of one predicted conflict.
+func newConflictRender() *ConflictRender {
+	circle := canvas.NewCircle(color.Transparent)
+	circle.StrokeColor = conflictColor
+	circle.StrokeWidth = 1
+	circle.Hidden = true
+
+	cr := &ConflictRender{Circle: circle, Label: canvas.NewText("", conflictColor)}
+	for i := range cr.Tracks {
+		cr.Tracks[i] = canvas.NewLine(conflictColor)
+		cr.Tracks[i].StrokeWidth = 1
+		cr.Tracks[i].Hidden = true
+	}
+	cr.Label.Hidden = true
+	return cr
+}
+
+// hide hides the whole overlay of the conflict.
+func (cr *ConflictRender) hide() {
+	cr.Circle.Hidden = true
+	cr.Tracks[0].Hidden = true
+	cr.Tracks[1].Hidden = true
+	cr.Label.Hidden = true
+}
+
+// objects returns the canvas objects of the overlay, in drawing order.
+func (cr *ConflictRender) objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{cr.Circle, cr.Tracks[0], cr.Tracks[1], cr.Label}
+}
+
+// ToggleConflicts shows or hides the overlay of the conflicts predicted from the flight plans.
+func (sa *SimulationArea) ToggleConflicts() {
+	sa.showConflicts = !sa.showConflicts
+	if !sa.showConflicts {
+		for _, cr := range sa.conflictRenders {
+			cr.hide()
+		}
+	}
+	sa.Refresh()
+}
+
+// predictConflicts updates the predicted conflicts shown by the overlay, and makes sure there is an overlay for
+// each of them. Conflicts are predicted from the live flight plans, so none is shown while reviewing an earlier time.
+func (sa *SimulationArea) predictConflicts() {
+	sa.conflicts = nil
+	if !sa.showConflicts || sa.Reviewing() || sa.simState == nil {
+		return
+	}
+	sa.conflicts = sa.simState.PredictConflicts()
+	for len(sa.conflictRenders) < len(sa.conflicts) {
+		sa.conflictRenders = append(sa.conflictRenders, newConflictRender())
+	}
+}
+
+// applyConflicts lays out the overlay of every predicted conflict and hides the unused ones.
+// The projected track of a plane is drawn from where it is now, planes not in the air yet have none.
+func (r *simulationAreaRenderer) applyConflicts(positions map[string]aviation.Coordinate, simTime time.Time, scale float32) {
+	sa := r.simulationArea
+	for i, cr := range sa.conflictRenders {
+		if i >= len(sa.conflicts) {
+			cr.hide()
+			continue
+		}
+		conflict := sa.conflicts[i]
+		displayX := (float32(conflict.Position.X) * scale) + sa.offsetX
+		displayY := (float32(conflict.Position.Y) * scale) + sa.offsetY
+
+		// The circle is as wide as the horizontal minimum, centred halfway between the planes at their closest approach
+		radius := float32(aviation.ConflictMinimumDistance()) / 2 * scale
+		cr.Circle.Move(fyne.NewPos(displayX-radius, displayY-radius))
+		cr.Circle.Resize(fyne.NewSize(radius*2, radius*2))
+		cr.Circle.Hidden = false
+
+		for j, serial := range conflict.Planes {
+			position, ok := positions[serial]
+			if !ok {
+				cr.Tracks[j].Hidden = true
+				continue
+			}
+			cr.Tracks[j].Position1 = fyne.NewPos((float32(position.X)*scale)+sa.offsetX, (float32(position.Y)*scale)+sa.offsetY)
+			cr.Tracks[j].Position2 = fyne.NewPos(displayX, displayY)
+			cr.Tracks[j].Hidden = false
+			cr.Tracks[j].Refresh()
+		}
+
+		cr.Label.Text = fmt.Sprintf("%s/%s %.1f min", conflict.Planes[0], conflict.Planes[1],
+			conflict.TimeToLoss(simTime).Minutes())
+		cr.Label.TextSize = 8 * scale
+		cr.Label.Resize(cr.Label.MinSize())
+		cr.Label.Move(fyne.NewPos(displayX-cr.Label.MinSize().Width/2, displayY+radius))
+		cr.Label.Hidden = false
+		cr.Label.Refresh()
+		cr.Circle.Refresh()
+	}
+}
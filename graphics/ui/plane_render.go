@@ -24,11 +24,32 @@ type PlaneRender struct {
 	ImageLocation  fyne.Position
 	FlightPathLine *canvas.Line
 	TCASCircle     *canvas.Circle
+	DataBlock      *canvas.Text // label of the plane, shown flashing while the ground STCA alerts about it
 }
 
 // AddPlaneToRender adds a new PlaneRender object to the simulation area.
 // This function will be called by the aviation package via the registered callback in simState.OnPlaneTakeoff.
 func (sa *SimulationArea) AddPlaneToRender(plane *aviation.Plane) {
+	// A plane may already be rendered when the window opened while it was taking off
+	for _, p := range sa.planesInFlight {
+		if p.ActualPlane == plane {
+			return
+		}
+	}
+
+	planeRender, err := sa.newPlaneRender(plane)
+	if err != nil {
+		log.Printf("Failed to rotate plane image: %v", err)
+		return // silently skip rendering this plane
+	}
+
+	sa.planesInFlight = append(sa.planesInFlight, planeRender)
+	// Refresh renderer to include new objects
+	sa.Refresh()
+}
+
+// newPlaneRender creates the image, flight path line and TCAS circle of a plane on its current flight.
+func (sa *SimulationArea) newPlaneRender(plane *aviation.Plane) (*PlaneRender, error) {
 	image := canvas.NewImageFromResource(sa.airplaneImage)
 	image.Hidden = true                      // Start hidden, will be shown when position is updated
 	image.SetMinSize(sa.initialAirplaneSize) // Set initial size
@@ -37,8 +58,7 @@ func (sa *SimulationArea) AddPlaneToRender(plane *aviation.Plane) {
 	rotation := planeOrientation(currentFlight.FlightSchedule.Depature, currentFlight.FlightSchedule.Destination)
 	rotatedImg, err := RotateCanvasImage(image, rotation)
 	if err != nil {
-		log.Printf("Failed to rotate plane image: %v", err)
-		return // silently skip rendering this plane
+		return nil, err
 	}
 	canvas.Refresh(rotatedImg)
 
@@ -61,14 +81,15 @@ func (sa *SimulationArea) AddPlaneToRender(plane *aviation.Plane) {
 		Image:          rotatedImg,
 		FlightPathLine: line,
 		TCASCircle:     canvas.NewCircle(color.Transparent),
+		DataBlock:      canvas.NewText("", stcaColor),
 	}
 
 	planeRender.TCASCircle.StrokeWidth = 3 // Set a default stroke width
 	planeRender.TCASCircle.Hidden = true   // Start hidden
+	planeRender.DataBlock.TextStyle.Bold = true
+	planeRender.DataBlock.Hidden = true
 
-	sa.planesInFlight = append(sa.planesInFlight, planeRender)
-	// Refresh renderer to include new objects
-	sa.Refresh()
+	return planeRender, nil
 }
 
 // RemovePlaneFromRender removes a PlaneRender object from the simulation area.
@@ -86,6 +107,7 @@ func (sa *SimulationArea) RemovePlaneFromRender(planeSerial string) {
 			if p.TCASCircle != nil { // Hide the circle if it exists
 				p.TCASCircle.Hide()
 			}
+			p.DataBlock.Hide()
 			break
 		}
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"image/color"
 	"log"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -45,11 +46,22 @@ type SimulationArea struct {
 	PlaneTakeOffCallback func(*aviation.Plane)
 	PlaneLandCallback    func(string) // Pass plane serial for removal
 
-	// Timer for updating plane positions
-	animationTicker          *time.Ticker
-	simState                 *aviation.SimulationState
-	planeCrash, crashTrigger bool
-	CrashedPlanes            []string
+	// Timer for updating plane positions, stopped by Stop when the window is closed
+	animationTicker *time.Ticker
+	done            chan struct{} // closed by Stop, ends the animation goroutine
+	stopOnce        sync.Once
+	simState        *aviation.SimulationState
+
+	// When review is set, the area shows the simulation at an earlier time chosen on the timeline scrubber
+	// instead of the live simulation
+	review       *aviation.TimelineState
+	reviewPlanes []*PlaneRender
+	reviewCache  map[string]*PlaneRender // review plane renders by flight ID, so their images are rotated only once
+
+	// When showConflicts is set, the conflicts predicted from the flight plans are drawn over the map
+	showConflicts   bool
+	conflicts       []aviation.PredictedConflict
+	conflictRenders []*ConflictRender
 }
 
 // Ensure SimulationArea implements the necessary interfaces for a widget,
@@ -85,9 +97,8 @@ func NewSimulationArea(simState *aviation.SimulationState, mainWindow fyne.Windo
 		mainWindow:          mainWindow,
 		planesInFlight:      []*PlaneRender{}, // Initialize empty slice
 		simState:            simState,
-		planeCrash:          false,
-		crashTrigger:        false,
-		CrashedPlanes:       []string{},
+		reviewCache:         map[string]*PlaneRender{},
+		done:                make(chan struct{}),
 	}
 	sa.statusLabel.Alignment = fyne.TextAlignCenter
 	sa.statusLabel.TextSize = 8
@@ -101,13 +112,24 @@ func NewSimulationArea(simState *aviation.SimulationState, mainWindow fyne.Windo
 	simState.OnPlaneTakeOffCallback = sa.AddPlaneToRender
 	simState.OnPlaneLandCallback = sa.RemovePlaneFromRender
 
-	// NEW: Start a ticker for continuous animation updates
+	// Planes already in flight, as in a simulation loaded from a snapshot, took off before the callbacks were registered
+	simState.Mu.Lock()
+	alreadyInFlight := append([]*aviation.Plane{}, simState.PlanesInFlight...)
+	simState.Mu.Unlock()
+	for _, plane := range alreadyInFlight {
+		sa.AddPlaneToRender(plane)
+	}
+
+	// NEW: Start a ticker for continuous animation updates.
+	// The simulation time itself is advanced by the aviation package, the ticker only redraws.
 	sa.animationTicker = time.NewTicker(50 * time.Millisecond) // Update 20 times per second
 	go func() {
-		for range sa.animationTicker.C {
-			simState.Mu.Lock()
-			simState.CurrentSimTime = time.Now()
-			simState.Mu.Unlock()
+		for {
+			select {
+			case <-sa.done:
+				return
+			case <-sa.animationTicker.C:
+			}
 			if sa.Size().IsZero() { // Don't refresh if widget hasn't been laid out yet
 				continue
 			}
@@ -121,6 +143,14 @@ func NewSimulationArea(simState *aviation.SimulationState, mainWindow fyne.Windo
 	return sa
 }
 
+// Stop stops the animation of the area, once its window is closed. It may be called more than once.
+func (sa *SimulationArea) Stop() {
+	sa.stopOnce.Do(func() {
+		sa.animationTicker.Stop()
+		close(sa.done)
+	})
+}
+
 // generateAirportsToRender creates the airport objects based on the input number.
 func (sa *SimulationArea) generateAirportsToRender(simState *aviation.SimulationState) {
 
@@ -228,8 +258,13 @@ func (sa *SimulationArea) ClearAllResource() {
 		if p.TCASCircle != nil {
 			p.TCASCircle.Hide()
 		}
+		p.DataBlock.Hide()
+	}
+	for _, cr := range sa.conflictRenders {
+		cr.hide()
 	}
 	sa.planesInFlight = []*PlaneRender{} // Reset the slice
+	sa.conflicts = nil
 	sa.airports = []*AirportRender{}
 
 	sa.Refresh()
@@ -3,7 +3,6 @@ package ui
 import (
 	"fmt"
 	"image/color"
-	"log"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -11,6 +10,12 @@ import (
 	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
 )
 
+// stcaColor is the color of the ground STCA alerts, apart from the orange, green and red of TCAS
+var stcaColor = color.RGBA{R: 255, B: 255, A: 255}
+
+// stcaFlashPeriod is how long the data block of a plane in an STCA alert is shown, then hidden, in turn
+const stcaFlashPeriod = 500 * time.Millisecond
+
 // simulationAreaRenderer implements fyne.WidgetRenderer for SimulationArea.
 type simulationAreaRenderer struct {
 	simulationArea *SimulationArea
@@ -23,8 +28,8 @@ func (r *simulationAreaRenderer) MinSize() fyne.Size {
 }
 
 // Layout positions and renders all simulation elements (
-// background, status, airports, planes, flight paths, and TCAS circles
-// ) within the given size, applying pan, zoom, and TCAS logic based on the current simulation state.
+// background, status, airports, planes, flight paths, TCAS circles and predicted conflicts
+// ) within the given size, applying pan and zoom, and showing the TCAS results of the current simulation state.
 func (r *simulationAreaRenderer) Layout(size fyne.Size) {
 	// Layout the background to fill the widget
 	r.background.Resize(size)
@@ -69,44 +74,42 @@ func (r *simulationAreaRenderer) Layout(size fyne.Size) {
 		return
 	}
 
-	// Use the simulation's current time for all calculations
-	simTime := r.simulationArea.simState.CurrentSimTime
-
-	// Pre-calculate plane positions and reset their current engagement state for this frame.
-	// This map helps avoid re-calculating positions multiple times and ensures all planes start clean.
-	planeStates := make(map[*aviation.Plane]struct {
-		Coord aviation.Coordinate
-		OK    bool
-	})
-
-	for _, pr := range r.simulationArea.planesInFlight {
-		coord, ok := planeCurrentPosition(pr.ActualPlane, simTime)
-		planeStates[pr.ActualPlane] = struct {
-			Coord aviation.Coordinate
-			OK    bool
-		}{Coord: coord, OK: ok}
-		// IMPORTANT: Reset the plane's CurrentTCASEngagement at the start of each frame.
-		// It will be set again below if an active interaction is found.
-		pr.ActualPlane.CurrentTCASEngagement = nil
+	// Use the simulation's current time for all calculations, or the reviewed time when scrubbing the timeline.
+	// TCAS detection runs in the aviation package, here we only read its results.
+	simState := r.simulationArea.simState
+	simState.Mu.Lock()
+	planes, simTime, crashedPlanes := r.simulationArea.shownPlanes()
+	crashed := make(map[string]bool)
+	for _, serial := range crashedPlanes {
+		crashed[serial] = true
+	}
+	engagements := make(map[*aviation.Plane]*aviation.TCASEngagement)
+	stcaAlerted := make(map[*aviation.Plane]bool)
+	for _, pr := range planes {
+		if pr.ActualPlane.CurrentTCASEngagement != nil {
+			engagement := *pr.ActualPlane.CurrentTCASEngagement
+			engagements[pr.ActualPlane] = &engagement
+		}
+		stcaAlerted[pr.ActualPlane] = len(pr.ActualPlane.STCAConflicts) > 0
 	}
+	simState.Mu.Unlock()
 
 	// Iterate through planes and apply rendering logic
-	for _, planeRender := range r.simulationArea.planesInFlight {
+	positions := make(map[string]aviation.Coordinate)
+	for _, planeRender := range planes {
 		plane := planeRender.ActualPlane
-		planeState := planeStates[plane]
+		planeCoord, ok := aviation.PlaneCurrentPosition(plane, simTime)
 
-		if !planeState.OK {
+		if !ok {
 			planeRender.Image.Hidden = true
 			planeRender.FlightPathLine.Hidden = true
 			if planeRender.TCASCircle != nil {
 				planeRender.TCASCircle.Hidden = true
 			}
-			// plane.CurrentTCASEngagement was already reset in the pre-calculation loop above.
+			planeRender.DataBlock.Hidden = true
 			continue
 		}
 
-		planeCoord := planeState.Coord
-
 		// Update plane image position and visibility
 		displayX := (float32(planeCoord.X) * scale) + r.simulationArea.offsetX
 		displayY := (float32(planeCoord.Y) * scale) + r.simulationArea.offsetY
@@ -114,6 +117,7 @@ func (r *simulationAreaRenderer) Layout(size fyne.Size) {
 		planeRender.Image.Resize(currentAirplaneDisplaySize)
 		planeRender.Image.Move(fyne.NewPos(displayX-currentAirplaneDisplaySize.Width/2, displayY-currentAirplaneDisplaySize.Height/2))
 		planeRender.Image.Hidden = false
+		positions[plane.Serial] = planeCoord
 
 		// Update flight path line
 		currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
@@ -124,69 +128,41 @@ func (r *simulationAreaRenderer) Layout(size fyne.Size) {
 		planeRender.FlightPathLine.Position2 = fyne.NewPos(destX, destY)
 		planeRender.FlightPathLine.Hidden = false
 
-		// Determine the most critical engagement for *this* plane in *this* frame
-		var mostCriticalEngagement *aviation.TCASEngagement = nil
+		// Apply the current engagement determined by the TCAS monitor (show/hide circle)
+		r.applyTCASCircle(planeRender, planeCoord, engagements[plane], crashed[plane.Serial], scale)
 
-		// --- TCAS Circle Logic ---
-		// Loop through all other planes to find potential interactions
-		for _, otherPlaneRender := range r.simulationArea.planesInFlight {
-			// Don't compare a plane with itself
-			if plane.Serial == otherPlaneRender.ActualPlane.Serial {
-				continue
-			}
-			otherPlane := otherPlaneRender.ActualPlane // Get the actual plane object
+		// The ground STCA alerts are shown on the data block of the plane, apart from the TCAS circle
+		r.applyDataBlock(planeRender, currentFlight.AltitudeAt(simTime), stcaAlerted[plane], displayX, displayY,
+			currentAirplaneDisplaySize, scale)
+	} // End of loop (planeRender)
 
-			// If both planes are not in the same Cruise altitude, skip
-			if plane.FlightLog[len(plane.FlightLog)-1].CruisingAltitude != otherPlane.FlightLog[len(otherPlane.FlightLog)-1].CruisingAltitude {
-				continue
-			}
+	// The conflicts predicted from the flight plans, drawn from the planes to where they would come closest
+	r.applyConflicts(positions, simTime, scale)
+}
 
-			otherPlaneState := planeStates[otherPlane] // Use pre-calculated state
-			if !otherPlaneState.OK {
-				continue // Skip if other plane is not in flight
-			}
-			otherPlaneCoord := otherPlaneState.Coord // Use pre-calculated position
-
-			distanceBetweenPlanes := aviation.Distance(planeCoord, otherPlaneCoord)
-
-			if distanceBetweenPlanes < TriggerEngageTCAS {
-				// This is a full engagement: Highest priority.
-				// Call tcasCore which now handles finding/creating the persistent record.
-				engagement := tcasCore(r.simulationArea.simState, plane, otherPlane)
-				mostCriticalEngagement = &engagement // Set this as the display engagement for this plane
-				break                                // Found a full engagement, no need to check other planes for *this* 'plane' anymore
-			} else if distanceBetweenPlanes < TriggerTCAS {
-				// This is a warning zone: Lower priority than full engagement.
-				// Only set if we haven't already found a full engagement for 'plane'.
-				if mostCriticalEngagement == nil { // If no full engagement found yet
-					// Create a temporary engagement record for warning display.
-					// This warning is *transient* for display only and not persisted in TCASEngagementRecords.
-					tempEngagement := aviation.TCASEngagement{
-						EngagementID:     fmt.Sprintf("W-Disp-%s-%s-%d", plane.Serial, otherPlane.Serial, simTime.UnixNano()), // Transient ID for display
-						PlaneSerial:      plane.Serial,
-						OtherPlaneSerial: otherPlane.Serial,
-						TimeOfEngagement: simTime,
-						WillCrash:        false,
-						WarningTriggered: true,
-						Engaged:          false,
-					}
-					mostCriticalEngagement = &tempEngagement
-				}
-			}
-		} // End of inner loop (otherPlaneRender)
+// applyDataBlock shows the data block of a plane the ground STCA alerts about, its serial and flight level next to
+// the plane, flashing as on a controller's screen. The data block of any other plane is hidden.
+// It flashes on the wall clock, so it still catches the eye while the simulation is paused.
+func (r *simulationAreaRenderer) applyDataBlock(pr *PlaneRender, altitude float64, alerted bool,
+	displayX, displayY float32, planeSize fyne.Size, scale float32) {
 
-		// After checking all other planes for 'plane', set its CurrentTCASEngagement
-		// based on the most critical interaction found (or nil if none).
-		plane.CurrentTCASEngagement = mostCriticalEngagement
+	if !alerted || time.Now().UnixMilli()/stcaFlashPeriod.Milliseconds()%2 == 1 {
+		pr.DataBlock.Hidden = true
+		return
+	}
 
-		// Apply the determined current engagement to the plane's rendering (show/hide circle)
-		r.applyTCASCircle(planeRender, planeCoord, plane.CurrentTCASEngagement, scale)
-	} // End of outer loop (planeRender)
+	pr.DataBlock.Text = fmt.Sprintf("%s FL%03.0f STCA", pr.ActualPlane.Serial, altitude/aviation.FeetToMeters/100)
+	pr.DataBlock.TextSize = 8 * scale
+	pr.DataBlock.Move(fyne.NewPos(displayX+planeSize.Width/2, displayY-planeSize.Height/2-pr.DataBlock.MinSize().Height))
+	pr.DataBlock.Resize(pr.DataBlock.MinSize())
+	pr.DataBlock.Hidden = false
+	pr.DataBlock.Refresh()
 }
 
-// applyTCASCircle Helper function to apply circle properties based on TCASEngagement
+// applyTCASCircle Helper function to apply circle properties based on TCASEngagement.
+// The plane image is hidden once the plane has crashed.
 func (r *simulationAreaRenderer) applyTCASCircle(pr *PlaneRender, pCoord aviation.Coordinate,
-	engagement *aviation.TCASEngagement, scale float32) {
+	engagement *aviation.TCASEngagement, crashed bool, scale float32) {
 
 	if engagement == nil {
 		pr.TCASCircle.Hidden = true
@@ -208,10 +184,10 @@ func (r *simulationAreaRenderer) applyTCASCircle(pr *PlaneRender, pCoord aviatio
 	if engagement.Engaged { // Green or Red state
 		pr.TCASCircle.StrokeColor = color.Transparent // No stroke for filled circles
 		if engagement.WillCrash {
-			r.simulationArea.CrashedPlanes = append(r.simulationArea.CrashedPlanes, engagement.PlaneSerial, engagement.OtherPlaneSerial)
-			pr.TCASCircle.FillColor = color.RGBA{R: 255, A: 255} // Red fill, plane destroyed
-			pr.Image.Hide()
-			r.simulationArea.planeCrash = true
+			pr.TCASCircle.FillColor = color.RGBA{R: 255, A: 255} // Red fill, the resolution failed
+			if crashed {
+				pr.Image.Hide() // plane destroyed
+			}
 		} else {
 			pr.TCASCircle.FillColor = color.RGBA{G: 255, A: 200} // Green fill, semi-transparent
 		}
@@ -241,14 +217,23 @@ func (r *simulationAreaRenderer) Objects() []fyne.CanvasObject {
 	}
 
 	// Add plane flight paths and images (order matters, paths usually behind planes)
-	for _, planeRender := range r.simulationArea.planesInFlight {
+	r.simulationArea.simState.Mu.Lock()
+	planes, _, _ := r.simulationArea.shownPlanes()
+	r.simulationArea.simState.Mu.Unlock()
+	for _, planeRender := range planes {
 		objects = append(objects,
 			planeRender.FlightPathLine,
 			planeRender.TCASCircle, // Draw circle before plane image so plane is on top
 			planeRender.Image,
+			planeRender.DataBlock,
 		)
 	}
 
+	// Add the overlay of the predicted conflicts over the planes
+	for _, cr := range r.simulationArea.conflictRenders {
+		objects = append(objects, cr.objects()...)
+	}
+
 	// Add status label last so it's always on top
 	objects = append(objects, r.simulationArea.statusLabel)
 
@@ -258,52 +243,39 @@ func (r *simulationAreaRenderer) Objects() []fyne.CanvasObject {
 // Destroy stops the animation ticker and clears all associated resources, performing necessary cleanup for the renderer.
 func (r *simulationAreaRenderer) Destroy() {
 	// Clean up any resources if necessary
-	r.simulationArea.animationTicker.Stop() // Stop the animation ticker
+	r.simulationArea.Stop() // Stop the animation ticker and its goroutine
 	r.simulationArea.ClearAllResource()
 }
 
 func (r *simulationAreaRenderer) Refresh() {
 	zoomText := fmt.Sprintf("Zoom: %.1fx", r.simulationArea.zoomScales[r.simulationArea.zoomLevel])
 
-	if !r.simulationArea.planeCrash {
+	simState := r.simulationArea.simState
+	simState.Mu.Lock()
+	planes, _, crashedPlanes := r.simulationArea.shownPlanes()
+	simState.Mu.Unlock()
+
+	if len(crashedPlanes) < 2 {
 		r.simulationArea.statusLabel.Text = fmt.Sprintf(
 			"Offset: %.0f, %.0f | %s | Drag to pan | Planes: %d",
-			r.simulationArea.offsetX, r.simulationArea.offsetY, zoomText, len(r.simulationArea.planesInFlight),
+			r.simulationArea.offsetX, r.simulationArea.offsetY, zoomText, len(planes),
 		)
+		// Scrubbing back to before a crash hides the crash message again
+		r.simulationArea.statusLabel.Color = color.RGBA{R: 0, G: 0, B: 0, A: 0}
+		r.simulationArea.statusLabel.TextSize = 8
+		r.simulationArea.statusLabel.TextStyle.Bold = false
 	} else {
-		if len(r.simulationArea.CrashedPlanes) > 1 {
-			tcasLog := r.simulationArea.simState.TCASLog
-			f := r.simulationArea.simState.ConsoleLog
-			planeSerial := r.simulationArea.CrashedPlanes[0]
-			otherPlaneSerial := r.simulationArea.CrashedPlanes[1]
-			r.simulationArea.statusLabel.Text = fmt.Sprintf("PLANE: %s AND PLANE: %s HAVE CRASHED !!!", planeSerial, otherPlaneSerial)
-			r.simulationArea.statusLabel.Color = color.RGBA{R: 255, A: 255}
-			r.simulationArea.statusLabel.TextSize = 30
-			r.simulationArea.statusLabel.TextStyle.Bold = true
-			if !r.simulationArea.crashTrigger {
-				// Carry out the corresponding actions depending of if the planes will successfully evade each orther or not
-				time.AfterFunc(3*time.Second, func() {
-					log.Printf("DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n",
-						planeSerial, otherPlaneSerial)
-					fmt.Fprintf(tcasLog, "%s DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n",
-						time.Now().Format("2006-01-02 15:04:05"), planeSerial, otherPlaneSerial)
-					fmt.Fprintf(f, "%s DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n",
-						time.Now().Format("2006-01-02 15:04:05"), planeSerial, otherPlaneSerial)
-
-					// at this point, the simulation ends
-					if r.simulationArea.simState.SimIsRunning {
-						aviation.EmergencyStop(r.simulationArea.simState)
-					}
-
-				})
-				r.simulationArea.crashTrigger = true
-
-			}
-		}
-
+		// The aviation package halts the simulation shortly after a crash, here we only report it
+		r.simulationArea.statusLabel.Text = fmt.Sprintf("PLANE: %s AND PLANE: %s HAVE CRASHED !!!", crashedPlanes[0], crashedPlanes[1])
+		r.simulationArea.statusLabel.Color = color.RGBA{R: 255, A: 255}
+		r.simulationArea.statusLabel.TextSize = 30
+		r.simulationArea.statusLabel.TextStyle.Bold = true
 	}
 	r.simulationArea.statusLabel.Refresh()
 
+	// Predicted before the layout, so the overlay of a new conflict is among the objects drawn
+	r.simulationArea.predictConflicts()
+
 	for _, airport := range r.simulationArea.airports {
 		airport.IDLabel.TextSize = 8 * r.simulationArea.zoomScales[r.simulationArea.zoomLevel]
 		airport.IDLabel.Refresh()
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"log"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+)
+
+// ShowTime shows the simulation as it was at simulation time t, rebuilt from its timeline.
+// The simulation itself is not changed, ShowLive returns to it.
+func (sa *SimulationArea) ShowTime(t time.Time) {
+	timeline := sa.simState.Timeline()
+	if timeline == nil {
+		return
+	}
+
+	state := timeline.StateAt(t)
+	planes := []*PlaneRender{}
+	for _, flight := range state.Flights {
+		planeRender, ok := sa.reviewCache[flight.Flight.FlightID]
+		if !ok {
+			// A copy of the plane on that flight, positioned by PlaneCurrentPosition like a live plane
+			plane := &aviation.Plane{Serial: flight.Plane, FlightLog: []aviation.Flight{flight.Flight}}
+			var err error
+			planeRender, err = sa.newPlaneRender(plane)
+			if err != nil {
+				log.Printf("Failed to rotate plane image: %v", err)
+				continue
+			}
+			sa.reviewCache[flight.Flight.FlightID] = planeRender
+		}
+		planeRender.ActualPlane.CurrentTCASEngagement = flight.TCAS
+		planeRender.ActualPlane.STCAConflicts = flight.STCA
+		planes = append(planes, planeRender)
+	}
+
+	sa.review = &state
+	sa.reviewPlanes = planes
+	sa.Refresh()
+}
+
+// ShowLive returns from the review of an earlier time to the live simulation.
+func (sa *SimulationArea) ShowLive() {
+	sa.review = nil
+	sa.reviewPlanes = nil
+	sa.Refresh()
+}
+
+// Reviewing reports whether the area shows an earlier time of the simulation rather than the live simulation.
+func (sa *SimulationArea) Reviewing() bool {
+	return sa.review != nil
+}
+
+// shownPlanes returns the planes to draw with the simulation time to draw them at and the crashed planes,
+// either those of the live simulation or those of the reviewed time.
+// It must be called with simState.Mu held.
+func (sa *SimulationArea) shownPlanes() ([]*PlaneRender, time.Time, []string) {
+	if sa.review != nil {
+		return sa.reviewPlanes, sa.review.Time, sa.review.CrashedPlanes
+	}
+	return sa.planesInFlight, sa.simState.CurrentSimTime, sa.simState.CrashedPlanes
+}
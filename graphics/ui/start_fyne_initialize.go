@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -11,13 +12,32 @@ import (
 	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
 )
 
+// GUIStepDuration is how far the step button advances a paused simulation
+const GUIStepDuration = 1 * time.Second
+
 // GraphicsSimulationInit initializes and sets up the main simulation window, including UI controls for navigation,
-// zoom, and quitting, alongside the core simulation area display.
+// zoom, pausing and quitting, alongside the core simulation area display.
 func GraphicsSimulationInit(simState *aviation.SimulationState, simulationWindow fyne.Window, inputWindow fyne.Window) {
 	simulationWindow.Resize(fyne.NewSize(800, 600)) // Larger window for simulation
 
 	simulationArea := NewSimulationArea(simState, inputWindow) // Pass inputWindow reference
 
+	// Timeline below the simulation area, drag it to review an earlier moment of the simulation
+	timelineScrubber := NewTimelineScrubber(simState, simulationArea)
+
+	// Every way of closing the window stops the goroutines redrawing it, they would outlive it otherwise
+	done := make(chan struct{})
+	var closeOnce sync.Once
+	closeWindow := func() {
+		closeOnce.Do(func() {
+			close(done)
+			simulationArea.Stop()
+			timelineScrubber.Stop()
+		})
+		simulationWindow.Close()
+	}
+	simulationWindow.SetCloseIntercept(closeWindow)
+
 	// Controls for the simulation window
 
 	homeButton := widget.NewButtonWithIcon("", theme.HomeIcon(), func() {
@@ -29,11 +49,23 @@ func GraphicsSimulationInit(simState *aviation.SimulationState, simulationWindow
 	zoomOutButton := widget.NewButtonWithIcon("", theme.ZoomOutIcon(), func() {
 		simulationArea.ZoomOut()
 	})
+	pauseButton := widget.NewButtonWithIcon("", theme.MediaPauseIcon(), func() {
+		simState.Pause()
+	})
+	resumeButton := widget.NewButtonWithIcon("", theme.MediaPlayIcon(), func() {
+		simState.Resume()
+	})
+	stepButton := widget.NewButtonWithIcon("", theme.MediaSkipNextIcon(), func() {
+		simState.Step(GUIStepDuration)
+	})
+	conflictsButton := widget.NewButtonWithIcon("Conflicts", theme.WarningIcon(), func() {
+		simulationArea.ToggleConflicts()
+	})
 	quitButton := widget.NewButtonWithIcon("Quit", theme.CancelIcon(), func() {
-		simulationWindow.Close()
+		closeWindow()
 		inputWindow.Show()
 		if simState.SimIsRunning {
-			aviation.EmergencyStop(simState)
+			simState.EmergencyStop()
 		}
 		aviation.CloseLogFiles(simState)
 
@@ -45,6 +77,10 @@ func GraphicsSimulationInit(simState *aviation.SimulationState, simulationWindow
 		homeButton,
 		zoomInButton,
 		zoomOutButton,
+		pauseButton,
+		resumeButton,
+		stepButton,
+		conflictsButton,
 		quitButton,
 		layout.NewSpacer(),
 	)
@@ -53,22 +89,23 @@ func GraphicsSimulationInit(simState *aviation.SimulationState, simulationWindow
 		ticker := time.NewTicker(1 * time.Second)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
 			if !simState.SimIsRunning {
-				fyne.Do(func() {
-					simulationWindow.Close()
-
-				})
-
+				fyne.Do(closeWindow)
 				return
 			}
 		}
 	}()
 
-	// Main content layout for simulation window: controls at top, simulation area fills rest
+	// Main content layout for simulation window: controls at top, timeline at the bottom, simulation area fills rest
 	simContent := container.NewBorder(
 		simControls,
-		nil,
+		timelineScrubber,
 		nil,
 		nil,
 		simulationArea,
@@ -0,0 +1,216 @@
+package ui
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+)
+
+// timelineRefreshInterval is how often the timeline scrubber follows the simulation and updates its markers
+const timelineRefreshInterval = 250 * time.Millisecond
+
+// timelineMarkerHeight is the height of the strip above the slider where the markers are drawn
+const timelineMarkerHeight float32 = 10
+
+// timelineMarkerColors gives the color of each kind of marker, the same colors as the TCAS circles
+var timelineMarkerColors = map[string]color.Color{
+	aviation.ReplayTakeoff:    color.RGBA{R: 200, G: 200, B: 200, A: 255}, // Light grey, like the flight paths
+	aviation.ReplayTCAS:       color.RGBA{R: 255, G: 165, A: 255},         // Orange, TCAS warning
+	aviation.ReplayEngagement: color.RGBA{G: 255, A: 255},                 // Green, TCAS engagement
+	aviation.ReplaySTCA:       stcaColor,                                  // Magenta, ground STCA alert
+	aviation.ReplayCrash:      color.RGBA{R: 255, A: 255},                 // Red, collision
+}
+
+// TimelineScrubber is the timeline slider below the SimulationArea. Dragging it shows the simulation at the
+// chosen time, and markers above it show the takeoffs, TCAS warnings, engagements and crashes.
+// Scrubbing pauses the simulation; once it is resumed or stepped the area shows the live simulation again.
+type TimelineScrubber struct {
+	widget.BaseWidget
+	slider    *widget.Slider
+	timeLabel *widget.Label
+
+	start, end time.Time                 // range of the slider, in simulation time
+	markers    []aviation.TimelineMarker // notable moments of the timeline
+	following  bool                      // set while the slider follows the simulation, so the move is not taken for a drag
+	reviewFrom time.Time                 // simulation clock time when the review started
+
+	simulationArea *SimulationArea
+	simState       *aviation.SimulationState
+	ticker         *time.Ticker
+	done           chan struct{} // closed by Stop, ends the goroutine following the simulation
+	stopOnce       sync.Once
+}
+
+// NewTimelineScrubber creates the timeline scrubber of the simulation shown in simulationArea.
+func NewTimelineScrubber(simState *aviation.SimulationState, simulationArea *SimulationArea) *TimelineScrubber {
+	ts := &TimelineScrubber{
+		slider:         widget.NewSlider(0, 1),
+		timeLabel:      widget.NewLabel("--:--:--"),
+		simulationArea: simulationArea,
+		simState:       simState,
+		done:           make(chan struct{}),
+	}
+	ts.slider.Step = aviation.TCASCheckInterval.Seconds() // The timeline changes at most once per TCAS check
+	ts.slider.OnChanged = ts.scrubTo
+	ts.ExtendBaseWidget(ts)
+
+	ts.ticker = time.NewTicker(timelineRefreshInterval)
+	go func() {
+		for {
+			select {
+			case <-ts.done:
+				return
+			case <-ts.ticker.C:
+				fyne.Do(ts.update)
+			}
+		}
+	}()
+
+	return ts
+}
+
+// Stop stops following the simulation, once the window of the scrubber is closed. It may be called more than once.
+func (ts *TimelineScrubber) Stop() {
+	ts.stopOnce.Do(func() {
+		ts.ticker.Stop()
+		close(ts.done)
+	})
+}
+
+// scrubTo shows the simulation at the time chosen on the slider, value is in seconds from the start of the timeline.
+func (ts *TimelineScrubber) scrubTo(value float64) {
+	if ts.following || ts.start.IsZero() {
+		return
+	}
+
+	if !ts.simulationArea.Reviewing() && ts.simState.Clock != nil {
+		if !ts.simState.Clock.Paused() {
+			ts.simState.Pause()
+		}
+		ts.reviewFrom = ts.simState.Clock.Now()
+	}
+
+	t := ts.start.Add(time.Duration(value * float64(time.Second)))
+	ts.simulationArea.ShowTime(t)
+	ts.timeLabel.SetText(fmt.Sprintf("Review %s / %s", t.Format("15:04:05"), ts.end.Format("15:04:05")))
+}
+
+// update follows the simulation: it extends the slider to the latest simulation time, moves it along
+// unless the user is reviewing an earlier time, and refreshes the markers.
+func (ts *TimelineScrubber) update() {
+	timeline := ts.simState.Timeline()
+	if timeline == nil {
+		return
+	}
+
+	// Resuming or stepping the simulation, from the window or the CLI, goes back to the live simulation
+	if ts.simulationArea.Reviewing() && ts.simState.Clock != nil && !ts.simState.Clock.Now().Equal(ts.reviewFrom) {
+		ts.simulationArea.ShowLive()
+	}
+
+	ts.simState.Mu.Lock()
+	live := ts.simState.CurrentSimTime
+	ts.simState.Mu.Unlock()
+
+	ts.start = timeline.Start()
+	ts.end = timeline.End()
+	if live.After(ts.end) {
+		ts.end = live
+	}
+	ts.markers = timeline.Markers()
+
+	ts.slider.Max = max(ts.end.Sub(ts.start).Seconds(), ts.slider.Step)
+	if !ts.simulationArea.Reviewing() {
+		ts.following = true
+		ts.slider.SetValue(live.Sub(ts.start).Seconds())
+		ts.following = false
+		ts.timeLabel.SetText(fmt.Sprintf("%s / %s", live.Format("15:04:05"), ts.end.Format("15:04:05")))
+	}
+	ts.slider.Refresh()
+	ts.Refresh()
+}
+
+// CreateRenderer is part of the fyne.Widget interface.
+func (ts *TimelineScrubber) CreateRenderer() fyne.WidgetRenderer {
+	return &timelineScrubberRenderer{timelineScrubber: ts}
+}
+
+// timelineScrubberRenderer implements fyne.WidgetRenderer for TimelineScrubber.
+type timelineScrubberRenderer struct {
+	timelineScrubber *TimelineScrubber
+	markers          []*canvas.Rectangle // one rectangle per marker, reused between refreshes
+}
+
+// MinSize returns the size of the slider with the marker strip above it and the time label beside it.
+func (r *timelineScrubberRenderer) MinSize() fyne.Size {
+	sliderSize := r.timelineScrubber.slider.MinSize()
+	labelSize := r.timelineScrubber.timeLabel.MinSize()
+	return fyne.NewSize(sliderSize.Width+labelSize.Width, timelineMarkerHeight+max(sliderSize.Height, labelSize.Height))
+}
+
+// Layout places the time label on the right, the slider on the rest of the width and each marker
+// above the point of the slider track matching its time.
+func (r *timelineScrubberRenderer) Layout(size fyne.Size) {
+	ts := r.timelineScrubber
+	labelWidth := ts.timeLabel.MinSize().Width
+	sliderSize := fyne.NewSize(size.Width-labelWidth, size.Height-timelineMarkerHeight)
+
+	ts.slider.Move(fyne.NewPos(0, timelineMarkerHeight))
+	ts.slider.Resize(sliderSize)
+	ts.timeLabel.Move(fyne.NewPos(sliderSize.Width, timelineMarkerHeight))
+	ts.timeLabel.Resize(fyne.NewSize(labelWidth, sliderSize.Height))
+
+	// The slider track is inset from its ends by the radius of the thumb, as in widget.Slider
+	th := ts.Theme()
+	endPad := (th.Size(theme.SizeNameInlineIcon)-4)/2 + th.Size(theme.SizeNameInnerPadding) - 1.5
+	trackWidth := sliderSize.Width - endPad*2
+	duration := ts.end.Sub(ts.start)
+
+	for i, marker := range ts.markers {
+		if i >= len(r.markers) {
+			break
+		}
+		ratio := float32(0)
+		if duration > 0 {
+			ratio = float32(marker.Time.Sub(ts.start)) / float32(duration)
+		}
+		r.markers[i].Move(fyne.NewPos(endPad+ratio*trackWidth-1, 0))
+		r.markers[i].Resize(fyne.NewSize(2, timelineMarkerHeight))
+	}
+}
+
+// Objects returns the markers, the slider and the time label.
+func (r *timelineScrubberRenderer) Objects() []fyne.CanvasObject {
+	objects := []fyne.CanvasObject{}
+	for _, marker := range r.markers {
+		objects = append(objects, marker)
+	}
+	return append(objects, r.timelineScrubber.slider, r.timelineScrubber.timeLabel)
+}
+
+// Refresh creates a rectangle for each new marker, colors them by kind and lays them out again.
+func (r *timelineScrubberRenderer) Refresh() {
+	markers := r.timelineScrubber.markers
+	for len(r.markers) < len(markers) {
+		r.markers = append(r.markers, canvas.NewRectangle(color.Transparent))
+	}
+	r.markers = r.markers[:len(markers)]
+	for i, marker := range markers {
+		r.markers[i].FillColor = timelineMarkerColors[marker.Kind]
+		r.markers[i].Refresh()
+	}
+
+	r.Layout(r.timelineScrubber.Size())
+}
+
+// Destroy stops following the simulation.
+func (r *timelineScrubberRenderer) Destroy() {
+	r.timelineScrubber.Stop()
+}
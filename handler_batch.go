@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+)
+
+// runBatch runs a Monte Carlo batch of headless simulations and writes its statistics to
+// <out>_summary.csv, <out>_runs.csv and <out>.json.
+func runBatch(batch aviation.BatchConfig, out string) error {
+	if batch.Runs < 1 {
+		return fmt.Errorf("a batch needs at least 1 run, got %d", batch.Runs)
+	}
+	if batch.Scenario.NoOfAirplanes < 4 {
+		return fmt.Errorf("a batch needs at least 4 planes, got %d", batch.Scenario.NoOfAirplanes)
+	}
+	if batch.DurationMinutes < 1 {
+		return fmt.Errorf("a batch needs a duration of at least 1 minute, got %d", batch.DurationMinutes)
+	}
+	if err := validateScenario(batch.Scenario); err != nil {
+		return err
+	}
+
+	// The simulations log every takeoff and landing, which is only noise for a batch
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	fmt.Printf("Running %d simulations of %d planes for %d minute(s)...\n",
+		batch.Runs, batch.Scenario.NoOfAirplanes, batch.DurationMinutes)
+	batch.Progress = func(done int) {
+		fmt.Printf("\r  %d/%d runs completed", done, batch.Runs)
+	}
+	summary := aviation.RunBatch(batch)
+	fmt.Println()
+
+	if dir := filepath.Dir(out); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+	if err := writeBatchSummaryCSV(summary, out+"_summary.csv"); err != nil {
+		return err
+	}
+	if err := writeBatchRunsCSV(summary, out+"_runs.csv"); err != nil {
+		return err
+	}
+	if err := writeBatchJSON(summary, out+".json"); err != nil {
+		return err
+	}
+
+	printBatchSummary(summary)
+	fmt.Printf("\nResults written to %s_summary.csv, %s_runs.csv and %s.json\n", out, out, out)
+	return nil
+}
+
+// printBatchSummary prints the main statistics of a batch to the console.
+func printBatchSummary(summary aviation.BatchSummary) {
+	fmt.Printf("\n--- Batch of %d runs (base seed %d), 95%% confidence intervals ---\n", summary.Runs, summary.BaseSeed)
+	printEstimate := func(name string, e aviation.Estimate) {
+		fmt.Printf("  %-28s %8.3f  [%.3f, %.3f]\n", name, e.Value, e.Lower, e.Upper)
+	}
+	printEstimate("Engagements per run:", summary.EngagementsPerRun)
+	printEstimate("Flights completed per run:", summary.FlightsCompletedPerRun)
+	printEstimate("Runs ended by a crash:", summary.RunsEndedByCrash)
+	printEstimate("STCA alerts per run:", summary.STCAAlertsPerRun)
+	printEstimate("RAs after an STCA alert:", summary.RAsAfterSTCA)
+	fmt.Println("  Crash rate by TCAS pairing:")
+	for _, p := range summary.Pairings {
+		fmt.Printf("    %-24s %8.3f  [%.3f, %.3f]  (%d crashes / %d losses of separation, %d engagements)\n",
+			p.Pairing+":", p.CrashRate.Value, p.CrashRate.Lower, p.CrashRate.Upper, p.Crashes, p.Exposures, p.Engagements)
+	}
+	fmt.Println("  Crash rate by crew responses:")
+	for _, p := range summary.ResponsePairings {
+		fmt.Printf("    %-24s %8.3f  [%.3f, %.3f]  (%d crashes / %d engagements)\n",
+			p.Pairing+":", p.CrashRate.Value, p.CrashRate.Lower, p.CrashRate.Upper, p.Crashes, p.Engagements)
+	}
+}
+
+// writeBatchSummaryCSV writes one row per statistic of the batch, with its confidence interval.
+func writeBatchSummaryCSV(summary aviation.BatchSummary, path string) error {
+	rows := [][]string{{"metric", "value", "ci_lower", "ci_upper", "crashes", "engagements", "exposures"}}
+	estimateRow := func(name string, e aviation.Estimate) []string {
+		return []string{name, formatFloat(e.Value), formatFloat(e.Lower), formatFloat(e.Upper), "", "", ""}
+	}
+	rows = append(rows,
+		estimateRow("engagements_per_run", summary.EngagementsPerRun),
+		estimateRow("flights_completed_per_run", summary.FlightsCompletedPerRun),
+		estimateRow("runs_ended_by_crash", summary.RunsEndedByCrash),
+		estimateRow("stca_alerts_per_run", summary.STCAAlertsPerRun),
+		estimateRow("ras_after_stca", summary.RAsAfterSTCA),
+	)
+	for _, p := range summary.Pairings {
+		row := estimateRow("crash_rate_"+p.Pairing, p.CrashRate)
+		row[4] = strconv.Itoa(p.Crashes)
+		row[5] = strconv.Itoa(p.Engagements)
+		row[6] = strconv.Itoa(p.Exposures)
+		rows = append(rows, row)
+	}
+	for _, p := range summary.ResponsePairings {
+		row := estimateRow("crash_rate_response_"+p.Pairing, p.CrashRate)
+		row[4] = strconv.Itoa(p.Crashes)
+		row[5] = strconv.Itoa(p.Engagements)
+		row[6] = strconv.Itoa(p.Exposures)
+		rows = append(rows, row)
+	}
+	return writeCSV(path, rows)
+}
+
+// writeBatchRunsCSV writes one row per run of the batch, each run can be reproduced from its seed.
+func writeBatchRunsCSV(summary aviation.BatchSummary, path string) error {
+	rows := [][]string{{"run", "seed", "flights_started", "flights_completed", "engagements", "crashes", "ended_by_crash",
+		"stca_alerts", "ras_after_stca", "stca_lead_seconds"}}
+	for _, r := range summary.Results {
+		rows = append(rows, []string{
+			strconv.Itoa(r.Run),
+			strconv.FormatInt(r.Seed, 10),
+			strconv.Itoa(r.FlightsStarted),
+			strconv.Itoa(r.FlightsCompleted),
+			strconv.Itoa(r.Engagements),
+			strconv.Itoa(r.Crashes),
+			strconv.FormatBool(r.EndedByCrash),
+			strconv.Itoa(r.STCAAlerts),
+			strconv.Itoa(r.RAsAfterSTCA),
+			formatFloat(r.STCALeadSeconds),
+		})
+	}
+	return writeCSV(path, rows)
+}
+
+// writeCSV writes the rows to a new CSV file at path.
+func writeCSV(path string, rows [][]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeBatchJSON writes the whole summary of the batch, including every run, to a JSON file.
+func writeBatchJSON(summary aviation.BatchSummary, path string) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode batch results: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// formatFloat formats a statistic for the CSV files.
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
@@ -18,12 +18,21 @@ func getDetails(simState *aviation.SimulationState, argument2 string) {
 		getAirPlanesDetails(simState)
 	case "flights":
 		getFlightDetails(simState)
+	case "encounters":
+		getEncounterDetails(simState)
+	case "stca":
+		getSTCADetails(simState)
+	case "conflicts":
+		getConflictDetails(simState)
 	case "all":
 		getAirportDetails(simState)
 		getAirPlanesDetails(simState)
 		getFlightDetails(simState)
+		getEncounterDetails(simState)
+		getSTCADetails(simState)
+		getConflictDetails(simState)
 	default:
-		fmt.Println("usage: get <option>, options: airports, airplanes, flights, all")
+		fmt.Println("usage: get <option>, options: airports, airplanes, flights, encounters, stca, conflicts, all")
 	}
 }
 
@@ -31,7 +40,7 @@ func getDetails(simState *aviation.SimulationState, argument2 string) {
 func getFlightDetails(simState *aviation.SimulationState) {
 	var simTime time.Time
 	if simState.SimIsRunning {
-		simTime = time.Now()
+		simTime = simState.CurrentSimTime
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -70,7 +79,7 @@ func getFlightDetails(simState *aviation.SimulationState) {
 func getAirPlanesDetails(simState *aviation.SimulationState) {
 	var simTime time.Time
 	if simState.SimIsRunning {
-		simTime = time.Now()
+		simTime = simState.CurrentSimTime
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -90,17 +99,15 @@ func getAirPlanesDetails(simState *aviation.SimulationState) {
 		fmt.Println("\n--- No plane recorded currently ---")
 		return
 	}
+	encounters := simState.EncounterList()
 	for i, plane := range Planes {
 		fmt.Printf("Plane %d (Serial: %s):\n", i+1, plane.Serial)
 		fmt.Printf("  In Flight: %t\n", plane.PlaneInFlight)
 		fmt.Printf("  Cruise Speed: %.2f m/s\n", plane.CruiseSpeed)
-		fmt.Printf("  TCAS Capability: %s\n", func(capability aviation.TCASCapability) string {
-			if capability == 1 {
-				return "Working Perfectly"
-			} else {
-				return "Faulty"
-			}
-		}(plane.TCASCapability))
+		fmt.Printf("  TCAS Capability: %s\n", plane.TCASCapability.Label())
+		fmt.Printf("  Collision Avoidance: %s\n", plane.CollisionAvoidance)
+		fmt.Printf("  Crew: responds in %.1f s (%.1f s when strengthened), ignores %.0f%% and opposes %.0f%% of RAs\n",
+			plane.Pilot.ResponseDelay, plane.Pilot.StrengthenedDelay, plane.Pilot.NonComplianceProbability*100, plane.Pilot.OppositeProbability*100)
 		fmt.Println("  Flight Log:")
 		if len(plane.FlightLog) == 0 {
 			fmt.Println("    No flights recorded for this plane.")
@@ -109,16 +116,32 @@ func getAirPlanesDetails(simState *aviation.SimulationState) {
 				printFlightDetails(flight, simTime)
 			}
 		}
-		if len(plane.TCASEngagementRecords) == 0 {
+		engagements := simState.EngagementsOf(plane)
+		if len(engagements) == 0 {
 			fmt.Println("    No Past TCAS engagement recorded for this plane.")
 		} else {
-			for _, engagement := range plane.TCASEngagementRecords {
+			for _, engagement := range engagements {
 				fmt.Println("    --- Past Engagement Details ---")
 				printEngagementDetails(engagement)
 			}
 		}
-		fmt.Println("    --- Expected Engagement Details ---")
-		printEngagementDetails(*plane.CurrentTCASEngagement)
+		// Every pair that came into TA range has an encounter, with its miss distances
+		fmt.Println("    --- Encounters ---")
+		for _, encounter := range encounters {
+			if encounter.Planes[0] == plane.Serial || encounter.Planes[1] == plane.Serial {
+				fmt.Printf("    %s\n", encounterSummary(plane.Serial, encounter))
+			}
+		}
+		if len(plane.TCASThreats) == 0 {
+			fmt.Println("    No active TCAS threat for this plane.")
+		} else {
+			fmt.Println("    --- Active Threats ---")
+			for _, threat := range plane.TCASThreats {
+				fmt.Printf("    %s\n", threatSummary(plane.Serial, threat))
+			}
+			fmt.Println("    --- Expected Engagement Details ---")
+			printEngagementDetails(*plane.CurrentTCASEngagement)
+		}
 
 	}
 	fmt.Println("-------------------------------------------")
@@ -157,6 +180,13 @@ func printFlightDetails(flight aviation.Flight, simTime time.Time) {
 	fmt.Printf("    Takeoff Time: %s\n", flight.TakeoffTime.Format("15:04:05"))
 	fmt.Printf("    Destination Arrival Time: %s\n", flight.DestinationArrivalTime.Format("15:04:05"))
 	fmt.Printf("    Cruising Altitude: %.2f meters\n", flight.CruisingAltitude)
+	if flight.ActualLandingTime.IsZero() && !simTime.Before(flight.TakeoffTime) {
+		fmt.Printf("    Current Altitude: %.2f meters\n", flight.AltitudeAt(simTime))
+	}
+	for _, maneuver := range flight.VerticalProfile {
+		fmt.Printf("    Manoeuvre at %s: %s from %.2f meters at %.2f m/s to %.2f meters\n", maneuver.Time.Format("15:04:05"),
+			maneuver.Reason, maneuver.Altitude, maneuver.VerticalRate, maneuver.TargetAltitude)
+	}
 	fmt.Printf("    Depature Airport: %s\n", flight.DepatureAirPort)
 	fmt.Printf("    Destination Airport: %s\n", flight.ArrivalAirPort)
 	var actualLandingTime string
@@ -189,4 +219,179 @@ func printEngagementDetails(engagement aviation.TCASEngagement) {
 			return "no"
 		}
 	}(engagement.WillCrash))
+	if engagement.Engaged {
+		fmt.Printf("    Crew Responses: %s %s, %s %s\n", engagement.PlaneSerial, engagement.PlaneResponse,
+			engagement.OtherPlaneSerial, engagement.OtherPlaneResponse)
+		fmt.Printf("    Current RAs: %s %s, %s %s\n", engagement.PlaneSerial, raName(engagement.PlaneRA),
+			engagement.OtherPlaneSerial, raName(engagement.OtherPlaneRA))
+		for _, transition := range engagement.Transitions {
+			fmt.Printf("      %s RA of %s %s, %s to %s\n", transition.Time.Format("15:04:05.0"), transition.Plane,
+				transition.Kind, transition.From, transition.To)
+		}
+		if engagement.Miss != nil {
+			fmt.Printf("    Miss Distance: %s\n", missSummary(*engagement.Miss))
+		}
+	}
+}
+
+// getEncounterDetails prints the lifecycle of every encounter between two planes, in the order they started.
+func getEncounterDetails(simState *aviation.SimulationState) {
+	encounters := simState.EncounterList()
+	fmt.Println("\n--- Printing all encounters ---")
+	if len(encounters) == 0 {
+		fmt.Println("\n--- No encounter recorded currently ---")
+		return
+	}
+	for _, encounter := range encounters {
+		printEncounterDetails(encounter)
+	}
+	fmt.Println()
+}
+
+// printEncounterDetails prints the timestamps of an encounter from its first advisory to its outcome,
+// with the time between them.
+func printEncounterDetails(encounter aviation.Encounter) {
+	fmt.Printf("  --- Encounter %s ---\n", encounter.ID)
+	fmt.Printf("    Planes: %s, %s\n", encounter.Planes[0], encounter.Planes[1])
+	fmt.Printf("    Outcome: %s\n", encounter.Outcome)
+	if !encounter.TAStart.IsZero() {
+		fmt.Printf("    TA: %s\n", encounter.TAStart.Format("15:04:05.0"))
+	}
+	if !encounter.RAIssued.IsZero() {
+		fmt.Printf("    RA: %s (engagement %s)", encounter.RAIssued.Format("15:04:05.0"), encounter.EngagementID)
+		if warning, ok := encounter.WarningTime(); ok {
+			fmt.Printf(", %.1f s after the TA", warning.Seconds())
+		}
+		fmt.Println()
+	}
+	for _, change := range encounter.RAChanges {
+		fmt.Printf("      %s RA of %s %s, %s to %s\n", change.Time.Format("15:04:05.0"), change.Plane,
+			change.Kind, change.From, change.To)
+	}
+	fmt.Printf("    Closest Approach: %s\n", missSummary(encounter.ClosestApproach))
+	if end, ok := encounter.End(); ok {
+		fmt.Printf("    Ended: %s, after %.1f s\n", end.Format("15:04:05.0"), end.Sub(encounter.Start()).Seconds())
+	}
+}
+
+// getSTCADetails prints every alert of the ground Short-Term Conflict Alert, in the order they were raised.
+func getSTCADetails(simState *aviation.SimulationState) {
+	alerts := simState.STCAAlertList()
+	fmt.Println("\n--- Printing all STCA alerts ---")
+	if len(alerts) == 0 {
+		fmt.Println("\n--- No STCA alert recorded currently ---")
+		return
+	}
+	for _, alert := range alerts {
+		printSTCADetails(alert)
+	}
+	fmt.Println()
+}
+
+// printSTCADetails prints when an STCA alert was raised and cleared, what the ground predicted, and how it met
+// the RA of the planes.
+func printSTCADetails(alert aviation.STCAAlert) {
+	fmt.Printf("  --- STCA Alert %s ---\n", alert.ID)
+	fmt.Printf("    Planes: %s, %s\n", alert.Planes[0], alert.Planes[1])
+	fmt.Printf("    Raised: %s\n", alert.Raised.Format("15:04:05.0"))
+	fmt.Printf("    Predicted: %s\n", stcaPredictionSummary(alert))
+	fmt.Printf("    RA: %s\n", stcaRASummary(alert))
+	if !alert.Active() {
+		fmt.Printf("    Cleared: %s, after %.1f s\n", alert.Cleared.Format("15:04:05.0"), alert.Cleared.Sub(alert.Raised).Seconds())
+	}
+}
+
+// stcaPredictionSummary describes in one line what the ground predicted when it raised an alert: when the planes
+// would lose separation and how close they would come.
+func stcaPredictionSummary(alert aviation.STCAAlert) string {
+	return fmt.Sprintf("separation lost at %s, %.1f NM and %.0f ft apart at closest approach",
+		alert.PredictedLoss.Format("15:04:05.0"), alert.HorizontalNM(), alert.VerticalFeet())
+}
+
+// stcaRASummary describes in one line the RA of the planes of an alert against each other, and whether the
+// ground alerted before it.
+func stcaRASummary(alert aviation.STCAAlert) string {
+	lead, ok := alert.RALead()
+	switch {
+	case !ok:
+		return "none"
+	case lead >= 0:
+		return fmt.Sprintf("%s (engagement %s), %.1f real s after the alert", alert.RAIssued.Format("15:04:05.0"), alert.EngagementID, lead.Seconds())
+	default:
+		return fmt.Sprintf("%s (engagement %s), %.1f real s before the alert", alert.RAIssued.Format("15:04:05.0"), alert.EngagementID, -lead.Seconds())
+	}
+}
+
+// getConflictDetails prints the losses of separation predicted from the flight plans of the planes, the soonest first.
+func getConflictDetails(simState *aviation.SimulationState) {
+	simTime := simState.CurrentSimTime
+	conflicts := simState.PredictConflicts()
+	fmt.Printf("\n--- Printing conflicts predicted for the next %.0f minutes ---\n", aviation.ConflictLookAhead.Minutes())
+	if len(conflicts) == 0 {
+		fmt.Println("\n--- No conflict predicted currently ---")
+		return
+	}
+	for _, conflict := range conflicts {
+		printConflictDetails(conflict, simTime)
+	}
+	fmt.Println()
+}
+
+// printConflictDetails prints which flights are predicted to lose separation, when, and how close they would come.
+func printConflictDetails(conflict aviation.PredictedConflict, simTime time.Time) {
+	fmt.Printf("  --- Conflict %s / %s ---\n", conflict.Planes[0], conflict.Planes[1])
+	fmt.Printf("    Flights: %s, %s\n", conflict.Flights[0], conflict.Flights[1])
+	fmt.Printf("    Separation Lost: %s\n", conflictStartSummary(conflict, simTime))
+	fmt.Printf("    Closest Approach: %s, %.1f NM and %.0f ft apart\n", conflict.Closest.Format("15:04:05.0"),
+		conflict.HorizontalNM(), conflict.VerticalFeet())
+	fmt.Printf("    Separation Regained: %s\n", conflict.End.Format("15:04:05.0"))
+}
+
+// conflictStartSummary describes when a predicted conflict starts, and how many minutes of real flight are left
+// to resolve it.
+func conflictStartSummary(conflict aviation.PredictedConflict, simTime time.Time) string {
+	return fmt.Sprintf("%s, %.1f min ahead", conflict.Start.Format("15:04:05.0"), conflict.TimeToLoss(simTime).Minutes())
+}
+
+// missSummary describes in one line how close two planes came: the time of their closest approach, the horizontal
+// and vertical miss distances in feet, and whether they were in a Near Mid-Air Collision.
+func missSummary(miss aviation.MissDistance) string {
+	summary := fmt.Sprintf("%s, %.0f ft horizontally and %.0f ft vertically apart", miss.Time.Format("15:04:05.0"),
+		miss.HorizontalFeet(), miss.VerticalFeet())
+	if miss.NMAC {
+		summary += ", NMAC"
+	}
+	return summary
+}
+
+// encounterSummary describes in one line an encounter of the plane with the given serial: the other plane,
+// the outcome and how close they came.
+func encounterSummary(serial string, encounter aviation.Encounter) string {
+	other := encounter.Planes[0]
+	if other == serial {
+		other = encounter.Planes[1]
+	}
+	return fmt.Sprintf("%s with %s: %s, closest approach %s", encounter.ID, other, encounter.Outcome,
+		missSummary(encounter.ClosestApproach))
+}
+
+// threatSummary describes in one line an advisory of the plane with the given serial against one intruder:
+// the intruder, whether it is an RA or a TA, and the RA the plane flies.
+func threatSummary(serial string, threat aviation.TCASEngagement) string {
+	intruder, ra := threat.OtherPlaneSerial, threat.PlaneRA
+	if intruder == serial {
+		intruder, ra = threat.PlaneSerial, threat.OtherPlaneRA
+	}
+	if !threat.Engaged {
+		return fmt.Sprintf("%s: TA", intruder)
+	}
+	return fmt.Sprintf("%s: RA, %s", intruder, raName(ra))
+}
+
+// raName returns the RA of a plane as shown to users, planes that got no RA have none.
+func raName(ra string) string {
+	if ra == "" {
+		return "none"
+	}
+	return ra
 }
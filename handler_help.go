@@ -8,9 +8,9 @@ import (
 )
 
 // helpFunc displays a welcome message and lists all available commands with their descriptions.
-func helpFunc(cfg *config.Config, simState *aviation.SimulationState, argument2 string) {
+func helpFunc(cfg *config.Config, simState *aviation.SimulationState, gui *fyneGUI, argument2 string) {
 	fmt.Print("Welcome to TCAS-simulator!\nUsage\n\n")
-	for key := range getCommand(cfg, simState, argument2) {
-		fmt.Printf("%s: %s\n", getCommand(cfg, simState, argument2)[key].name, getCommand(cfg, simState, argument2)[key].description)
+	for key := range getCommand(cfg, simState, gui, argument2) {
+		fmt.Printf("%s: %s\n", getCommand(cfg, simState, gui, argument2)[key].name, getCommand(cfg, simState, gui, argument2)[key].description)
 	}
 }
@@ -20,12 +20,21 @@ func logDetails(simState *aviation.SimulationState, argument2 string) {
 		logAirplanesDetails(simState)
 	case "flights":
 		logFlightDetailsToFile(simState)
+	case "encounters":
+		logEncounterDetails(simState)
+	case "stca":
+		logSTCADetails(simState)
+	case "conflicts":
+		logConflictDetails(simState)
 	case "all":
 		logAirportDetails(simState)
 		logAirplanesDetails(simState)
 		logFlightDetailsToFile(simState)
+		logEncounterDetails(simState)
+		logSTCADetails(simState)
+		logConflictDetails(simState)
 	default:
-		fmt.Println("usage: log <option>, options: airports, airplanes, flights, all")
+		fmt.Println("usage: log <option>, options: airports, airplanes, flights, encounters, stca, conflicts, all")
 	}
 }
 
@@ -41,7 +50,7 @@ func logFlightDetailsToFile(simState *aviation.SimulationState) {
 
 	var simTime time.Time
 	if simState.SimIsRunning {
-		simTime = time.Now()
+		simTime = simState.CurrentSimTime
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -89,7 +98,7 @@ func logAirplanesDetails(simState *aviation.SimulationState) {
 
 	var simTime time.Time
 	if simState.SimIsRunning {
-		simTime = time.Now()
+		simTime = simState.CurrentSimTime
 	} else {
 		simTime = simState.SimEndedTime
 	}
@@ -109,17 +118,15 @@ func logAirplanesDetails(simState *aviation.SimulationState) {
 		fmt.Fprintln(f, "\n--- No plane recorded currently ---")
 		return
 	}
+	encounters := simState.EncounterList()
 	for i, plane := range Planes {
 		fmt.Fprintf(f, "Plane %d (Serial: %s):\n", i+1, plane.Serial)
 		fmt.Fprintf(f, "  In Flight: %t\n", plane.PlaneInFlight)
 		fmt.Fprintf(f, "  Cruise Speed: %.2f m/s\n", plane.CruiseSpeed)
-		fmt.Fprintf(f, "  TCAS Capability: %s\n", func(capability aviation.TCASCapability) string {
-			if capability == 1 {
-				return "Working Perfectly"
-			} else {
-				return "Faulty"
-			}
-		}(plane.TCASCapability))
+		fmt.Fprintf(f, "  TCAS Capability: %s\n", plane.TCASCapability.Label())
+		fmt.Fprintf(f, "  Collision Avoidance: %s\n", plane.CollisionAvoidance)
+		fmt.Fprintf(f, "  Crew: responds in %.1f s (%.1f s when strengthened), ignores %.0f%% and opposes %.0f%% of RAs\n",
+			plane.Pilot.ResponseDelay, plane.Pilot.StrengthenedDelay, plane.Pilot.NonComplianceProbability*100, plane.Pilot.OppositeProbability*100)
 		fmt.Fprintln(f, "  Flight Log:")
 		if len(plane.FlightLog) == 0 {
 			fmt.Fprintln(f, "    No flights recorded for this plane.")
@@ -128,16 +135,28 @@ func logAirplanesDetails(simState *aviation.SimulationState) {
 				logFlightDetails(flight, simTime, f)
 			}
 		}
-		if len(plane.TCASEngagementRecords) == 0 {
+		engagements := simState.EngagementsOf(plane)
+		if len(engagements) == 0 {
 			fmt.Fprintln(f, "    No TCAS engagement recorded for this plane.")
 		} else {
-			for _, engagement := range plane.TCASEngagementRecords {
+			for _, engagement := range engagements {
 				logEngagementDetails(engagement, f)
 			}
 		}
+		// Every pair that came into TA range has an encounter, with its miss distances
+		fmt.Fprintln(f, "    --- Encounters ---")
+		for _, encounter := range encounters {
+			if encounter.Planes[0] == plane.Serial || encounter.Planes[1] == plane.Serial {
+				fmt.Fprintf(f, "    %s\n", encounterSummary(plane.Serial, encounter))
+			}
+		}
 		if plane.CurrentTCASEngagement == nil {
 			fmt.Fprintln(f, "    No current TCAS engagement recorded for this plane.")
 		} else {
+			fmt.Fprintln(f, "    --- Active Threats ---")
+			for _, threat := range plane.TCASThreats {
+				fmt.Fprintf(f, "    %s\n", threatSummary(plane.Serial, threat))
+			}
 			logEngagementDetails(*plane.CurrentTCASEngagement, f)
 		}
 
@@ -186,6 +205,13 @@ func logFlightDetails(flight aviation.Flight, simTime time.Time, f *os.File) {
 	fmt.Fprintf(f, "    Takeoff Time: %s\n", flight.TakeoffTime.Format("15:04:05"))
 	fmt.Fprintf(f, "    Destination Arrival Time: %s\n", flight.DestinationArrivalTime.Format("15:04:05"))
 	fmt.Fprintf(f, "    Cruising Altitude: %.2f meters\n", flight.CruisingAltitude)
+	if flight.ActualLandingTime.IsZero() && !simTime.Before(flight.TakeoffTime) {
+		fmt.Fprintf(f, "    Current Altitude: %.2f meters\n", flight.AltitudeAt(simTime))
+	}
+	for _, maneuver := range flight.VerticalProfile {
+		fmt.Fprintf(f, "    Manoeuvre at %s: %s from %.2f meters at %.2f m/s to %.2f meters\n", maneuver.Time.Format("15:04:05"),
+			maneuver.Reason, maneuver.Altitude, maneuver.VerticalRate, maneuver.TargetAltitude)
+	}
 	fmt.Fprintf(f, "    Depature Airport: %s\n", flight.DepatureAirPort)
 	fmt.Fprintf(f, "    Destination Airport: %s\n", flight.ArrivalAirPort)
 	var actualLandingTime string
@@ -218,4 +244,138 @@ func logEngagementDetails(engagement aviation.TCASEngagement, f *os.File) {
 			return "no"
 		}
 	}(engagement.WillCrash))
+	if engagement.Engaged {
+		fmt.Fprintf(f, "    Crew Responses: %s %s, %s %s\n", engagement.PlaneSerial, engagement.PlaneResponse,
+			engagement.OtherPlaneSerial, engagement.OtherPlaneResponse)
+		fmt.Fprintf(f, "    Current RAs: %s %s, %s %s\n", engagement.PlaneSerial, raName(engagement.PlaneRA),
+			engagement.OtherPlaneSerial, raName(engagement.OtherPlaneRA))
+		for _, transition := range engagement.Transitions {
+			fmt.Fprintf(f, "      %s RA of %s %s, %s to %s\n", transition.Time.Format("15:04:05.0"), transition.Plane,
+				transition.Kind, transition.From, transition.To)
+		}
+		if engagement.Miss != nil {
+			fmt.Fprintf(f, "    Miss Distance: %s\n", missSummary(*engagement.Miss))
+		}
+	}
+}
+
+// logEncounterDetails appends the lifecycle of every encounter between two planes to a log file,
+// in the order they started.
+func logEncounterDetails(simState *aviation.SimulationState) {
+	logFilePath := "logs/encounterDetails.txt"
+	// Open the file in append mode. Create it if it doesn't exist.
+	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	encounters := simState.EncounterList()
+	fmt.Fprintln(f, "\n--- Log of all encounters ---")
+	if len(encounters) == 0 {
+		fmt.Fprintln(f, "\n--- No encounter recorded currently ---")
+		return
+	}
+	for _, encounter := range encounters {
+		logEncounter(encounter, f)
+	}
+	fmt.Println("Successfully logged encounters")
+}
+
+// logEncounter appends the timestamps of an encounter from its first advisory to its outcome,
+// with the time between them, to a log file.
+func logEncounter(encounter aviation.Encounter, f *os.File) {
+	fmt.Fprintf(f, "  --- Encounter %s ---\n", encounter.ID)
+	fmt.Fprintf(f, "    Planes: %s, %s\n", encounter.Planes[0], encounter.Planes[1])
+	fmt.Fprintf(f, "    Outcome: %s\n", encounter.Outcome)
+	if !encounter.TAStart.IsZero() {
+		fmt.Fprintf(f, "    TA: %s\n", encounter.TAStart.Format("15:04:05.0"))
+	}
+	if !encounter.RAIssued.IsZero() {
+		fmt.Fprintf(f, "    RA: %s (engagement %s)", encounter.RAIssued.Format("15:04:05.0"), encounter.EngagementID)
+		if warning, ok := encounter.WarningTime(); ok {
+			fmt.Fprintf(f, ", %.1f s after the TA", warning.Seconds())
+		}
+		fmt.Fprintln(f)
+	}
+	for _, change := range encounter.RAChanges {
+		fmt.Fprintf(f, "      %s RA of %s %s, %s to %s\n", change.Time.Format("15:04:05.0"), change.Plane,
+			change.Kind, change.From, change.To)
+	}
+	fmt.Fprintf(f, "    Closest Approach: %s\n", missSummary(encounter.ClosestApproach))
+	if end, ok := encounter.End(); ok {
+		fmt.Fprintf(f, "    Ended: %s, after %.1f s\n", end.Format("15:04:05.0"), end.Sub(encounter.Start()).Seconds())
+	}
+}
+
+// logSTCADetails appends every alert of the ground Short-Term Conflict Alert to a log file, in the order they were
+// raised.
+func logSTCADetails(simState *aviation.SimulationState) {
+	logFilePath := "logs/stcaDetails.txt"
+	// Open the file in append mode. Create it if it doesn't exist.
+	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	alerts := simState.STCAAlertList()
+	fmt.Fprintln(f, "\n--- Log of all STCA alerts ---")
+	if len(alerts) == 0 {
+		fmt.Fprintln(f, "\n--- No STCA alert recorded currently ---")
+		return
+	}
+	for _, alert := range alerts {
+		logSTCAAlert(alert, f)
+	}
+	fmt.Println("Successfully logged STCA alerts")
+}
+
+// logSTCAAlert appends when an STCA alert was raised and cleared, what the ground predicted, and how it met
+// the RA of the planes, to a log file.
+func logSTCAAlert(alert aviation.STCAAlert, f *os.File) {
+	fmt.Fprintf(f, "  --- STCA Alert %s ---\n", alert.ID)
+	fmt.Fprintf(f, "    Planes: %s, %s\n", alert.Planes[0], alert.Planes[1])
+	fmt.Fprintf(f, "    Raised: %s\n", alert.Raised.Format("15:04:05.0"))
+	fmt.Fprintf(f, "    Predicted: %s\n", stcaPredictionSummary(alert))
+	fmt.Fprintf(f, "    RA: %s\n", stcaRASummary(alert))
+	if !alert.Active() {
+		fmt.Fprintf(f, "    Cleared: %s, after %.1f s\n", alert.Cleared.Format("15:04:05.0"), alert.Cleared.Sub(alert.Raised).Seconds())
+	}
+}
+
+// logConflictDetails appends the losses of separation predicted from the flight plans of the planes to
+// logs/conflictDetails.txt, the soonest first.
+func logConflictDetails(simState *aviation.SimulationState) {
+	logFilePath := "logs/conflictDetails.txt"
+	// Open the file in append mode. Create it if it doesn't exist.
+	f, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	simTime := simState.CurrentSimTime
+	conflicts := simState.PredictConflicts()
+	fmt.Fprintf(f, "\n--- Log of conflicts predicted at %s for the next %.0f minutes ---\n", simTime.Format("15:04:05.0"),
+		aviation.ConflictLookAhead.Minutes())
+	if len(conflicts) == 0 {
+		fmt.Fprintln(f, "\n--- No conflict predicted currently ---")
+		return
+	}
+	for _, conflict := range conflicts {
+		logConflict(conflict, simTime, f)
+	}
+	fmt.Println("Successfully logged predicted conflicts")
+}
+
+// logConflict appends which flights are predicted to lose separation, when, and how close they would come,
+// to a log file.
+func logConflict(conflict aviation.PredictedConflict, simTime time.Time, f *os.File) {
+	fmt.Fprintf(f, "  --- Conflict %s / %s ---\n", conflict.Planes[0], conflict.Planes[1])
+	fmt.Fprintf(f, "    Flights: %s, %s\n", conflict.Flights[0], conflict.Flights[1])
+	fmt.Fprintf(f, "    Separation Lost: %s\n", conflictStartSummary(conflict, simTime))
+	fmt.Fprintf(f, "    Closest Approach: %s, %.1f NM and %.0f ft apart\n", conflict.Closest.Format("15:04:05.0"),
+		conflict.HorizontalNM(), conflict.VerticalFeet())
+	fmt.Fprintf(f, "    Separation Regained: %s\n", conflict.End.Format("15:04:05.0"))
 }
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+)
+
+// pauseSimulation freezes the running simulation so an encounter can be examined.
+func pauseSimulation(simState *aviation.SimulationState) {
+	if err := simState.Pause(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Simulation paused, type 'resume' to continue or 'step <duration>' to advance it")
+}
+
+// resumeSimulation lets a paused simulation run again.
+func resumeSimulation(simState *aviation.SimulationState) {
+	if err := simState.Resume(); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("Simulation resumed")
+}
+
+// stepSimulation advances the simulation by the given duration and leaves it paused.
+// The duration is either a Go duration such as "500ms", "10s" or "2m", or a plain number of seconds.
+func stepSimulation(simState *aviation.SimulationState, argument2 string) {
+	if argument2 == "" {
+		fmt.Println("usage: step <duration>, e.g. step 10s, step 500ms, step 2m or step 5 (seconds)")
+		return
+	}
+
+	d, err := time.ParseDuration(argument2)
+	if err != nil {
+		seconds, convErr := strconv.ParseFloat(argument2, 64)
+		if convErr != nil {
+			fmt.Printf("invalid step duration %q, use e.g. 10s, 500ms or 2m\n", argument2)
+			return
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+
+	reached, err := simState.Step(d)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Simulation stepped to %s and paused\n", reached.Format("15:04:05"))
+}
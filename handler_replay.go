@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"github.com/josephus-git/TCAS-simulation-Fyne/graphics/ui"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// replaySimulation plays back a recorded simulation without running any of the aviation logic.
+// When the GUI is open the replay is shown in a new simulation window, otherwise its events are printed as they happen.
+func replaySimulation(cfg *config.Config, simState *aviation.SimulationState, gui *fyneGUI, argument2 string) {
+	if argument2 == "" {
+		fmt.Printf("usage: replay <file>, e.g. replay %s\n", aviation.ReplayFilePath)
+		return
+	}
+
+	replay, err := simState.LoadReplay(argument2)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	simState.SimSpeed = cfg.SimSpeed
+
+	// No window is rendering this replay yet, so no UI callbacks must be called
+	simState.OnPlaneTakeOffCallback = nil
+	simState.OnPlaneLandCallback = nil
+
+	if gui.app != nil {
+		fyne.Do(func() {
+			simulationWindow := gui.app.NewWindow("Airport Simulation Replay")
+			simulationWindow.SetOnClosed(func() {
+				if simState.SimIsRunning {
+					simState.EmergencyStop()
+				}
+			})
+			ui.GraphicsSimulationInit(simState, simulationWindow, gui.inputWindow)
+			simulationWindow.Show()
+			gui.inputWindow.Hide()
+		})
+	}
+
+	go aviation.RunReplay(simState, replay)
+
+	fmt.Printf("Replaying %s (%d recorded events, seed %d)\n", argument2, len(replay.Entries), replay.Header.Seed)
+}
@@ -21,25 +21,29 @@ import (
 	"github.com/josephus-git/TCAS-simulation-Fyne/internal/util"
 )
 
-// a holds the main Fyne application instance for the program.
-var a fyne.App
-
-// inputWindow holds the Fyne GUI window used for user input and controls.
-var inputWindow fyne.Window
+// fyneGUI holds the Fyne application and the window used for user input and controls.
+// They are created by the first 'run' and reused by the following ones,
+// since a Fyne application can only be started once per process.
+type fyneGUI struct {
+	app         fyne.App
+	inputWindow fyne.Window
+}
 
 // StartFyne initializes the Fyne GUI application, sets up the simulation input window with controls for configuration,
 // and manages the lifecycle of both the input and simulation display windows.
-func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
+func StartFyne(cfg *config.Config, simState *aviation.SimulationState, gui *fyneGUI) {
 	if cfg.FirstRun {
 		// Create a new Fyne application
-		a = app.NewWithID("tcas.app")
+		a := app.NewWithID("tcas.app")
 		a.Settings().SetTheme(ui.CustomDarkTheme{})
+		gui.app = a
 
 		// --- Initial Input Window ---
-		if inputWindow == nil {
-			inputWindow = a.NewWindow("TCAS Simulation Setup")
-			inputWindow.Resize(fyne.NewSize(400, 600)) // Smaller initial window
+		if gui.inputWindow == nil {
+			gui.inputWindow = a.NewWindow("TCAS Simulation Setup")
+			gui.inputWindow.Resize(fyne.NewSize(400, 600)) // Smaller initial window
 		}
+		inputWindow := gui.inputWindow
 
 		// A close interceptor for the main window
 		inputWindow.SetCloseIntercept(func() {
@@ -98,6 +102,28 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 		}
 		durationFormItem := widget.NewFormItem("Duration (minutes):", durationEntry)
 
+		// Input entry for the random seed, empty picks a new seed for every run
+		seedEntry := widget.NewEntry()
+		seedEntry.SetPlaceHolder("Random")
+		if cfg.Seed != 0 {
+			seedEntry.SetText(strconv.FormatInt(cfg.Seed, 10))
+		}
+		seedEntry.Validator = func(s string) error {
+			if s == "" {
+				return nil
+			}
+			if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+				return fmt.Errorf("please input a valid integer")
+			}
+			return nil
+		}
+		seedFormItem := widget.NewFormItem("Seed:", seedEntry)
+
+		// Select for the speed of the simulation clock
+		speedSelect := widget.NewSelect([]string{"1x", "10x", "100x", "max"}, func(s string) {})
+		speedSelect.SetSelected(aviation.SimSpeedString(cfg.SimSpeed))
+		speedFormItem := widget.NewFormItem("Speed:", speedSelect)
+
 		//  checkbox for Varying Altitude
 		varyingAltitudeCheckbox := widget.NewCheck("Yes", func(b bool) {})
 		varyingAltitudeCheckbox.SetChecked(simState.DifferentAltitudes)
@@ -107,6 +133,8 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 		inputForm := widget.NewForm(
 			numPlanesFormItem,
 			durationFormItem,
+			speedFormItem,
+			seedFormItem,
 			widget.NewFormItem("Varying Altitude:", varyingAltitudeCheckbox),
 		)
 
@@ -123,7 +151,7 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 				varyingAltitudeCheckbox.Show()
 				inputWindow.Show()
 				if simState.SimIsRunning {
-					aviation.EmergencyStop(simState)
+					simState.EmergencyStop()
 				}
 				aviation.CloseLogFiles(simState)
 			})
@@ -154,6 +182,25 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 				cfg.DifferentAltitudes = varyingAltitudeCheckbox.Checked
 			}
 
+			simSpeed, err := aviation.ParseSimSpeed(speedSelect.Selected)
+			if err != nil {
+				errorMessage.Text = "Please select a valid simulation speed"
+				errorMessage.Refresh()
+				return
+			}
+			cfg.SimSpeed = simSpeed
+
+			var seed int64
+			if seedEntry.Text != "" {
+				seed, err = strconv.ParseInt(seedEntry.Text, 10, 64)
+				if err != nil {
+					errorMessage.Text = "Please enter a valid seed or leave it empty for a random one"
+					errorMessage.Refresh()
+					return
+				}
+			}
+			cfg.Seed = seed
+
 			if simState.SimIsRunning {
 				errorMessage.Text = "Please wait a few seconds before restarting the simulation"
 				errorMessage.Refresh()
@@ -170,6 +217,9 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 			cfg.NoOfAirplanes = numAirPlanes
 			aviation.InitializeAirports(cfg, simState)
 
+			// The log files and the replay recording must be open before the simulation starts
+			aviation.OpenLogFiles(cfg, simState)
+
 			// run the simulation
 			go aviation.StartSimulation(simState, time.Duration(durationOfSimulation))
 
@@ -180,10 +230,9 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 
 			simulationWindow.Show()
 			inputWindow.Hide()
-			aviation.OpenLogFiles(cfg, simState)
 
 			simState.SimWindowOpened = true
-			log.Printf("Starting simulation with %d airplanes.", numAirPlanes)
+			log.Printf("Starting simulation with %d airplanes (seed %d).", numAirPlanes, simState.Seed)
 		})
 
 		// Set content
@@ -209,17 +258,17 @@ func StartFyne(cfg *config.Config, simState *aviation.SimulationState) {
 		// Show input window
 		inputWindow.Show()
 
-		go startPartition(cfg, simState)
+		go startPartition(cfg, simState, gui)
 		a.Run()
 
 	} else {
-		fyne.Do(func() { inputWindow.Show() })
+		fyne.Do(func() { gui.inputWindow.Show() })
 	}
 
 }
 
 // startPartition handles the command-line interface for the TCAS simulator, processing user input for various commands.
-func startPartition(cfg *config.Config, simState *aviation.SimulationState) {
+func startPartition(cfg *config.Config, simState *aviation.SimulationState, gui *fyneGUI) {
 	cfg.FirstRun = false
 	scanner := bufio.NewScanner(os.Stdin)
 
@@ -237,7 +286,7 @@ func startPartition(cfg *config.Config, simState *aviation.SimulationState) {
 			continue
 		}
 
-		cmd, ok := getCommand(cfg, simState, argument2)[input[0]]
+		cmd, ok := getCommand(cfg, simState, gui, argument2)[input[0]]
 		if !ok {
 			fmt.Println("Unknown command, type <help> for usage")
 			continue
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// runHeadless initializes and starts a simulation without opening any Fyne window,
+// so it can run on build servers or over SSH where no display is available.
+// The simulation runs in the background, leaving the command line free for commands such as 'get' and 'q'.
+func runHeadless(cfg *config.Config, simState *aviation.SimulationState) {
+	if simState.SimIsRunning {
+		fmt.Println("A simulation is already running, type 'q' to stop it before starting another one")
+		return
+	}
+
+	durationOfSimulation := aviation.GetSimulationDuration()
+
+	simState.Airports = []*aviation.Airport{}
+	simState.PlanesInFlight = []*aviation.Plane{}
+
+	// No window is rendering this simulation, so no UI callbacks must be called
+	simState.OnPlaneTakeOffCallback = nil
+	simState.OnPlaneLandCallback = nil
+
+	aviation.InitializeAirports(cfg, simState)
+	aviation.OpenLogFiles(cfg, simState)
+
+	go func() {
+		aviation.StartSimulation(simState, time.Duration(durationOfSimulation))
+		aviation.CloseLogFiles(simState)
+	}()
+
+	fmt.Printf("Starting headless simulation with %d airplanes.\n", cfg.NoOfAirplanes)
+}
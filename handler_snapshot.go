@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"github.com/josephus-git/TCAS-simulation-Fyne/graphics/ui"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// saveSnapshot saves the whole state of the running simulation to a file so it can be loaded again later.
+func saveSnapshot(simState *aviation.SimulationState, argument2 string) {
+	if argument2 == "" {
+		fmt.Println("usage: save <file>, e.g. save logs/crossing.json")
+		return
+	}
+
+	if err := simState.SaveSnapshot(argument2); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Simulation saved to %s at %s\n", argument2, simState.CurrentSimTime.Format("15:04:05"))
+}
+
+// loadSnapshot restores a simulation saved with 'save' and carries it on, paused, from the moment it was saved.
+// When the GUI is open the loaded simulation is shown in a new simulation window, otherwise it runs headless.
+func loadSnapshot(cfg *config.Config, simState *aviation.SimulationState, gui *fyneGUI, argument2 string) {
+	if argument2 == "" {
+		fmt.Println("usage: load <file>, e.g. load logs/crossing.json")
+		return
+	}
+
+	if err := simState.LoadSnapshot(argument2); err != nil {
+		fmt.Println(err)
+		return
+	}
+	simState.SimSpeed = cfg.SimSpeed
+
+	// No window is rendering this simulation yet, so no UI callbacks must be called
+	simState.OnPlaneTakeOffCallback = nil
+	simState.OnPlaneLandCallback = nil
+
+	aviation.OpenLogFiles(cfg, simState)
+	go func() {
+		aviation.StartLoadedSimulation(simState)
+		aviation.CloseLogFiles(simState)
+	}()
+
+	if gui.app != nil {
+		fyne.Do(func() {
+			simulationWindow := gui.app.NewWindow("Airport Simulation")
+			simulationWindow.SetOnClosed(func() {
+				if simState.SimIsRunning {
+					simState.EmergencyStop()
+				}
+			})
+			ui.GraphicsSimulationInit(simState, simulationWindow, gui.inputWindow)
+			simulationWindow.Show()
+			gui.inputWindow.Hide()
+		})
+	}
+
+	fmt.Printf("Simulation loaded from %s at %s, paused: type 'resume' to carry it on\n",
+		argument2, simState.CurrentSimTime.Format("15:04:05"))
+}
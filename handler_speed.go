@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/aviation"
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// setSimSpeed changes the speed of the simulation clock.
+// The new speed applies immediately to a running simulation and to every simulation started afterwards.
+func setSimSpeed(cfg *config.Config, simState *aviation.SimulationState, argument2 string) {
+	if argument2 == "" {
+		fmt.Printf("Current simulation speed: %s\n", aviation.SimSpeedString(cfg.SimSpeed))
+		fmt.Println("usage: speed <factor>, e.g. speed 1, speed 10, speed 100, speed max")
+		return
+	}
+
+	speed, err := aviation.ParseSimSpeed(argument2)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	cfg.SimSpeed = speed
+	simState.SimSpeed = speed
+	if simState.SimIsRunning && simState.Clock != nil {
+		simState.Clock.SetSpeed(speed)
+	}
+	fmt.Printf("Simulation speed set to %s\n", aviation.SimSpeedString(speed))
+}
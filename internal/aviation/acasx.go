@@ -0,0 +1,259 @@
+package aviation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// ACASXa is the name the lookup table logic is registered under, see TableLogic
+const ACASXa = "acasx"
+
+// DefaultCostTablePath is where the cost table of the lookup table logic is read from when none is given
+const DefaultCostTablePath = "assets/acasx_costs.json"
+
+// CostTableVersion is the version of the cost table file format, it is increased whenever the format changes.
+const CostTableVersion = 1
+
+// Advisories of the cost table, the actions it scores and the previous advisory it is indexed by
+const (
+	tableCOC     = iota // clear of conflict, no RA
+	tableClimb          // climb RA
+	tableDescend        // descend RA
+	tableActionCount
+)
+
+// CostTable is the optimised table of an ACAS X style logic: the expected cost of each advisory in every state of an
+// encounter, computed offline. A state is the altitude of the intruder relative to the own plane, the vertical rates
+// of both, the time to the closest approach (tau) and the advisory given before. The grids are in real units;
+// between their points the costs are interpolated, and outside them the intruder is no threat.
+// The file is JSON, see cmd/acasx-table for how the shipped table is built.
+type CostTable struct {
+	Version          int       `json:"version"`
+	RelativeAltitude []float64 `json:"relative_altitude"` // feet, positive when the intruder is above
+	OwnRate          []float64 `json:"own_rate"`          // ft/min
+	IntruderRate     []float64 `json:"intruder_rate"`     // ft/min
+	Tau              []float64 `json:"tau"`               // seconds
+	HorizontalMiss   float64   `json:"horizontal_miss"`   // nautical miles, an intruder predicted to pass further away is no threat
+	TAMargin         float64   `json:"ta_margin"`         // a TA is issued when an RA costs at most this much more than none
+	ClearMargin      float64   `json:"clear_margin"`      // an RA ends once clear of conflict costs at most this much
+	// Costs holds the cost of every advisory (clear of conflict, climb, descend) in every state, indexed by relative
+	// altitude, own rate, intruder rate, tau, previous advisory and advisory, the last changing fastest
+	Costs []float64 `json:"costs"`
+}
+
+// LoadCostTable reads a cost table file and checks that its grids and costs fit together.
+func LoadCostTable(path string) (*CostTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cost table: %w", err)
+	}
+	var table CostTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("failed to decode cost table %s: %w", path, err)
+	}
+	if table.Version != CostTableVersion {
+		return nil, fmt.Errorf("cost table %s has version %d, this simulator reads version %d", path, table.Version, CostTableVersion)
+	}
+	size := tableActionCount * tableActionCount
+	for _, grid := range [][]float64{table.RelativeAltitude, table.OwnRate, table.IntruderRate, table.Tau} {
+		if len(grid) < 2 {
+			return nil, fmt.Errorf("cost table %s has a grid of less than two points", path)
+		}
+		for i := 1; i < len(grid); i++ {
+			if grid[i] <= grid[i-1] {
+				return nil, fmt.Errorf("cost table %s has a grid that is not increasing: %v", path, grid)
+			}
+		}
+		size *= len(grid)
+	}
+	if len(table.Costs) != size {
+		return nil, fmt.Errorf("cost table %s has %d costs, its grids need %d", path, len(table.Costs), size)
+	}
+	return &table, nil
+}
+
+// NewCostTable reads the cost table of the lookup table logic from the path of the configuration, or
+// DefaultCostTablePath. It returns nil without reading anything when no plane of the avoidance mix flies with it.
+// Reading it before a simulation starts reports a bad file at once, instead of when the first plane flying with it
+// is checked.
+func NewCostTable(conf *config.Config) (*CostTable, error) {
+	if _, ok := conf.AvoidanceMix[ACASXa]; !ok {
+		return nil, nil
+	}
+	path := conf.CostTablePath
+	if path == "" {
+		path = DefaultCostTablePath
+	}
+	return LoadCostTable(path)
+}
+
+func init() {
+	RegisterCollisionAvoidance(ACASXa, func(simState *SimulationState) CollisionAvoidance {
+		if simState.CostTable == nil {
+			// Without its table the plane falls back on TCAS II rather than flying without any logic,
+			// InitializeAirports has said so once already
+			return ThresholdLogic{Vertical: simState.VerticalThresholds}
+		}
+		return TableLogic{Table: simState.CostTable}
+	})
+}
+
+// gridWeights returns the index of the grid point at or below x and the weight of the point above it, for the linear
+// interpolation between them. Values outside the grid are taken at its ends.
+func gridWeights(grid []float64, x float64) (int, float64) {
+	if x <= grid[0] {
+		return 0, 0
+	}
+	last := len(grid) - 1
+	if x >= grid[last] {
+		return last - 1, 1
+	}
+	i := 0
+	for grid[i+1] < x {
+		i++
+	}
+	return i, (x - grid[i]) / (grid[i+1] - grid[i])
+}
+
+// lookup returns the cost of every advisory in a state, interpolated between the 16 grid points around it.
+func (t *CostTable) lookup(relativeAltitude, ownRate, intruderRate, tau float64, previous int) [tableActionCount]float64 {
+	grids := [4][]float64{t.RelativeAltitude, t.OwnRate, t.IntruderRate, t.Tau}
+	values := [4]float64{relativeAltitude, ownRate, intruderRate, tau}
+	var index [4]int
+	var weight [4]float64
+	for d := range grids {
+		index[d], weight[d] = gridWeights(grids[d], values[d])
+	}
+
+	var costs [tableActionCount]float64
+	for corner := 0; corner < 16; corner++ {
+		offset, w := 0, 1.0
+		for d := range grids {
+			i := index[d]
+			if corner&(1<<d) != 0 {
+				i++
+				w *= weight[d]
+			} else {
+				w *= 1 - weight[d]
+			}
+			offset = offset*len(grids[d]) + i
+		}
+		if w == 0 {
+			continue
+		}
+		offset = (offset*tableActionCount + previous) * tableActionCount
+		for a := range costs {
+			costs[a] += w * t.Costs[offset+a]
+		}
+	}
+	return costs
+}
+
+// tableAdvisory returns the advisory of the cost table standing for the RA own flies against intruder, see RAClimb.
+// A level off RA weakens the RA that took own away from the intruder, so it stands for a climb when the intruder is
+// below and a descend otherwise.
+func tableAdvisory(own, intruder Aircraft) int {
+	switch {
+	case own.RA == RALevelOff && intruder.Track.Altitude < own.Track.Altitude:
+		return tableClimb
+	case own.RA == RALevelOff:
+		return tableDescend
+	}
+	switch raVerticalSense(own.RA) {
+	case 1:
+		return tableClimb
+	case -1:
+		return tableDescend
+	default:
+		return tableCOC
+	}
+}
+
+// TableLogic is a collision avoidance logic in the style of ACAS Xa: instead of fixed thresholds, each advisory is
+// chosen from a cost table optimised offline, as the one of least expected cost in the current state of the
+// encounter. The previous advisory is part of the state, so the table itself decides how readily an RA is issued,
+// kept or ended.
+type TableLogic struct {
+	Table *CostTable
+}
+
+// Name returns the name TableLogic is registered under.
+func (l TableLogic) Name() string {
+	return ACASXa
+}
+
+// costs returns the cost of every advisory of own against intruder, ok is false when the intruder is no threat:
+// moving away, predicted to pass further than the horizontal miss distance of the table, or outside its altitude
+// and tau grids.
+func (l TableLogic) costs(own, intruder Aircraft, previous int) (costs [tableActionCount]float64, ok bool) {
+	timeToCPA, missDistance := closestApproach(own.Track, intruder.Track)
+	if timeToCPA <= 0 || missDistance > l.Table.HorizontalMiss*NauticalMileToMeters*tcasRangeScale {
+		return costs, false
+	}
+	tau := timeToCPA / TCASTimeScale
+	relativeAltitude := (intruder.Track.Altitude - own.Track.Altitude) / FeetToMeters
+	altitudes := l.Table.RelativeAltitude
+	if tau > l.Table.Tau[len(l.Table.Tau)-1] || relativeAltitude < altitudes[0] || relativeAltitude > altitudes[len(altitudes)-1] {
+		return costs, false
+	}
+	ownRate, intruderRate := verticalSpeedFPM(own.Track.VerticalRate), verticalSpeedFPM(intruder.Track.VerticalRate)
+	return l.Table.lookup(relativeAltitude, ownRate, intruderRate, tau, previous), true
+}
+
+// best returns the advisory of least cost, the previous one on a tie.
+func best(costs [tableActionCount]float64, previous int) int {
+	chosen := previous
+	for a := range costs {
+		if costs[a] < costs[chosen] {
+			chosen = a
+		}
+	}
+	return chosen
+}
+
+// Advise returns an RA when climbing or descending costs less than carrying on, given the RA the plane flies against
+// the intruder, and a TA when an RA costs at most the TA margin more.
+func (l TableLogic) Advise(own, intruder Aircraft) Advisory {
+	previous := tableAdvisory(own, intruder)
+	costs, ok := l.costs(own, intruder, previous)
+	if !ok {
+		return AdvisoryNone
+	}
+	switch {
+	case best(costs, previous) != tableCOC && own.GetsRA:
+		return AdvisoryRA
+	case math.Min(costs[tableClimb], costs[tableDescend])-costs[tableCOC] <= l.Table.TAMargin:
+		return AdvisoryTA
+	default:
+		return AdvisoryNone
+	}
+}
+
+// RAContinues reports whether an RA goes on, given the RA flown: until the intruder is no longer a threat, or the table
+// prefers clear of conflict and it costs at most the clear of conflict margin, so the separation is assured once the
+// crews stop manoeuvring.
+func (l TableLogic) RAContinues(own, intruder Aircraft) bool {
+	previous := tableAdvisory(own, intruder)
+	costs, ok := l.costs(own, intruder, previous)
+	return ok && (best(costs, previous) != tableCOC || costs[tableCOC] > l.Table.ClearMargin)
+}
+
+// Climb reports whether climbing costs less than descending, the lower serial climbing on a tie as in TCAS II.
+// A plane below DescendInhibitAltitude always climbs.
+func (l TableLogic) Climb(own, intruder Aircraft) bool {
+	if own.Track.Altitude < DescendInhibitAltitude*FeetToMeters {
+		return true
+	}
+	costs, ok := l.costs(own, intruder, tableCOC)
+	if !ok {
+		return selectClimbingPlane(own, intruder) == own.Serial
+	}
+	if costs[tableClimb] == costs[tableDescend] {
+		return own.Serial < intruder.Serial
+	}
+	return costs[tableClimb] < costs[tableDescend]
+}
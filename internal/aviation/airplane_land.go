@@ -1,6 +1,7 @@
 package aviation
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -18,21 +19,22 @@ const (
 
 )
 
-// Land handles the process of a plane landing at an airport.
-// It verifies the plane's intended destination, strictly manages runway availability,
-// simulates the landing process, and updates the plane's and the global simulation's states.
+// errRunwayInUse is returned by Land while a runway of the airport is in use.
+var errRunwayInUse = errors.New("runway in use")
+
+// Land starts the landing of a plane at an airport.
+// It verifies the plane's intended destination, strictly manages runway availability
+// and schedules the end of the landing, at which point completeLanding parks the plane.
 //
 // Parameters:
 //
-//	plane: The Plane struct that is attempting to land. This is passed by value;
-//	       its modifications will be reflected when it's re-added to the airport's list.
-//	simState: A pointer to the global SimulationState, necessary for removing the plane
-//	          from the `PlanesInFlight` list.
+//	plane: The Plane that is attempting to land.
+//	simState: A pointer to the global SimulationState, used to schedule the end of the landing.
 //
 // Returns:
 //
-//	error: An error if the landing cannot proceed (e.g., wrong destination,
-//	       runways are currently in use, or the plane is not found in flight).
+//	error: errRunwayInUse if a runway is in use and the caller should retry later,
+//	       or an error if the landing cannot proceed at all (e.g., wrong destination).
 func (ap *Airport) Land(plane *Plane, simState *SimulationState) error {
 	f := simState.ConsoleLog
 	log.Printf("Plane %s is attempting to land at Airport %s (%s).\n\n",
@@ -40,49 +42,66 @@ func (ap *Airport) Land(plane *Plane, simState *SimulationState) error {
 	fmt.Fprintf(f, "%s Plane %s is attempting to land at Airport %s (%s).\n\n",
 		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, ap.Serial, ap.Location.String())
 
-	// first we run a loop to make sure a plane is not trying to land in an airport where
-	// another airplane is trying to take off
-	for i := 0; ap.Runway.noOfRunwayinUse > 0 && simState.SimIsRunning; i++ {
+	// Retrieve the current flight details from the plane's log.
+	if len(plane.FlightLog) == 0 {
+		return fmt.Errorf("plane %s has no flight history; cannot initiate landing", plane.Serial)
+	}
+	// Get the most recent flight from the log.
+	currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
+
+	// Verify that this airport is the plane's intended destination.
+	// We use the 'distance' function with an Epsilon to account for floating-point inaccuracies.
+	if Distance(ap.Location, currentFlight.FlightSchedule.Destination) > Epsilon {
+		return fmt.Errorf("plane %s attempting to land at airport %s (%s), but its destination for current flight %s is %s",
+			plane.Serial, ap.Serial, ap.Location.String(), currentFlight.FlightID, currentFlight.FlightSchedule.Destination.String())
+	}
+
+	// A plane is not allowed to land in an airport where another airplane is using a runway
+	ap.Mu.Lock()
+	if ap.Runway.noOfRunwayinUse > 0 {
+		ap.Mu.Unlock()
 		log.Printf("\nairport %s has %d runway(s) currently in use; plane %s cannot land until all runways are free\n\n",
 			ap.Serial, ap.Runway.noOfRunwayinUse, plane.Serial)
 		fmt.Fprintf(f, "%s\nairport %s has %d runway(s) currently in use; plane %s cannot land until all runways are free\n\n",
 			simState.CurrentSimTime.Format("2006-01-02 15:04:05"), ap.Serial, ap.Runway.noOfRunwayinUse, plane.Serial)
-		time.Sleep(TakeoffDuration)
+		return errRunwayInUse
 	}
-	log.Printf("Plane %s is now landing at Airport %s (%s).\n\n",
-		plane.Serial, ap.Serial, ap.Location.String())
-	fmt.Fprintf(f, "%sPlane %s is now landing at Airport %s (%s).\n\n",
-		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, ap.Serial, ap.Location.String())
 
 	// Mark a runway as in use for the landing.
 	// This lock the runway so no plane can take off for the landing duration
-
-	ap.Mu.Lock()
 	ap.Runway.noOfRunwayinUse++
 	ap.ReceivingPlane = true
 	ap.Mu.Unlock()
-	defer func() {
-		ap.Mu.Lock()
-		ap.ReceivingPlane = false
-		ap.Mu.Unlock()
-	}()
-	time.Sleep(LandingDuration)
 
-	// Retrieve the current flight details from the plane's log.
-	if len(plane.FlightLog) == 0 {
-		return fmt.Errorf("plane %s has no flight history; cannot initiate landing", plane.Serial)
-	}
-	// Get the most recent flight from the log.
-	currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
+	log.Printf("Plane %s is now landing at Airport %s (%s).\n\n",
+		plane.Serial, ap.Serial, ap.Location.String())
+	fmt.Fprintf(f, "%sPlane %s is now landing at Airport %s (%s).\n\n",
+		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, ap.Serial, ap.Location.String())
 
+	simState.Mu.Lock()
 	plane.FlightLog[len(plane.FlightLog)-1].FlightStatus = "about to land"
+	simState.Mu.Unlock()
 
-	// Verify that this airport is the plane's intended destination.
-	// We use the 'distance' function with an Epsilon to account for floating-point inaccuracies.
-	if Distance(ap.Location, currentFlight.FlightSchedule.Destination) > Epsilon {
-		return fmt.Errorf("plane %s attempting to land at airport %s (%s), but its destination for current flight %s is %s",
-			plane.Serial, ap.Serial, ap.Location.String(), currentFlight.FlightID, currentFlight.FlightSchedule.Destination.String())
-	}
+	simState.record(ReplayEntry{Kind: ReplayLanding, Airport: ap.Serial, Plane: plane.Serial})
+
+	simState.scheduler.Schedule(&Event{
+		Time:    simState.CurrentSimTime.Add(LandingDuration),
+		Kind:    EventLandingComplete,
+		Airport: ap,
+		Plane:   plane,
+	})
+
+	return nil
+}
+
+// completeLanding ends the landing of a plane: it releases the runway, removes the plane
+// from the planes in flight and parks it at the airport.
+//
+// Returns:
+//
+//	error: An error if the plane is not found in the global PlanesInFlight list.
+func (ap *Airport) completeLanding(plane *Plane, simState *SimulationState) error {
+	f := simState.ConsoleLog
 
 	// Acquire the airport's mutex lock. This protects the runway state and other
 	// airport-specific shared resources during the critical landing operation.
@@ -91,6 +110,7 @@ func (ap *Airport) Land(plane *Plane, simState *SimulationState) error {
 
 	// Release the runway after the landing is complete.
 	ap.Runway.noOfRunwayinUse--
+	ap.ReceivingPlane = false
 
 	// Remove the plane from the global `simState.PlanesInFlight` list.
 	simState.Mu.Lock()
@@ -106,16 +126,21 @@ func (ap *Airport) Land(plane *Plane, simState *SimulationState) error {
 		return fmt.Errorf("plane %s not found in the global PlanesInFlight list", plane.Serial)
 	}
 	simState.PlanesInFlight = append(simState.PlanesInFlight[:planeInFlightIndex], simState.PlanesInFlight[planeInFlightIndex+1:]...)
-	simState.Mu.Unlock()
 
 	// Update the plane's status to reflect it's no longer in flight.
 	plane.PlaneInFlight = false
+	// Its TCAS no longer tracks any intruder
+	plane.CurrentTCASEngagement = nil
+	plane.TCASThreats = nil
 
 	plane.FlightLog[len(plane.FlightLog)-1].FlightStatus = "landed"
 	plane.FlightLog[len(plane.FlightLog)-1].ActualLandingTime = simState.CurrentSimTime
+	simState.Mu.Unlock()
 
 	// Add the now-landed plane to the destination airport's list of parked planes.
-	ap.Planes = append(ap.Planes, plane) // Append the updated copy of the plane
+	ap.Planes = append(ap.Planes, plane)
+
+	simState.record(ReplayEntry{Kind: ReplayLanded, Airport: ap.Serial, Plane: plane.Serial})
 
 	log.Printf("Plane %s successfully landed at Airport %s (%s). It is now parked.\n\n",
 		plane.Serial, ap.Serial, ap.Location.String())
@@ -1,9 +1,9 @@
 package aviation
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
 	"time"
 
 	"fyne.io/fyne/v2"
@@ -29,81 +29,113 @@ func (airport *Airport) getRandomDestinationAirport(allAirports []*Airport) (*Ai
 		return nil, fmt.Errorf("no other airports available to serve as a destination")
 	}
 
-	// Use a new random source to ensure sufficient randomness, especially if called frequently.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	randomIndex := r.Intn(len(eligibleAirports))
+	// Use the airport's own random source so the destinations follow from the simulation seed.
+	randomIndex := airport.rng.Intn(len(eligibleAirports))
 	return eligibleAirports[randomIndex], nil
 }
 
-// TakeOff prepares a plane for flight, simulates its takeoff, and updates the simulation state.
-// It handles runway allocation, flight path generation, and state transitions for the plane and airport.
+// findAirport returns the airport with the given serial, nil if there is none.
+func findAirport(airports []*Airport, serial string) *Airport {
+	for _, ap := range airports {
+		if ap.Serial == serial {
+			return ap
+		}
+	}
+	return nil
+}
+
+// errAirportReceivingPlane is returned by TakeOff while a plane is landing at the airport.
+var errAirportReceivingPlane = errors.New("airport is currently receiving a landing plane")
+
+// errNoRunwayAvailable is returned by TakeOff while every runway of the airport is in use.
+var errNoRunwayAvailable = errors.New("no runway available for takeoff")
+
+// TakeOff starts the takeoff of a plane, it handles runway allocation and schedules the end of the takeoff roll.
+// The flight itself is generated by completeTakeoff once the takeoff roll is over.
 //
 // Parameters:
 //
-//	plane: The Plane struct that is taking off. Note that this is passed by value
-//	       the modifications to this copy are then reflected when its added to simState.PlanesInFlight.
-//	simState: A pointer to the global SimulationState, allowing updates to the list of planes in flight.
+//	plane: The Plane that is taking off, it must be parked at this airport.
+//	simState: A pointer to the global SimulationState, used to schedule the end of the takeoff.
 //
 // Returns:
 //
-//	*Flight: A pointer to the newly created Flight struct representing this takeoff.
-//	error: An error if the takeoff cannot be initiated (e.g., no available runways, plane not found).
-func (airport *Airport) TakeOff(plane *Plane, simState *SimulationState) (*Flight, error) {
+//	error: errAirportReceivingPlane or errNoRunwayAvailable if the takeoff cannot start now,
+//	       the caller is expected to retry later.
+func (airport *Airport) TakeOff(plane *Plane, simState *SimulationState) error {
 	f := simState.ConsoleLog
 	log.Printf("Plane %s (Cruise Speed: %.2fm/s) is attempting to takeoff from Airport %s %s\n\n",
 		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) is attempting to takeoff from Airport %s %s\n\n",
 		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 
-	for {
-		airport.Mu.Lock()
-		receiving := airport.ReceivingPlane
+	airport.Mu.Lock()
+	if airport.ReceivingPlane {
 		airport.Mu.Unlock()
-
-		if !receiving || !simState.SimIsRunning {
-			break
-		}
 		log.Printf("\nairport %s is currently receiving a landing plane; plane %s cannot takeoff until all landing operations are over\n\n",
 			airport.Serial, plane.Serial)
 		fmt.Fprintf(f, "%s \nairport %s is currently receiving a landing plane; plane %s cannot takeoff until all landing operations are over\n\n",
 			simState.CurrentSimTime.Format("2006-01-02 15:04:05"), airport.Serial, plane.Serial)
-		time.Sleep(LandingDuration)
+		return errAirportReceivingPlane
 	}
 
-	for {
-		// Check if there's an available runway.
-		airport.Mu.Lock()
-		if airport.Runway.noOfRunwayinUse >= airport.Runway.numberOfRunway {
-			airport.Mu.Unlock() // Release lock immediately if no runway available
-			log.Printf("\nairport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
-				airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
-			fmt.Fprintf(f, "%s \nairport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
-				simState.CurrentSimTime.Format("2006-01-02 15:04:05"), airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
-			time.Sleep(TakeoffDuration)
-		} else {
-			airport.Mu.Unlock()
-			break
-		}
+	// Check if there's an available runway.
+	if airport.Runway.noOfRunwayinUse >= airport.Runway.numberOfRunway {
+		airport.Mu.Unlock()
+		log.Printf("\nairport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
+			airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
+		fmt.Fprintf(f, "%s \nairport %s has no available runways for takeoff (all %d of %d runway(s) in use)\n\n",
+			simState.CurrentSimTime.Format("2006-01-02 15:04:05"), airport.Serial, airport.Runway.noOfRunwayinUse, airport.Runway.numberOfRunway)
+		return errNoRunwayAvailable
 	}
-	airport.Mu.Lock()
-	// Mark a runway as in use.
+
+	// Mark a runway as in use, it is released when the takeoff completes.
 	airport.Runway.noOfRunwayinUse++
-	airport.Mu.Unlock() // Release the lock BEFORE the takeoff duration sleep
+	airport.Mu.Unlock()
+
+	// The flight is planned as the roll starts, so the conflict probe knows of it before the plane is airborne.
+	flight, _, err := airport.planDeparture(plane, simState, simState.CurrentSimTime.Add(TakeoffDuration))
+	if err != nil {
+		airport.Mu.Lock()
+		airport.Runway.noOfRunwayinUse--
+		airport.Mu.Unlock()
+		return err
+	}
+	simState.Mu.Lock()
+	plane.PlannedFlight = &flight
+	simState.Mu.Unlock()
 
-	// Simulate the physical takeoff duration. This does NOT hold the lock.
-	// This allows other planes to acquire the lock and potentially start taking off
-	// on another available runway immediately.
 	log.Printf("Plane %s (Cruise Speed: %.2fm/s) is taking off from Airport %s %s\n\n",
 		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) is taking off from Airport %s %s\n\n",
 		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String())
 
-	time.Sleep(TakeoffDuration)
+	simState.record(ReplayEntry{Kind: ReplayTakeoff, Airport: airport.Serial, Plane: plane.Serial, Flight: &flight})
+
+	// The physical takeoff lasts TakeoffDuration, other planes can use the remaining runways meanwhile.
+	simState.scheduler.Schedule(&Event{
+		Time:    simState.CurrentSimTime.Add(TakeoffDuration),
+		Kind:    EventTakeoffComplete,
+		Airport: airport,
+		Plane:   plane,
+	})
+
+	return nil
+}
+
+// completeTakeoff ends the takeoff roll of a plane: it releases the runway, starts the flight planned by TakeOff
+// and schedules the landing request at the plane's destination.
+//
+// Returns:
+//
+//	*Flight: A pointer to the newly created Flight struct representing this takeoff.
+//	error: An error if the flight cannot be created (e.g., plane not found, no destination available).
+func (airport *Airport) completeTakeoff(plane *Plane, simState *SimulationState) (*Flight, error) {
+	f := simState.ConsoleLog
 
-	// After the takeoff duration, re-acquire the lock to safely decrement the counter.
+	// Release the runway used for the takeoff.
 	airport.Mu.Lock()
 	airport.Runway.noOfRunwayinUse--
-	airport.Mu.Unlock()
 
 	// Find and remove the plane from this airport's list of parked planes.
 	planeIndex := -1
@@ -115,16 +147,74 @@ func (airport *Airport) TakeOff(plane *Plane, simState *SimulationState) (*Fligh
 	}
 
 	if planeIndex == -1 {
+		airport.Mu.Unlock()
 		return nil, fmt.Errorf("plane %s not found at airport %s to initiate takeoff", plane.Serial, airport.Serial)
 	}
 
 	// Remove the plane from the airport's Planes slice.
 	airport.Planes = append(airport.Planes[:planeIndex], airport.Planes[planeIndex+1:]...)
+	airport.Mu.Unlock()
+
+	// The flight was planned as the roll started, a plane restored from an older snapshot plans it now.
+	simState.Mu.Lock()
+	planned := plane.PlannedFlight
+	plane.PlannedFlight = nil
+	simState.Mu.Unlock()
+	var newFlight Flight
+	var destinationAirport *Airport
+	if planned != nil {
+		newFlight, destinationAirport = *planned, findAirport(simState.Airports, planned.ArrivalAirPort)
+	}
+	if destinationAirport == nil {
+		var err error
+		newFlight, destinationAirport, err = airport.planDeparture(plane, simState, simState.CurrentSimTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+	landingTime := newFlight.DestinationArrivalTime
+
+	// Update the plane's internal state to reflect it's now in flight, and add it
+	// to the global list of planes currently in flight.
+	simState.Mu.Lock()
+	plane.PlaneInFlight = true
+	plane.FlightLog = append(plane.FlightLog, newFlight)
+	simState.PlanesInFlight = append(simState.PlanesInFlight, plane)
+	simState.FlightCount++
+	simState.Mu.Unlock()
+
+	simState.record(ReplayEntry{Kind: ReplayFlight, Airport: airport.Serial, Plane: plane.Serial, Flight: &newFlight})
+
+	// The plane asks for a runway at its destination as soon as it arrives there.
+	simState.scheduler.Schedule(&Event{
+		Time:    landingTime,
+		Kind:    EventLandingRequest,
+		Airport: destinationAirport,
+		Plane:   plane,
+	})
+
+	log.Printf("Plane %s (Cruise Speed: %.2fm/s) took off from Airport %s %s, heading to Airport %s %s. Estimated landing at %s.\n\n",
+		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format("15:04:05"))
+	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) took off from Airport %s %s, heading to Airport %s %s. Estimated landing at %s.\n\n",
+		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format("15:04:05"))
+
+	// Call the UI callback if registered
+	if simState.OnPlaneTakeOffCallback != nil {
+		fyne.Do(func() { // Ensure UI updates are on main goroutine
+			simState.OnPlaneTakeOffCallback(plane)
+		})
+	}
+
+	return &newFlight, nil
+}
 
+// planDeparture draws the destination and cruising altitude of the next flight of a plane parked at the airport,
+// and returns its flight plan for a takeoff at takeoffTime with the airport it flies to.
+func (airport *Airport) planDeparture(plane *Plane, simState *SimulationState, takeoffTime time.Time) (Flight, *Airport, error) {
 	// Select a random destination airport for the plane.
 	destinationAirport, err := airport.getRandomDestinationAirport(simState.Airports)
 	if err != nil {
-		return nil, fmt.Errorf("failed to select destination airport for plane %s: %w", plane.Serial, err)
+		return Flight{}, nil, fmt.Errorf("failed to select destination airport for plane %s: %w", plane.Serial, err)
 	}
 
 	// Define the flight path from the current airport to the destination.
@@ -136,16 +226,15 @@ func (airport *Airport) TakeOff(plane *Plane, simState *SimulationState) (*Fligh
 	// Calculate the total distance and estimated flight duration.
 	flightDistance := Distance(flightPath.Depature, flightPath.Destination)
 	if plane.CruiseSpeed <= 0 {
-		return nil, fmt.Errorf("plane %s has an invalid cruise speed (%.2f), cannot calculate flight duration", plane.Serial, plane.CruiseSpeed)
+		return Flight{}, nil, fmt.Errorf("plane %s has an invalid cruise speed (%.2f), cannot calculate flight duration", plane.Serial, plane.CruiseSpeed)
 	}
 	// Assuming CruiseSpeed is in units per second, and distance is in those same units.
 	flightDuration := time.Duration(flightDistance/plane.CruiseSpeed) * time.Second
 
-	takeoffTime := simState.CurrentSimTime
 	landingTime := takeoffTime.Add(flightDuration)
 	var cruisingAltitude float64
 	if simState.DifferentAltitudes {
-		chance := rand.Float64()
+		chance := airport.rng.Float64()
 		if chance < 0.33 {
 			cruisingAltitude = CruisingAltitudes[0]
 		} else if chance < 0.66 {
@@ -172,27 +261,7 @@ func (airport *Airport) TakeOff(plane *Plane, simState *SimulationState) (*Fligh
 			Destination: destinationAirport.Location,
 		},
 	}
+	newFlight.VerticalProfile = []VerticalManeuver{climbManeuver(newFlight)}
 
-	// Update the plane's internal state to reflect it's now in flight.
-	plane.PlaneInFlight = true
-	plane.FlightLog = append(plane.FlightLog, newFlight)
-
-	// Add the updated plane to the global list of planes currently in flight.
-	simState.Mu.Lock()
-	simState.PlanesInFlight = append(simState.PlanesInFlight, plane)
-	simState.Mu.Unlock()
-
-	log.Printf("Plane %s (Cruise Speed: %.2fm/s) took off from Airport %s %s, heading to Airport %s %s. Estimated landing at %s.\n\n",
-		plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format("15:04:05"))
-	fmt.Fprintf(f, "%s Plane %s (Cruise Speed: %.2fm/s) took off from Airport %s %s, heading to Airport %s %s. Estimated landing at %s.\n\n",
-		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), plane.Serial, plane.CruiseSpeed, airport.Serial, airport.Location.String(), destinationAirport.Serial, destinationAirport.Location.String(), landingTime.Format("15:04:05"))
-
-	// Call the UI callback if registered
-	if simState.OnPlaneTakeOffCallback != nil {
-		fyne.Do(func() { // Ensure UI updates are on main goroutine
-			simState.OnPlaneTakeOffCallback(plane)
-		})
-	}
-
-	return &newFlight, nil
+	return newFlight, destinationAirport, nil
 }
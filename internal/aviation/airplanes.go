@@ -8,29 +8,36 @@ import (
 	"github.com/josephus-git/TCAS-simulation-Fyne/internal/util"
 )
 
-// TCASCapability defines the type of TCAS system installed on a plane
-type TCASCapability int
-
 // CruiseSpeed defines the speed of all planes
 const CruiseSpeed = 10.0
 
-// TCASCapability defines the operational state of a plane's TCAS system.
-const (
-	TCASPerfect TCASCapability = iota // 0
-	TCASFaulty
-)
-
 // TCASEngagement represents a recorded interaction between two planes, tracking its ID, involved aircraft,
 // time, and the nature of the engagement (e.g., warning, crash prediction).
 type TCASEngagement struct {
-	EngagementID     string
-	FlightID         string
-	PlaneSerial      string
-	OtherPlaneSerial string
-	TimeOfEngagement time.Time
-	WillCrash        bool
-	WarningTriggered bool // Added to track if the orange warning has been shown
-	Engaged          bool // Added to track if the green/red engagement has occurred
+	EngagementID       string
+	FlightID           string
+	OtherFlightID      string // flight of the other plane
+	PlaneSerial        string
+	OtherPlaneSerial   string
+	TimeOfEngagement   time.Time
+	WillCrash          bool           // the coordinated manoeuvres were predicted, when the RA was issued, not to keep the planes apart
+	WarningTriggered   bool           // Added to track if the orange warning has been shown
+	Engaged            bool           // Added to track if the green/red engagement has occurred
+	ClimbingPlane      string         // serial of the plane told to climb by the RA, the other plane is told to descend (only planes with a working TCAS II get it)
+	ClearOfConflict    time.Time      // when the RA ended, zero while it goes on
+	PlaneResponse      string         // how the crew of the plane responded to the RA, see ResponseComplied
+	OtherPlaneResponse string         // how the crew of the other plane responded to the RA
+	PlaneRA            string         // current RA of the plane, see RAClimb, empty when it gets none
+	OtherPlaneRA       string         // current RA of the other plane
+	Reversed           bool           // the senses of the RA were reversed, which TCAS II does only once
+	Transitions        []RATransition // every strengthening, weakening and reversal of the RA, in order
+	Miss               *MissDistance  // how close the planes came, set once their encounter is over, see Encounter
+}
+
+// involvesFlight reports whether the engagement concerns the given flight of the given plane.
+func (e TCASEngagement) involvesFlight(planeSerial, flightID string) bool {
+	return (e.PlaneSerial == planeSerial && e.FlightID == flightID) ||
+		(e.OtherPlaneSerial == planeSerial && e.OtherFlightID == flightID)
 }
 
 // Plane represents an aircraft with its key operational details and flight history.
@@ -40,24 +47,32 @@ type Plane struct {
 	CruiseSpeed           float64
 	FlightLog             []Flight
 	TCASCapability        TCASCapability
-	TCASEngagementRecords []TCASEngagement
-	CurrentTCASEngagement *TCASEngagement
+	Pilot                 PilotModel       // how the crew responds to Resolution Advisories
+	CollisionAvoidance    string           // name of the collision avoidance logic of its TCAS, see CollisionAvoidance
+	TCASEngagementIDs     []string         // its RAs, oldest first, the records are kept by SimulationState.Engagements
+	CurrentTCASEngagement *TCASEngagement  // the most critical of TCASThreats, nil without any
+	TCASThreats           []TCASEngagement // every intruder TCAS alerts about in the last cycle, RAs first
+	STCAConflicts         []string         // serials of the planes the ground STCA alerts about with this one, see STCAAlert
+	PlannedFlight         *Flight          // the flight of its takeoff roll, planned as the roll starts, nil otherwise
 }
 
 // createPlane initializes and returns a new Plane struct with a generated serial number.
-func createPlane(planeCount int) *Plane {
+// Its equipage and collision avoidance logic are drawn from the fleet and avoidance mixes,
+// and its crew from the pilot population.
+func createPlane(planeCount int, r *rand.Rand, fleet FleetMix, avoidance AvoidanceMix, pilots PilotPopulation) *Plane {
 	// Randomly assign TCAS capability
-	capability := TCASPerfect
-	if rand.Float64() < 0.25 { // 25% chance of faulty TCAS
-		capability = TCASFaulty
-	}
+	capability := fleet.draw(r)
+	logic := avoidance.draw(r)
+	pilot := pilots.draw(r)
 
 	return &Plane{
-		Serial:         util.GenerateSerialNumber(planeCount, "p"),
-		PlaneInFlight:  false,
-		CruiseSpeed:    CruiseSpeed,
-		FlightLog:      []Flight{},
-		TCASCapability: capability,
+		Serial:             util.GenerateSerialNumber(planeCount, "p"),
+		PlaneInFlight:      false,
+		CruiseSpeed:        CruiseSpeed,
+		FlightLog:          []Flight{},
+		TCASCapability:     capability,
+		Pilot:              pilot,
+		CollisionAvoidance: logic,
 	}
 }
 
@@ -1,7 +1,6 @@
 package aviation
 
 import (
-	"context"
 	"fmt"
 	"log"
 	"math/rand"
@@ -20,6 +19,10 @@ type Airport struct {
 	Planes             []*Plane
 	Mu                 sync.Mutex
 	ReceivingPlane     bool
+
+	// rng drives every random choice made by this airport (launch delays, destinations, altitudes),
+	// it is seeded from the simulation seed so runs can be reproduced.
+	rng *simRand
 }
 
 // runway represents the state of an airport's runways.
@@ -30,17 +33,18 @@ type runway struct {
 
 // createAirport initializes and returns a new Airport struct.
 // It generates a serial number, plane capacity, and runway details for the airport.
-func createAirport(airportCount, planecount, totalNumPlanes int) Airport {
+func createAirport(airportCount, planecount, totalNumPlanes int, r *rand.Rand) Airport {
 	return Airport{
 		Serial:             util.GenerateSerialNumber(airportCount, "ap"),
-		InitialPlaneAmount: generatePlaneCapacity(totalNumPlanes, planecount),
-		Runway:             generateRunway(),
+		InitialPlaneAmount: generatePlaneCapacity(totalNumPlanes, planecount, r),
+		Runway:             generateRunway(r),
+		rng:                newSimRand(r.Int63()),
 	}
 }
 
 // generateRunway creates and returns a new runway configuration.
-func generateRunway() runway {
-	randomNumber := rand.Intn(3) + 1
+func generateRunway(r *rand.Rand) runway {
+	randomNumber := r.Intn(3) + 1
 	return runway{
 		numberOfRunway:  randomNumber,
 		noOfRunwayinUse: 0,
@@ -49,14 +53,14 @@ func generateRunway() runway {
 
 // generatePlaneCapacity calculates a random number of planes to create,
 // adjusting the quantity based on the total target and already generated planes.
-func generatePlaneCapacity(totalPlanes, planeGenerated int) int {
+func generatePlaneCapacity(totalPlanes, planeGenerated int, r *rand.Rand) int {
 	var randomNumber int
 	if totalPlanes < 20 {
 		planeToCreate := totalPlanes - planeGenerated
 		if planeToCreate <= 3 {
 			randomNumber = planeToCreate
 		} else {
-			randomNumber = rand.Intn(2) + 1
+			randomNumber = r.Intn(2) + 1
 		}
 
 	} else if totalPlanes < 100 {
@@ -64,7 +68,7 @@ func generatePlaneCapacity(totalPlanes, planeGenerated int) int {
 		if planeToCreate <= 6 {
 			randomNumber = planeToCreate
 		} else {
-			randomNumber = rand.Intn(5) + 1
+			randomNumber = r.Intn(5) + 1
 		}
 
 	} else {
@@ -72,7 +76,7 @@ func generatePlaneCapacity(totalPlanes, planeGenerated int) int {
 		if planeToCreate <= 30 {
 			randomNumber = planeToCreate
 		} else {
-			randomNumber = rand.Intn(20) + 10
+			randomNumber = r.Intn(20) + 10
 		}
 
 	}
@@ -87,52 +91,46 @@ const AirportLaunchIntervalMin = 1 * time.Second
 // AirportLaunchIntervalMax is the max random delay before an airport tries to launch a plane
 const AirportLaunchIntervalMax = 60 * time.Second
 
-// startAirports launches goroutines for each airport to handle takeoffs.
-func startAirports(simState *SimulationState, ctx context.Context, wg *sync.WaitGroup) {
+// scheduleAirportLaunches schedules the first launch attempt of every airport.
+// Each airport then schedules its own next attempt, so no goroutine is needed per airport.
+func scheduleAirportLaunches(simState *SimulationState) {
 	f := simState.ConsoleLog
 	log.Printf("--- Starting Airport Launch Operations ---")
 	fmt.Fprintf(f, "%s--- Starting Airport Launch Operations ---\n",
-		time.Now().Format("2006-01-02 15:04:05"))
+		simState.CurrentSimTime.Format("2006-01-02 15:04:05"))
 	for i := range simState.Airports {
-		ap := simState.Airports[i] // Get a pointer to the airport
-		wg.Add(1)                  // Add to WaitGroup for each airport goroutine
-		go func(airport *Airport) {
-			defer wg.Done()
-			airportRand := rand.New(rand.NewSource(time.Now().UnixNano() + int64(i)*1000)) // Unique seed for each airport
-
-			for {
-				select {
-				case <-ctx.Done(): // Check if the main simulation context is done
-					// stopping all airport launch operations
-					return // Exit goroutine
-				default:
-					// Continue operation
-				}
-
-				sleepDuration := time.Duration(airportRand.Intn(int(AirportLaunchIntervalMax.Seconds()-AirportLaunchIntervalMin.Seconds())+1)+int(AirportLaunchIntervalMin.Seconds())) * time.Second //wait 5 to 10 seconds
-				select {
-				case <-time.After(sleepDuration):
-				case <-ctx.Done():
-					// stoping all airport launch operation during sleep
-					return
-				}
-
-				airport.Mu.Lock() // Lock airport to safely check and pick a plane
-				if len(airport.Planes) > 0 {
-					planeToTakeOff := airport.Planes[0] // Pick the first available plane for simplicity
-					airport.Mu.Unlock()                 // Unlock airport before calling TakeOff
-
-					// IMPORTANT: Pass the global simState here.
-					_, err := airport.TakeOff(planeToTakeOff, simState) // Pass the simState from main
-					if err != nil {
-						// log.Printf("error taking off from %s: %v", airport.Serial, err)
-					}
-				} else {
-					airport.Mu.Unlock() // Always ensure lock is released
-					// log.Printf("Airport %s has no planes to take off.", airport.Serial)
-					time.Sleep(1 * time.Second)
-				}
-			}
-		}(ap) // Pass airport pointer
+		simState.Airports[i].scheduleNextLaunch(simState, 0)
+	}
+}
+
+// scheduleNextLaunch schedules the next launch attempt of the airport after a random delay
+// between AirportLaunchIntervalMin and AirportLaunchIntervalMax, plus the given extra delay.
+func (airport *Airport) scheduleNextLaunch(simState *SimulationState, extraDelay time.Duration) {
+	// Seeded from the simulation seed, unique for each airport
+	delay := time.Duration(airport.rng.Intn(int(AirportLaunchIntervalMax.Seconds()-AirportLaunchIntervalMin.Seconds())+1)+int(AirportLaunchIntervalMin.Seconds())) * time.Second
+	simState.scheduler.Schedule(&Event{
+		Time:    simState.CurrentSimTime.Add(delay + extraDelay),
+		Kind:    EventLaunchAttempt,
+		Airport: airport,
+	})
+}
+
+// launchAttempt tries to take off the first plane parked at the airport.
+// When the takeoff starts, the next attempt is scheduled once the takeoff is complete,
+// otherwise it is scheduled right away.
+func (airport *Airport) launchAttempt(simState *SimulationState) {
+	airport.Mu.Lock() // Lock airport to safely check and pick a plane
+	if len(airport.Planes) == 0 {
+		airport.Mu.Unlock() // Always ensure lock is released
+		// log.Printf("Airport %s has no planes to take off.", airport.Serial)
+		airport.scheduleNextLaunch(simState, 1*time.Second)
+		return
+	}
+	planeToTakeOff := airport.Planes[0] // Pick the first available plane for simplicity
+	airport.Mu.Unlock()                 // Unlock airport before calling TakeOff
+
+	if err := airport.TakeOff(planeToTakeOff, simState); err != nil {
+		// log.Printf("error taking off from %s: %v", airport.Serial, err)
+		airport.scheduleNextLaunch(simState, 0)
 	}
 }
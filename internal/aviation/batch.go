@@ -0,0 +1,396 @@
+package aviation
+
+import (
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// ConfidenceZ is the z-score used for the confidence intervals of batch statistics (95%)
+const ConfidenceZ = 1.96
+
+// BatchConfig describes a Monte Carlo batch: the same scenario run many times with different seeds.
+type BatchConfig struct {
+	Runs            int            // number of simulations to run
+	DurationMinutes int            // simulated duration of each run
+	BaseSeed        int64          // run i uses the seed BaseSeed+i, so any run of the batch can be reproduced on its own
+	Scenario        config.Config  // number of planes, altitude mode and faulty TCAS ratio shared by every run
+	CostTable       *CostTable     // cost table of the acasx logic shared by every run, read from the scenario when nil
+	Progress        func(done int) // called after each completed run, may be nil
+}
+
+// RunResult holds the statistics of a single run of a batch.
+type RunResult struct {
+	Run                   int            `json:"run"`
+	Seed                  int64          `json:"seed"`
+	FlightsStarted        int            `json:"flights_started"`
+	FlightsCompleted      int            `json:"flights_completed"`
+	Engagements           int            `json:"engagements"`
+	Crashes               int            `json:"crashes"`
+	EndedByCrash          bool           `json:"ended_by_crash"`
+	EngagementsByPairing  map[string]int `json:"engagements_by_pairing"`
+	ConflictsByPairing    map[string]int `json:"conflicts_by_pairing"` // losses of separation flown, see lossesOfSeparation
+	CrashesByPairing      map[string]int `json:"crashes_by_pairing"`
+	EngagementsByResponse map[string]int `json:"engagements_by_response"` // by pair of crew responses, see responsePairing
+	CrashesByResponse     map[string]int `json:"crashes_by_response"`
+	STCAAlerts            int            `json:"stca_alerts"`       // alerts of the ground STCA
+	RAsAfterSTCA          int            `json:"ras_after_stca"`    // RAs issued while an STCA alert about the planes was on
+	STCALeadSeconds       float64        `json:"stca_lead_seconds"` // mean real seconds between the STCA alert and the RA, of those RAs
+}
+
+// Estimate is a statistic of a batch with its confidence interval.
+type Estimate struct {
+	Value float64 `json:"value"`
+	Lower float64 `json:"ci_lower"`
+	Upper float64 `json:"ci_upper"`
+}
+
+// PairingStats holds the crash rate of a pairing, of TCAS capabilities or of crew responses: the share of the
+// times two planes of the pairing were exposed to each other that ended in a crash.
+type PairingStats struct {
+	Pairing     string   `json:"pairing"`
+	Exposures   int      `json:"exposures"` // the denominator of the crash rate
+	Engagements int      `json:"engagements"`
+	Crashes     int      `json:"crashes"`
+	CrashRate   Estimate `json:"crash_rate"`
+}
+
+// BatchSummary aggregates the results of every run of a batch.
+type BatchSummary struct {
+	Runs                   int                `json:"runs"`
+	Planes                 int                `json:"planes"`
+	DurationMinutes        int                `json:"duration_minutes"`
+	DifferentAltitudes     bool               `json:"different_altitudes"`
+	FaultyTCASRatio        float64            `json:"faulty_tcas_ratio"`
+	FleetMix               map[string]float64 `json:"fleet_mix"`     // share of the fleet fitted with each equipage
+	AvoidanceMix           map[string]float64 `json:"avoidance_mix"` // share of the fleet flying with each collision avoidance logic
+	STCA                   STCAConfig         `json:"stca"`
+	CollisionRadius        float64            `json:"collision_radius"`      // map units
+	CollisionHeight        float64            `json:"collision_height_feet"` // feet
+	TAZTHR                 float64            `json:"ta_zthr_feet"`
+	RAZTHR                 float64            `json:"ra_zthr_feet"`
+	BaseSeed               int64              `json:"base_seed"`
+	EngagementsPerRun      Estimate           `json:"engagements_per_run"`
+	FlightsCompletedPerRun Estimate           `json:"flights_completed_per_run"`
+	RunsEndedByCrash       Estimate           `json:"runs_ended_by_crash"` // share of runs halted by a collision, the first crash halts a run so it is also the crashes per run
+	STCAAlertsPerRun       Estimate           `json:"stca_alerts_per_run"`
+	RAsAfterSTCA           Estimate           `json:"ras_after_stca"` // share of the RAs the ground STCA alerted about before they were issued
+	Pairings               []PairingStats     `json:"pairings"`
+	ResponsePairings       []PairingStats     `json:"response_pairings"` // crash rate by pair of crew responses
+	Results                []RunResult        `json:"results"`
+}
+
+// RunBatch runs every simulation of the batch headless and as fast as possible, spread over all CPUs,
+// and aggregates their results. Each run owns its SimulationState, so runs do not affect each other.
+func RunBatch(batch BatchConfig) BatchSummary {
+	if batch.BaseSeed == 0 {
+		batch.BaseSeed = time.Now().UnixNano()
+	}
+	if batch.CostTable == nil {
+		table, err := NewCostTable(&batch.Scenario)
+		if err != nil {
+			log.Printf("%v, the planes flying with %s use %s instead\n", err, ACASXa, DefaultCollisionAvoidance)
+		}
+		batch.CostTable = table
+	}
+
+	results := make([]RunResult, batch.Runs)
+	runs := make(chan int)
+	var wg sync.WaitGroup
+	var progressMu sync.Mutex
+	done := 0
+
+	for range runtime.NumCPU() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range runs {
+				results[i] = runBatchSimulation(batch, i)
+
+				if batch.Progress != nil {
+					progressMu.Lock()
+					done++
+					batch.Progress(done)
+					progressMu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range batch.Runs {
+		runs <- i
+	}
+	close(runs)
+	wg.Wait()
+
+	return summarizeBatch(batch, results)
+}
+
+// runBatchSimulation runs the i-th simulation of a batch and collects its statistics.
+func runBatchSimulation(batch BatchConfig, i int) RunResult {
+	conf := batch.Scenario
+	conf.Seed = batch.BaseSeed + int64(i)
+
+	simState := &SimulationState{Quiet: true, CostTable: batch.CostTable}
+	InitializeAirports(&conf, simState)
+	simState.SimSpeed = SimSpeedMax
+
+	// Batch runs keep no logs, the statistics are all that is kept of them
+	simState.ConsoleLog, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	simState.TCASLog, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	simState.STCALog, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	StartSimulation(simState, time.Duration(batch.DurationMinutes))
+	CloseLogFiles(simState)
+
+	result := RunResult{
+		Run:                  i,
+		Seed:                 simState.Seed,
+		FlightsStarted:       simState.FlightCount,
+		EndedByCrash:         len(simState.CrashedPlanes) > 0,
+		EngagementsByPairing: map[string]int{},
+		CrashesByPairing:     map[string]int{},
+	}
+
+	planes := planesBySerial(simState)
+	result.EngagementsByResponse, result.CrashesByResponse = responseOutcomes(simState, planes)
+	stca := outcomesOfSTCA(simState)
+	result.STCAAlerts = stca.Alerts
+	result.RAsAfterSTCA = stca.RAsAlerted
+	if stca.RAsAlerted > 0 {
+		result.STCALeadSeconds = stca.TotalLead.Seconds() / float64(stca.RAsAlerted)
+	}
+
+	for _, p := range planes {
+		for _, flight := range p.FlightLog {
+			if flight.FlightStatus == "landed" {
+				result.FlightsCompleted++
+			}
+		}
+	}
+	for _, engagement := range simState.Engagements {
+		if !engagement.Engaged {
+			continue
+		}
+		pairing := tcasPairing(planes[engagement.PlaneSerial], planes[engagement.OtherPlaneSerial])
+		result.Engagements++
+		result.EngagementsByPairing[pairing]++
+	}
+
+	// Planes that never got an RA, as two faulty TCAS, meet as well: every loss of separation exposes the pairing
+	result.ConflictsByPairing = lossesOfSeparation(planes, simState.CurrentSimTime)
+
+	// A failed resolution only ends in a crash if the planes actually meet, and the first crash halts the run
+	if len(simState.CrashedPlanes) == 2 {
+		result.Crashes++
+		result.CrashesByPairing[tcasPairing(planes[simState.CrashedPlanes[0]], planes[simState.CrashedPlanes[1]])]++
+	}
+
+	return result
+}
+
+// planesBySerial returns every plane of the simulation, each is either parked at an airport or in flight.
+// It must be called with simState.Mu held, or once the simulation has stopped.
+func planesBySerial(simState *SimulationState) map[string]*Plane {
+	planes := map[string]*Plane{}
+	for _, ap := range simState.Airports {
+		ap.Mu.Lock()
+		for _, p := range ap.Planes {
+			planes[p.Serial] = p
+		}
+		ap.Mu.Unlock()
+	}
+	for _, p := range simState.PlanesInFlight {
+		planes[p.Serial] = p
+	}
+	return planes
+}
+
+// lossesOfSeparation counts, by TCAS pairing, the pairs of flights that came within the separation minima of the
+// conflict probe (5 NM and 1000 ft) of each other until simEnd, outside the runway zones. The flights are replayed as
+// they were flown, RA manoeuvres included, so a collision is always one of them whatever the equipage of the planes.
+func lossesOfSeparation(planes map[string]*Plane, simEnd time.Time) map[string]int {
+	serials := make([]string, 0, len(planes))
+	for serial := range planes {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	flown := []plannedFlight{}
+	for _, serial := range serials {
+		for _, flight := range planes[serial].FlightLog {
+			if planned, ok := planFlight(serial, flight); ok {
+				flown = append(flown, planned)
+			}
+		}
+	}
+
+	losses := map[string]int{}
+	for i, a := range flown {
+		for _, b := range flown[i+1:] {
+			if a.plane == b.plane {
+				continue
+			}
+			from, to := a.flight.TakeoffTime, a.flight.DestinationArrivalTime
+			if b.flight.TakeoffTime.After(from) {
+				from = b.flight.TakeoffTime
+			}
+			if b.flight.DestinationArrivalTime.Before(to) {
+				to = b.flight.DestinationArrivalTime
+			}
+			if simEnd.Before(to) {
+				to = simEnd
+			}
+			if !to.After(from) {
+				continue
+			}
+			if _, ok := predictConflict(a, b, from, to.Sub(from)); ok {
+				losses[tcasPairing(planes[a.plane], planes[b.plane])]++
+			}
+		}
+	}
+	return losses
+}
+
+// tcasPairing names the pair of TCAS capabilities of two planes, in the same order whichever plane comes first.
+func tcasPairing(p1, p2 *Plane) string {
+	names := []string{p1.TCASCapability.String(), p2.TCASCapability.String()}
+	sort.Strings(names)
+	return names[0] + "-" + names[1]
+}
+
+// summarizeBatch aggregates the results of a batch with their confidence intervals.
+func summarizeBatch(batch BatchConfig, results []RunResult) BatchSummary {
+	summary := BatchSummary{
+		Runs:               batch.Runs,
+		Planes:             batch.Scenario.NoOfAirplanes,
+		DurationMinutes:    batch.DurationMinutes,
+		DifferentAltitudes: batch.Scenario.DifferentAltitudes,
+		FaultyTCASRatio:    batch.Scenario.FaultyTCASRatio,
+		FleetMix:           NewFleetMix(&batch.Scenario).Names(),
+		AvoidanceMix:       batch.Scenario.AvoidanceMix,
+		STCA:               NewSTCAConfig(&batch.Scenario),
+		CollisionRadius:    batch.Scenario.CollisionRadius,
+		CollisionHeight:    batch.Scenario.CollisionHeight,
+		TAZTHR:             batch.Scenario.TAZTHR,
+		RAZTHR:             batch.Scenario.RAZTHR,
+		BaseSeed:           batch.BaseSeed,
+		Results:            results,
+	}
+
+	engagements := make([]float64, len(results))
+	flights := make([]float64, len(results))
+	alerts := make([]float64, len(results))
+	endedByCrash, totalEngagements, rasAfterSTCA := 0, 0, 0
+	pairingEngagements := map[string]int{}
+	pairingConflicts := map[string]int{}
+	pairingCrashes := map[string]int{}
+	responseEngagements := map[string]int{}
+	responseCrashes := map[string]int{}
+	for i, r := range results {
+		engagements[i] = float64(r.Engagements)
+		flights[i] = float64(r.FlightsCompleted)
+		alerts[i] = float64(r.STCAAlerts)
+		totalEngagements += r.Engagements
+		rasAfterSTCA += r.RAsAfterSTCA
+		if r.EndedByCrash {
+			endedByCrash++
+		}
+		for pairing, n := range r.EngagementsByPairing {
+			pairingEngagements[pairing] += n
+		}
+		for pairing, n := range r.ConflictsByPairing {
+			pairingConflicts[pairing] += n
+		}
+		for pairing, n := range r.CrashesByPairing {
+			pairingCrashes[pairing] += n
+		}
+		for pairing, n := range r.EngagementsByResponse {
+			responseEngagements[pairing] += n
+		}
+		for pairing, n := range r.CrashesByResponse {
+			responseCrashes[pairing] += n
+		}
+	}
+
+	summary.EngagementsPerRun = meanEstimate(engagements)
+	summary.FlightsCompletedPerRun = meanEstimate(flights)
+	summary.RunsEndedByCrash = proportionEstimate(endedByCrash, len(results))
+	summary.STCAAlertsPerRun = meanEstimate(alerts)
+	summary.RAsAfterSTCA = proportionEstimate(rasAfterSTCA, totalEngagements)
+
+	// Crews only respond to RAs, so the RAs are what exposes a pairing of responses
+	summary.Pairings = pairingStats(pairingConflicts, pairingEngagements, pairingCrashes)
+	summary.ResponsePairings = pairingStats(responseEngagements, responseEngagements, responseCrashes)
+
+	return summary
+}
+
+// pairingStats returns the crash rate of each pairing with exposures, engagements or crashes, sorted by name.
+// The rate is the share of the exposures that ended in a crash; a crash is always counted, even in a pairing
+// that had no engagement.
+func pairingStats(exposures, engagements, crashes map[string]int) []PairingStats {
+	pairings := map[string]bool{}
+	for _, counts := range []map[string]int{exposures, engagements, crashes} {
+		for pairing := range counts {
+			pairings[pairing] = true
+		}
+	}
+	stats := []PairingStats{}
+	for pairing := range pairings {
+		// A crash exposed its pairing, even if the exposures missed it
+		exposed := max(exposures[pairing], crashes[pairing])
+		stats = append(stats, PairingStats{
+			Pairing:     pairing,
+			Exposures:   exposed,
+			Engagements: engagements[pairing],
+			Crashes:     crashes[pairing],
+			CrashRate:   proportionEstimate(crashes[pairing], exposed),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Pairing < stats[j].Pairing })
+	return stats
+}
+
+// meanEstimate returns the mean of the samples with its normal approximation confidence interval.
+func meanEstimate(samples []float64) Estimate {
+	n := float64(len(samples))
+	if n == 0 {
+		return Estimate{}
+	}
+
+	var sum float64
+	for _, s := range samples {
+		sum += s
+	}
+	mean := sum / n
+	if n < 2 {
+		return Estimate{Value: mean, Lower: mean, Upper: mean}
+	}
+
+	var squares float64
+	for _, s := range samples {
+		squares += (s - mean) * (s - mean)
+	}
+	margin := ConfidenceZ * math.Sqrt(squares/(n-1)) / math.Sqrt(n)
+	return Estimate{Value: mean, Lower: mean - margin, Upper: mean + margin}
+}
+
+// proportionEstimate returns the proportion successes/trials with its Wilson score confidence interval,
+// which stays within [0, 1] even for the small counts and extreme rates crashes produce.
+func proportionEstimate(successes, trials int) Estimate {
+	if trials == 0 {
+		return Estimate{}
+	}
+
+	n := float64(trials)
+	p := float64(successes) / n
+	z2 := ConfidenceZ * ConfidenceZ
+	centre := (p + z2/(2*n)) / (1 + z2/n)
+	margin := ConfidenceZ * math.Sqrt(p*(1-p)/n+z2/(4*n*n)) / (1 + z2/n)
+	return Estimate{Value: p, Lower: math.Max(0, centre-margin), Upper: math.Min(1, centre+margin)}
+}
@@ -0,0 +1,66 @@
+package aviation
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestProportionEstimate(t *testing.T) {
+	tests := []struct {
+		name              string
+		successes, trials int
+		want              Estimate
+	}{
+		{name: "no trials", successes: 0, trials: 0, want: Estimate{}},
+		{name: "no successes", successes: 0, trials: 10, want: Estimate{Value: 0, Lower: 0, Upper: 0.2775}},
+		{name: "half", successes: 5, trials: 10, want: Estimate{Value: 0.5, Lower: 0.2366, Upper: 0.7634}},
+		{name: "every trial", successes: 20, trials: 20, want: Estimate{Value: 1, Lower: 0.8389, Upper: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := proportionEstimate(tt.successes, tt.trials)
+			if math.Abs(got.Value-tt.want.Value) > 1e-4 || math.Abs(got.Lower-tt.want.Lower) > 1e-4 ||
+				math.Abs(got.Upper-tt.want.Upper) > 1e-4 {
+				t.Errorf("proportionEstimate(%d, %d) = %+v, want %+v", tt.successes, tt.trials, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPairingStats(t *testing.T) {
+	tests := []struct {
+		name                            string
+		exposures, engagements, crashes map[string]int
+		want                            []PairingStats
+	}{
+		{
+			name: "nothing happened",
+			want: []PairingStats{},
+		},
+		{
+			name:        "sorted by pairing",
+			exposures:   map[string]int{"perfect-perfect": 10, "faulty-perfect": 4},
+			engagements: map[string]int{"perfect-perfect": 8, "faulty-perfect": 2},
+			crashes:     map[string]int{"perfect-perfect": 5},
+			want: []PairingStats{
+				{Pairing: "faulty-perfect", Exposures: 4, Engagements: 2, CrashRate: proportionEstimate(0, 4)},
+				{Pairing: "perfect-perfect", Exposures: 10, Engagements: 8, Crashes: 5, CrashRate: proportionEstimate(5, 10)},
+			},
+		},
+		{
+			name:    "a crash exposes its pairing",
+			crashes: map[string]int{"faulty-faulty": 1},
+			want: []PairingStats{
+				{Pairing: "faulty-faulty", Exposures: 1, Crashes: 1, CrashRate: proportionEstimate(1, 1)},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pairingStats(tt.exposures, tt.engagements, tt.crashes); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pairingStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CollisionAvoidance is the collision avoidance logic of a plane: every TCAS cycle it decides which advisory its
+// crew gets about each intruder, how long an RA goes on, and which way a new RA sends the plane.
+// The simulation does the rest the same way whatever the logic: it coordinates RAs between the planes, flies them
+// with the pilot models, revises them (see reviseRA) and resolves multi-threat encounters.
+// A plane only ever uses the logic picked for it, see Plane.CollisionAvoidance and AvoidanceMix.
+type CollisionAvoidance interface {
+	// Name returns the short name of the logic, used by scenarios, flags and logs.
+	Name() string
+	// Advise returns the advisory the plane gets about an intruder. An RA is given as a TA to a plane that cannot
+	// get RAs (own.GetsRA is false).
+	Advise(own, intruder Aircraft) Advisory
+	// RAContinues reports whether an RA issued by own against intruder goes on, or the planes are clear of conflict.
+	RAContinues(own, intruder Aircraft) bool
+	// Climb reports whether a new RA of own against intruder climbs. When intruder.GetsRA it gets the complementary
+	// sense, otherwise it is expected to carry on as it flies.
+	Climb(own, intruder Aircraft) bool
+}
+
+// Aircraft is what collision avoidance logic knows of a plane: its Mode S address, which is its serial, its track,
+// whether its TCAS may give it RAs, and the RA it flies against the other aircraft, if any.
+type Aircraft struct {
+	Serial string
+	Track  Track
+	GetsRA bool
+	RA     string // see RAClimb, empty without an RA against the other aircraft
+}
+
+// DefaultCollisionAvoidance is the name of the collision avoidance logic planes use when none is picked for them
+const DefaultCollisionAvoidance = "tcas2"
+
+// collisionAvoidanceLogics builds the collision avoidance logic of each name for a simulation,
+// see RegisterCollisionAvoidance.
+var collisionAvoidanceLogics = map[string]func(simState *SimulationState) CollisionAvoidance{
+	DefaultCollisionAvoidance: func(simState *SimulationState) CollisionAvoidance {
+		return ThresholdLogic{Vertical: simState.VerticalThresholds}
+	},
+}
+
+// RegisterCollisionAvoidance makes a collision avoidance logic available to scenarios and the -avoidance flag under
+// the given name. build is called for each plane every TCAS cycle, with the state of its simulation.
+// It must be called before any simulation starts, typically from an init function.
+func RegisterCollisionAvoidance(name string, build func(simState *SimulationState) CollisionAvoidance) {
+	collisionAvoidanceLogics[name] = build
+}
+
+// collisionAvoidanceNames returns the names of every registered collision avoidance logic, in order.
+func collisionAvoidanceNames() []string {
+	names := []string{}
+	for name := range collisionAvoidanceLogics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// avoidanceOf returns the collision avoidance logic of a plane, planes saved before it could be picked use the default.
+func (simState *SimulationState) avoidanceOf(plane *Plane) CollisionAvoidance {
+	build, ok := collisionAvoidanceLogics[plane.CollisionAvoidance]
+	if !ok {
+		build = collisionAvoidanceLogics[DefaultCollisionAvoidance]
+	}
+	return build(simState)
+}
+
+// aircraftOf returns what collision avoidance logic knows of a plane at the given track.
+func aircraftOf(plane *Plane, track Track) Aircraft {
+	return Aircraft{Serial: plane.Serial, Track: track, GetsRA: getsRA(plane, track)}
+}
+
+// AvoidanceMix is the share of the fleet, between 0 and 1, flying with each collision avoidance logic, by name.
+// The shares add up to 1.
+type AvoidanceMix map[string]float64
+
+// ParseAvoidanceMix parses the collision avoidance logics of a fleet, written as comma separated name=share pairs
+// such as "tcas2=0.5,acasx=0.5", or a single name for the whole fleet.
+func ParseAvoidanceMix(s string) (AvoidanceMix, error) {
+	mix := AvoidanceMix{}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		share := 1.0
+		if ok {
+			var err error
+			if share, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("the share of %s in the avoidance mix is not a number: %q", name, value)
+			}
+		}
+		mix[name] += share
+	}
+	if err := mix.Validate(); err != nil {
+		return nil, err
+	}
+	return mix, nil
+}
+
+// Validate reports an error if a logic is not registered, a share is outside [0, 1] or the shares do not add up to 1.
+func (m AvoidanceMix) Validate() error {
+	total := 0.0
+	for name, share := range m {
+		if _, ok := collisionAvoidanceLogics[name]; !ok {
+			return fmt.Errorf("unknown collision avoidance logic %q, use one of %s", name, strings.Join(collisionAvoidanceNames(), ", "))
+		}
+		if share < 0 || share > 1 {
+			return fmt.Errorf("the share of %s in the avoidance mix must be between 0 and 1, got %v", name, share)
+		}
+		total += share
+	}
+	if len(m) > 0 && math.Abs(total-1) > 1e-6 {
+		return fmt.Errorf("the shares of the avoidance mix must add up to 1, got %v", total)
+	}
+	return nil
+}
+
+// draw picks the collision avoidance logic of a new plane, each with the odds of its share of the fleet.
+// An empty mix gives every plane the default logic, and a mix of a single logic draws nothing, so picking one
+// does not change the other random choices of a seeded simulation.
+func (m AvoidanceMix) draw(r *rand.Rand) string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	switch len(names) {
+	case 0:
+		return DefaultCollisionAvoidance
+	case 1:
+		return names[0]
+	}
+
+	x := r.Float64()
+	for _, name := range names {
+		x -= m[name]
+		if x < 0 {
+			return name
+		}
+	}
+	return names[len(names)-1]
+}
+
+// ThresholdLogic is the collision avoidance logic of TCAS II: the advisories come from the range and vertical tests
+// against the thresholds of the sensitivity level of the altitude of the plane, with the configured altitude
+// thresholds at cruising levels.
+type ThresholdLogic struct {
+	Vertical VerticalThresholds
+}
+
+// Name returns the name ThresholdLogic is registered under.
+func (l ThresholdLogic) Name() string {
+	return DefaultCollisionAvoidance
+}
+
+// Advise returns the advisory of the TCAS II tests, see evaluateTCAS.
+func (l ThresholdLogic) Advise(own, intruder Aircraft) Advisory {
+	advisory := evaluateTCAS(own.Track, intruder.Track, l.Vertical.thresholdsAt(own.Track.Altitude))
+	if advisory == AdvisoryRA && !own.GetsRA {
+		return AdvisoryTA
+	}
+	return advisory
+}
+
+// RAContinues reports whether the RA range test still passes, with the wider RA thresholds of the two planes,
+// so the RA ends at the same time whichever plane issued it.
+func (l ThresholdLogic) RAContinues(own, intruder Aircraft) bool {
+	th := l.Vertical.thresholdsAt(own.Track.Altitude)
+	if other := l.Vertical.thresholdsAt(intruder.Track.Altitude); other.RATau > th.RATau {
+		th = other
+	}
+	return raContinues(own.Track, intruder.Track, th)
+}
+
+// Climb chooses the senses of the RA as TCAS II does, see selectClimbingPlane.
+func (l ThresholdLogic) Climb(own, intruder Aircraft) bool {
+	return selectClimbingPlane(own, intruder) == own.Serial
+}
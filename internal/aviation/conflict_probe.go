@@ -0,0 +1,239 @@
+package aviation
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Medium-term conflict detection: the conflict probe projects every flight along its flight plan and reports the
+// pairs predicted to lose separation minutes ahead, long before STCA or TCAS would alert about them, so they can be
+// resolved by a change of plan rather than by an avoidance manoeuvre.
+// Like the TCAS II thresholds, the look-ahead and the minima are those of real flight, scaled to the simulated world.
+const (
+	ConflictLookAhead         = 10 * time.Minute // how far ahead the flights are projected, in real time
+	ConflictHorizontalMinimum = 5.0              // nautical miles, the en-route separation minimum
+	ConflictVerticalMinimum   = 1000.0           // feet
+)
+
+// conflictProbeStep is the step, in real seconds, at which the planned altitudes of two flights are compared while
+// they are horizontally closer than the minimum
+const conflictProbeStep = 1.0
+
+// ConflictMinimumDistance returns the horizontal separation minimum of the conflict probe in map units.
+func ConflictMinimumDistance() float64 {
+	return ConflictHorizontalMinimum * NauticalMileToMeters * tcasRangeScale
+}
+
+// PredictedConflict is a loss of separation the conflict probe predicts between two flights following their plans:
+// when it starts and ends, and how close the planes come meanwhile.
+type PredictedConflict struct {
+	Planes     [2]string
+	Flights    [2]string
+	Start      time.Time  // separation is predicted to be lost
+	End        time.Time  // separation is predicted to be regained, or the end of the look-ahead or of a flight
+	Closest    time.Time  // the planes are horizontally closest while separation is lost
+	Horizontal float64    // map units, at Closest
+	Vertical   float64    // meters, at Closest
+	Position   Coordinate // halfway between the planes at Closest
+}
+
+// HorizontalNM returns the predicted horizontal distance at the closest approach in real nautical miles, see
+// tcasRangeScale.
+func (c PredictedConflict) HorizontalNM() float64 {
+	return c.Horizontal / tcasRangeScale / NauticalMileToMeters
+}
+
+// VerticalFeet returns the predicted vertical distance at the closest approach in feet.
+func (c PredictedConflict) VerticalFeet() float64 {
+	return c.Vertical / FeetToMeters
+}
+
+// TimeToLoss returns how long, in real time, the planes have before they lose separation when it is simTime.
+func (c PredictedConflict) TimeToLoss(simTime time.Time) time.Duration {
+	return time.Duration(float64(c.Start.Sub(simTime)) / TCASTimeScale)
+}
+
+// plannedFlight is a flight as the conflict probe projects it: straight from its departure to its destination in
+// the time planned, with its planned climb and descent. It is only watched between leaving the runway zone of its
+// departure and entering the one of its destination, where the runway rules keep the planes apart.
+type plannedFlight struct {
+	plane                string
+	flight               Flight
+	velocityX, velocityY float64 // map units per simulation second
+	enter, leave         time.Time
+}
+
+// planFlight returns the projection of a flight, ok is false for a flight too short to leave the runway zones.
+// The phases of the flight plan that have not started yet are added to the vertical profile; a plane in an RA starts
+// them later, which the probe finds out once it has.
+func planFlight(plane string, flight Flight) (plannedFlight, bool) {
+	duration := flight.DestinationArrivalTime.Sub(flight.TakeoffTime).Seconds()
+	if duration <= 0 {
+		return plannedFlight{}, false
+	}
+	planned := plannedFlight{
+		plane:     plane,
+		flight:    flight,
+		velocityX: (flight.FlightSchedule.Destination.X - flight.FlightSchedule.Depature.X) / duration,
+		velocityY: (flight.FlightSchedule.Destination.Y - flight.FlightSchedule.Depature.Y) / duration,
+	}
+	speed := math.Hypot(planned.velocityX, planned.velocityY)
+	if speed == 0 {
+		return plannedFlight{}, false
+	}
+	clearance := time.Duration(RunwayZoneRadius / speed * float64(time.Second))
+	planned.enter = flight.TakeoffTime.Add(clearance)
+	planned.leave = flight.DestinationArrivalTime.Add(-clearance)
+	if !planned.leave.After(planned.enter) {
+		return plannedFlight{}, false
+	}
+
+	planned.flight = flight.plannedProfile()
+	return planned, true
+}
+
+// positionAt returns where the flight plans the plane to be at simTime.
+func (p plannedFlight) positionAt(simTime time.Time) Coordinate {
+	dt := simTime.Sub(p.flight.TakeoffTime).Seconds()
+	return Coordinate{
+		X: p.flight.FlightSchedule.Depature.X + p.velocityX*dt,
+		Y: p.flight.FlightSchedule.Depature.Y + p.velocityY*dt,
+	}
+}
+
+// plannedFlights returns the projection of the flight of every plane that has not arrived yet at simTime: the
+// flights in the air, and the flights of the planes on their takeoff roll, whose plan is drawn as the roll starts.
+// It must be called with simState.Mu held.
+func plannedFlights(simState *SimulationState, simTime time.Time) []plannedFlight {
+	planes := planesBySerial(simState)
+	serials := make([]string, 0, len(planes))
+	for serial := range planes {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	flights := []plannedFlight{}
+	for _, serial := range serials {
+		plane := planes[serial]
+		var flight Flight
+		switch {
+		case plane.PlannedFlight != nil:
+			flight = *plane.PlannedFlight
+		case len(plane.FlightLog) > 0:
+			flight = plane.FlightLog[len(plane.FlightLog)-1]
+		default:
+			continue
+		}
+		if !flight.DestinationArrivalTime.After(simTime) {
+			continue
+		}
+		if planned, ok := planFlight(serial, flight); ok {
+			flights = append(flights, planned)
+		}
+	}
+	return flights
+}
+
+// predictConflict returns the first loss of separation between two flights from simTime until simTime+lookAhead,
+// ok is false when they keep separated. The planes move in straight lines, so the times they are horizontally
+// closer than the minimum are solved for; their altitudes are compared at every step of that interval.
+func predictConflict(a, b plannedFlight, simTime time.Time, lookAhead time.Duration) (PredictedConflict, bool) {
+	from, to := simTime, simTime.Add(lookAhead)
+	for _, t := range []time.Time{a.enter, b.enter} {
+		if t.After(from) {
+			from = t
+		}
+	}
+	for _, t := range []time.Time{a.leave, b.leave} {
+		if t.Before(to) {
+			to = t
+		}
+	}
+	if !to.After(from) {
+		return PredictedConflict{}, false
+	}
+
+	// Times the planes are within the horizontal minimum: |r + v s| < minimum, s seconds after from
+	minimum := ConflictMinimumDistance()
+	posA, posB := a.positionAt(from), b.positionAt(from)
+	rx, ry := posB.X-posA.X, posB.Y-posA.Y
+	vx, vy := b.velocityX-a.velocityX, b.velocityY-a.velocityY
+	start, end := 0.0, to.Sub(from).Seconds()
+	qa, qb, qd := vx*vx+vy*vy, rx*vx+ry*vy, rx*rx+ry*ry-minimum*minimum
+	if qa == 0 {
+		if qd >= 0 {
+			return PredictedConflict{}, false
+		}
+	} else {
+		discriminant := qb*qb - qa*qd
+		if discriminant <= 0 {
+			return PredictedConflict{}, false
+		}
+		root := math.Sqrt(discriminant)
+		start, end = math.Max(start, (-qb-root)/qa), math.Min(end, (-qb+root)/qa)
+	}
+	if start >= end {
+		return PredictedConflict{}, false
+	}
+
+	// Within that interval, separation is lost while the planes are also within the vertical minimum
+	verticalMinimum := ConflictVerticalMinimum * FeetToMeters
+	step := conflictProbeStep * TCASTimeScale
+	conflict := PredictedConflict{Planes: [2]string{a.plane, b.plane}, Flights: [2]string{a.flight.FlightID, b.flight.FlightID}}
+	lost := false
+	for s := start; ; s = math.Min(s+step, end) {
+		t := from.Add(time.Duration(s * float64(time.Second)))
+		vertical := math.Abs(a.flight.AltitudeAt(t) - b.flight.AltitudeAt(t))
+		if vertical >= verticalMinimum {
+			if lost {
+				conflict.End = t
+				break
+			}
+		} else {
+			horizontal := math.Hypot(rx+vx*s, ry+vy*s)
+			if !lost || horizontal < conflict.Horizontal {
+				pa, pb := a.positionAt(t), b.positionAt(t)
+				conflict.Closest, conflict.Horizontal, conflict.Vertical = t, horizontal, vertical
+				conflict.Position = Coordinate{X: (pa.X + pb.X) / 2, Y: (pa.Y + pb.Y) / 2}
+			}
+			if !lost {
+				conflict.Start, lost = t, true
+			}
+		}
+		if s >= end {
+			conflict.End = t
+			break
+		}
+	}
+	return conflict, lost
+}
+
+// PredictConflicts projects every flight that has not arrived yet along its flight plan for ConflictLookAhead and
+// returns the losses of separation predicted between them, the soonest first.
+func (simState *SimulationState) PredictConflicts() []PredictedConflict {
+	simState.Mu.Lock()
+	simTime := simState.CurrentSimTime
+	flights := plannedFlights(simState, simTime)
+	simState.Mu.Unlock()
+
+	lookAhead := time.Duration(float64(ConflictLookAhead) * TCASTimeScale)
+	conflicts := []PredictedConflict{}
+	for i := range flights {
+		for j := i + 1; j < len(flights); j++ {
+			if conflict, ok := predictConflict(flights[i], flights[j], simTime, lookAhead); ok {
+				conflicts = append(conflicts, conflict)
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if !conflicts[i].Start.Equal(conflicts[j].Start) {
+			return conflicts[i].Start.Before(conflicts[j].Start)
+		}
+		if conflicts[i].Planes[0] != conflicts[j].Planes[0] {
+			return conflicts[i].Planes[0] < conflicts[j].Planes[0]
+		}
+		return conflicts[i].Planes[1] < conflicts[j].Planes[1]
+	})
+	return conflicts
+}
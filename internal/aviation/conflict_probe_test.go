@@ -0,0 +1,98 @@
+package aviation
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// levelFlight returns the projection of a flight from one point to another between takeoff and arrival, level
+// at altitude unless profile is given.
+func levelFlight(plane string, from, to Coordinate, takeoff, arrival time.Time, altitude float64, profile ...VerticalManeuver) plannedFlight {
+	if len(profile) == 0 {
+		profile = []VerticalManeuver{{Time: takeoff, Altitude: altitude, TargetAltitude: altitude}}
+	}
+	duration := arrival.Sub(takeoff).Seconds()
+	return plannedFlight{
+		plane: plane,
+		flight: Flight{FlightID: plane + "-f1", FlightSchedule: FlightPath{Depature: from, Destination: to},
+			TakeoffTime: takeoff, DestinationArrivalTime: arrival, CruisingAltitude: altitude, VerticalProfile: profile},
+		velocityX: (to.X - from.X) / duration,
+		velocityY: (to.Y - from.Y) / duration,
+		enter:     takeoff.Add(time.Second),
+		leave:     arrival.Add(-time.Second),
+	}
+}
+
+func TestPredictConflict(t *testing.T) {
+	// Two planes meet head-on halfway along 400 units, 20 s after their takeoffs; they are within the 5 NM minimum,
+	// about 37 units, from 18.15 s to 21.85 s
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	seconds := func(s float64) time.Time { return start.Add(time.Duration(s * float64(time.Second))) }
+	west, east := Coordinate{X: 0}, Coordinate{X: 400}
+	eastbound := levelFlight("p1", west, east, start, seconds(40), 10000)
+	tests := []struct {
+		name      string
+		other     plannedFlight
+		lookAhead time.Duration
+		want      bool
+		wantStart time.Time
+		wantEnd   time.Time
+	}{
+		{
+			name:      "head-on at the same level",
+			other:     levelFlight("p2", east, west, start, seconds(40), 10000),
+			lookAhead: time.Minute,
+			want:      true,
+			wantStart: seconds(18.15),
+			wantEnd:   seconds(21.85),
+		},
+		{
+			name:      "head-on 2000 ft apart",
+			other:     levelFlight("p2", east, west, start, seconds(40), 10000+2000*FeetToMeters),
+			lookAhead: time.Minute,
+		},
+		{
+			name:      "head-on beyond the look-ahead",
+			other:     levelFlight("p2", east, west, start, seconds(40), 10000),
+			lookAhead: 10 * time.Second,
+		},
+		{
+			name:      "parallel tracks apart",
+			other:     levelFlight("p2", Coordinate{Y: 50}, Coordinate{X: 400, Y: 50}, start, seconds(40), 10000),
+			lookAhead: time.Minute,
+		},
+		{
+			name: "climbing out of the level of the other",
+			other: levelFlight("p2", east, west, start, seconds(40), 10000,
+				VerticalManeuver{Time: start, Altitude: 10000, TargetAltitude: 10000},
+				VerticalManeuver{Time: seconds(18), Altitude: 10000, VerticalRate: 100, TargetAltitude: 12000}),
+			lookAhead: time.Minute,
+			want:      true,
+			wantStart: seconds(18.15),
+			wantEnd:   seconds(21.05),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflict, ok := predictConflict(eastbound, tt.other, start, tt.lookAhead)
+			if ok != tt.want {
+				t.Fatalf("predictConflict() ok = %v, want %v", ok, tt.want)
+			}
+			if !ok {
+				return
+			}
+			// The altitudes are compared every conflictProbeStep, so the times are known to a step
+			step := conflictProbeStep * TCASTimeScale
+			if math.Abs(conflict.Start.Sub(tt.wantStart).Seconds()) > step {
+				t.Errorf("Start = %v, want %v", conflict.Start.Sub(start), tt.wantStart.Sub(start))
+			}
+			if math.Abs(conflict.End.Sub(tt.wantEnd).Seconds()) > step {
+				t.Errorf("End = %v, want %v", conflict.End.Sub(start), tt.wantEnd.Sub(start))
+			}
+			if conflict.Planes != [2]string{"p1", "p2"} {
+				t.Errorf("Planes = %v", conflict.Planes)
+			}
+		})
+	}
+}
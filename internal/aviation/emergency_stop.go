@@ -4,17 +4,19 @@ import (
 	"log"
 )
 
-// EmergencyStop immediately halts the simulation, canceling all active goroutines and resetting the simulation state.
-func EmergencyStop(simState *SimulationState) {
-	if simulationCancelFunc != nil {
-		log.Println("\n--- EMERGENCY STOP ACTIVATED! Signaling all goroutines to stop... ---")
-		simulationCancelFunc() // Trigger cancellation
-		// Reset the cancel func to indicate no active simulation,
-		// and prevent multiple calls to a potentially nil context if Start() finished.
-		if stopTrigger.Stop() {
-		}
-		simulationCancelFunc = nil
+// EmergencyStop immediately halts the simulation by cancelling its context, which stops the event loop.
+// It only affects this simulation, other simulations running in the same process carry on.
+func (simState *SimulationState) EmergencyStop() {
+	simState.Mu.Lock()
+	cancel := simState.cancel
+	// Reset the cancel func to indicate no active simulation,
+	// and prevent multiple calls to a potentially nil context if Start() finished.
+	simState.cancel = nil
+	simState.Mu.Unlock()
 
+	if cancel != nil {
+		log.Println("\n--- EMERGENCY STOP ACTIVATED! Signaling the simulation to stop... ---")
+		cancel() // Trigger cancellation
 	} else {
 		log.Println("EmergencyStop: Simulation not running")
 	}
@@ -0,0 +1,303 @@
+package aviation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Outcomes of an encounter
+const (
+	EncounterOngoing  = "ongoing"           // the planes are still in an advisory against each other
+	EncounterTAOnly   = "traffic only"      // the encounter ended without an RA
+	EncounterResolved = "clear of conflict" // the RA ended with the planes clear of each other
+	EncounterCrashed  = "crashed"           // the planes collided
+)
+
+// Encounter is the lifecycle of an encounter between two planes, from the first advisory of either TCAS about the
+// other until they are clear of conflict: its TA, its RA and every change of it, and the closest point of approach.
+// Engagements and encounters are both stored once on the simulation state, an encounter keyed by the pair of planes
+// (see pairKey). A pair that meets again later has a new encounter.
+type Encounter struct {
+	ID              string         `json:"id"`
+	Planes          [2]string      `json:"planes"`                      // serials of the two planes, in order
+	TAStart         time.Time      `json:"ta_start"`                    // first TA of either plane, zero if the encounter started with an RA
+	RAIssued        time.Time      `json:"ra_issued"`                   // zero without an RA
+	EngagementID    string         `json:"engagement_id,omitempty"`     // the first RA of the encounter, empty without one
+	RAChanges       []RATransition `json:"ra_changes,omitempty"`        // strengthenings, weakenings and reversals of the RA
+	ClosestApproach MissDistance   `json:"closest_approach"`            // miss distances so far
+	ClearOfConflict time.Time      `json:"clear_of_conflict,omitempty"` // when the last advisory ended, zero while ongoing or after a crash
+	Outcome         string         `json:"outcome"`                     // EncounterOngoing, EncounterTAOnly, EncounterResolved or EncounterCrashed
+}
+
+// Thresholds of a Near Mid-Air Collision: two planes closer than both at once, whatever TCAS did
+const (
+	NMACHorizontal = 500.0 // feet
+	NMACVertical   = 100.0 // feet
+)
+
+// MissDistance is how close the planes of an encounter came to each other: the horizontal and vertical distances
+// between them at their closest point of approach, the moment they were horizontally closest.
+type MissDistance struct {
+	Time       time.Time `json:"time"`       // time of closest approach
+	Horizontal float64   `json:"horizontal"` // map units
+	Vertical   float64   `json:"vertical"`   // meters
+	NMAC       bool      `json:"nmac"`       // the planes were within the NMAC thresholds at some point, not only at the closest approach
+}
+
+// HorizontalFeet returns the horizontal miss distance in real feet, see tcasRangeScale.
+func (m MissDistance) HorizontalFeet() float64 {
+	return m.Horizontal / tcasRangeScale / FeetToMeters
+}
+
+// VerticalFeet returns the vertical miss distance in feet.
+func (m MissDistance) VerticalFeet() float64 {
+	return m.Vertical / FeetToMeters
+}
+
+// isNMAC reports whether two planes at the given horizontal and vertical distances, in map units and meters,
+// are in a Near Mid-Air Collision.
+func isNMAC(horizontal, vertical float64) bool {
+	return horizontal/tcasRangeScale < NMACHorizontal*FeetToMeters && vertical < NMACVertical*FeetToMeters
+}
+
+// observe takes the distances between the planes over the last TCAS cycle into the miss distances,
+// they count as an NMAC only if nmacPossible.
+func (m *MissDistance) observe(simTime time.Time, horizontal, vertical float64, nmacPossible bool) {
+	if m.Time.IsZero() || horizontal < m.Horizontal {
+		m.Time = simTime
+		m.Horizontal = horizontal
+		m.Vertical = vertical
+	}
+	if nmacPossible && isNMAC(horizontal, vertical) {
+		m.NMAC = true
+	}
+}
+
+// pairKey returns the key of the encounters of two planes, the same whichever way round they are given.
+func pairKey(serial1, serial2 string) string {
+	if serial2 < serial1 {
+		serial1, serial2 = serial2, serial1
+	}
+	return serial1 + "/" + serial2
+}
+
+// Start returns when the encounter started: its first advisory, or the collision of planes that got none.
+func (e *Encounter) Start() time.Time {
+	switch {
+	case !e.TAStart.IsZero():
+		return e.TAStart
+	case !e.RAIssued.IsZero():
+		return e.RAIssued
+	default:
+		return e.ClosestApproach.Time
+	}
+}
+
+// End returns when the encounter ended, clear of conflict or in a collision, and false while it is ongoing.
+func (e *Encounter) End() (time.Time, bool) {
+	switch e.Outcome {
+	case EncounterOngoing:
+		return time.Time{}, false
+	case EncounterCrashed:
+		return e.ClosestApproach.Time, true
+	default:
+		return e.ClearOfConflict, true
+	}
+}
+
+// WarningTime returns how long the crews had between the TA and the RA, false if the encounter had not both.
+func (e *Encounter) WarningTime() (time.Duration, bool) {
+	if e.TAStart.IsZero() || e.RAIssued.IsZero() {
+		return 0, false
+	}
+	return e.RAIssued.Sub(e.TAStart), true
+}
+
+// openEncounter returns the ongoing encounter of two planes, nil if they are not in one.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) openEncounter(serial1, serial2 string) *Encounter {
+	encounters := simState.Encounters[pairKey(serial1, serial2)]
+	if n := len(encounters); n > 0 && encounters[n-1].Outcome == EncounterOngoing {
+		return encounters[n-1]
+	}
+	return nil
+}
+
+// startEncounter opens a new encounter between two planes.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) startEncounter(serial1, serial2 string) *Encounter {
+	if serial2 < serial1 {
+		serial1, serial2 = serial2, serial1
+	}
+	key := pairKey(serial1, serial2)
+	if simState.Encounters == nil {
+		simState.Encounters = map[string][]*Encounter{}
+	}
+	encounter := &Encounter{
+		ID:      fmt.Sprintf("ENC-%s-%s-%d", serial1, serial2, len(simState.Encounters[key])+1),
+		Planes:  [2]string{serial1, serial2},
+		Outcome: EncounterOngoing,
+	}
+	simState.Encounters[key] = append(simState.Encounters[key], encounter)
+	return encounter
+}
+
+// recordEncounter records a change of the lifecycle of an encounter for replays. The encounter is copied, as it
+// carries on changing afterwards.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) recordEncounter(encounter *Encounter) {
+	recorded := *encounter
+	simState.record(ReplayEntry{Kind: ReplayEncounter, Plane: encounter.Planes[0], OtherPlane: encounter.Planes[1], Encounter: &recorded})
+}
+
+// updateEncounters runs one cycle of the lifecycle of the encounters, once every plane has its threats for the cycle.
+// A pair of planes with an advisory of either of them against the other is in an encounter, opened by the first
+// TA or RA; its RA and the changes of it are taken from the engagement, and the closest point of approach from
+// the tracks of the planes. An encounter whose planes no longer have any advisory against each other is clear of
+// conflict, one whose planes collided is over as well.
+// It must be called with simState.Mu held, with the tracks of the planes in flight.
+func updateEncounters(simState *SimulationState, planes []*Plane, tracks map[*Plane]Track) {
+	simTime := simState.CurrentSimTime
+	bySerial := map[string]*Plane{}
+	for _, p := range planes {
+		bySerial[p.Serial] = p
+	}
+
+	inAdvisory := map[string]bool{}
+	for _, plane := range planes {
+		for _, threat := range plane.TCASThreats {
+			other := threat.OtherPlaneSerial
+			if other == plane.Serial {
+				other = threat.PlaneSerial
+			}
+			intruder := bySerial[other]
+			if intruder == nil {
+				continue
+			}
+			key := pairKey(plane.Serial, other)
+			inAdvisory[key] = true
+			// Planes that collided stay in their encounter, it is over
+			if encounters := simState.Encounters[key]; len(encounters) > 0 && encounters[len(encounters)-1].Outcome == EncounterCrashed {
+				continue
+			}
+
+			encounter := simState.openEncounter(plane.Serial, other)
+			changed := false
+			if encounter == nil {
+				encounter = simState.startEncounter(plane.Serial, other)
+				changed = true
+			}
+			if !threat.Engaged && encounter.TAStart.IsZero() && encounter.RAIssued.IsZero() {
+				encounter.TAStart = simTime
+				changed = true
+			}
+			if threat.Engaged && encounter.EngagementID == "" {
+				encounter.RAIssued = threat.TimeOfEngagement
+				encounter.EngagementID = threat.EngagementID
+				changed = true
+			}
+			if threat.Engaged && threat.EngagementID == encounter.EngagementID && len(threat.Transitions) != len(encounter.RAChanges) {
+				encounter.RAChanges = append([]RATransition{}, threat.Transitions...)
+				changed = true
+			}
+
+			// Planes taking off from or landing at the same airport meet there, which is no NMAC, as it is no collision
+			horizontal, vertical := closestApproachSince(tracks[plane], tracks[intruder], TCASCheckInterval.Seconds())
+			atRunways := atRunway(plane, tracks[plane]) || atRunway(intruder, tracks[intruder])
+			if !encounter.ClosestApproach.NMAC && !atRunways && isNMAC(horizontal, vertical) {
+				fmt.Fprintf(simState.TCASLog, "%s NMAC between %s and %s: %.0f ft horizontally and %.0f ft vertically apart.\n\n",
+					simTime.Format("2006-01-02 15:04:05"), encounter.Planes[0], encounter.Planes[1],
+					horizontal/tcasRangeScale/FeetToMeters, vertical/FeetToMeters)
+				changed = true
+			}
+			encounter.ClosestApproach.observe(simTime, horizontal, vertical, !atRunways)
+			if changed {
+				simState.recordEncounter(encounter)
+			}
+		}
+	}
+
+	// The pairs are taken in order, so replays are recorded the same way every run
+	keys := []string{}
+	for key := range simState.Encounters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		encounters := simState.Encounters[key]
+		encounter := encounters[len(encounters)-1]
+		if encounter.Outcome != EncounterOngoing || inAdvisory[key] {
+			continue
+		}
+		encounter.ClearOfConflict = simTime
+		encounter.Outcome = EncounterTAOnly
+		if encounter.EngagementID != "" {
+			encounter.Outcome = EncounterResolved
+		}
+		simState.recordEncounter(encounter)
+		storeMissDistance(simState, *encounter)
+	}
+}
+
+// storeMissDistance copies the miss distances of an encounter that is over to its RA engagement.
+// Encounters without an RA have no engagement.
+// It must be called with simState.Mu held.
+func storeMissDistance(simState *SimulationState, encounter Encounter) {
+	if record, ok := simState.Engagements[encounter.EngagementID]; ok {
+		miss := encounter.ClosestApproach
+		record.Miss = &miss
+	}
+}
+
+// crashEncounter ends the encounter of two planes that collided, with the distances at their closest point of
+// approach. Planes that collided without any advisory have an encounter as well.
+// It must be called with simState.Mu held.
+func crashEncounter(simState *SimulationState, serial1, serial2 string, horizontal, vertical float64) {
+	encounter := simState.openEncounter(serial1, serial2)
+	if encounter == nil {
+		encounter = simState.startEncounter(serial1, serial2)
+	}
+	// The collision is the closest approach of the planes, however close they came before
+	encounter.ClosestApproach.Time = time.Time{}
+	encounter.ClosestApproach.observe(simState.CurrentSimTime, horizontal, vertical, true)
+	encounter.Outcome = EncounterCrashed
+	simState.recordEncounter(encounter)
+	storeMissDistance(simState, *encounter)
+}
+
+// applyEncounter stores an encounter read from a replay, replacing the earlier record of it.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) applyEncounter(encounter Encounter) {
+	key := pairKey(encounter.Planes[0], encounter.Planes[1])
+	for i, e := range simState.Encounters[key] {
+		if e.ID == encounter.ID {
+			simState.Encounters[key][i] = &encounter
+			return
+		}
+	}
+	if simState.Encounters == nil {
+		simState.Encounters = map[string][]*Encounter{}
+	}
+	simState.Encounters[key] = append(simState.Encounters[key], &encounter)
+}
+
+// EncounterList returns a copy of every encounter of the simulation, in the order they started.
+func (simState *SimulationState) EncounterList() []Encounter {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+
+	list := []Encounter{}
+	for _, encounters := range simState.Encounters {
+		for _, e := range encounters {
+			list = append(list, *e)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Start().Equal(list[j].Start()) {
+			return list[i].Start().Before(list[j].Start())
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
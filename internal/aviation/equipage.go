@@ -0,0 +1,206 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// TCASCapability defines the collision avoidance equipage of a plane: its transponder and the TCAS fitted to it.
+// The values are stored in snapshots and replays, new ones are only ever added at the end.
+type TCASCapability int
+
+// The equipages a plane can be fitted with.
+const (
+	TCASPerfect       TCASCapability = iota // 0, a working TCAS II, it issues TAs and RAs and coordinates them
+	TCASFaulty                              // 1, a failed TCAS II, its Mode S transponder still replies but it issues no advisory
+	TCASNoTransponder                       // no transponder at all, the plane is invisible to TCAS
+	TCASModeC                               // a Mode C transponder only, it reports its altitude in 100 ft steps and cannot coordinate
+	TCASModeS                               // a Mode S transponder without TCAS, it reports its altitude in 25 ft steps and cannot coordinate
+	TCASI                                   // TCAS I, it issues Traffic Advisories only
+	TCASIITAOnly                            // a TCAS II switched to TA-only mode, it issues TAs and its RAs are inhibited
+	tcasCapabilityCount
+)
+
+// Resolution, in feet, of the altitudes transponders report: Mode C encodes them in 100 ft steps (Gillham code),
+// Mode S in 25 ft steps. Every TCAS is fitted with a Mode S transponder.
+const (
+	ModeCAltitudeStep = 100.0
+	ModeSAltitudeStep = 25.0
+)
+
+// DefaultFaultyTCASRatio is the share of the fleet fitted with a faulty TCAS when none is configured
+const DefaultFaultyTCASRatio = 0.25
+
+// String returns a short name for the TCAS capability, used in logs, batch statistics and fleet mixes.
+func (c TCASCapability) String() string {
+	switch c {
+	case TCASPerfect:
+		return "perfect"
+	case TCASFaulty:
+		return "faulty"
+	case TCASNoTransponder:
+		return "none"
+	case TCASModeC:
+		return "mode_c"
+	case TCASModeS:
+		return "mode_s"
+	case TCASI:
+		return "tcas_i"
+	case TCASIITAOnly:
+		return "ta_only"
+	default:
+		return "unknown"
+	}
+}
+
+// Label returns the description of the capability shown to users.
+func (c TCASCapability) Label() string {
+	switch c {
+	case TCASPerfect:
+		return "TCAS II, working perfectly"
+	case TCASFaulty:
+		return "TCAS II, faulty"
+	case TCASNoTransponder:
+		return "No transponder"
+	case TCASModeC:
+		return "Mode C transponder, no TCAS"
+	case TCASModeS:
+		return "Mode S transponder, no TCAS"
+	case TCASI:
+		return "TCAS I, traffic advisories only"
+	case TCASIITAOnly:
+		return "TCAS II in TA-only mode"
+	default:
+		return "Unknown"
+	}
+}
+
+// parseTCASCapability returns the capability with the given short name, see TCASCapability.String.
+func parseTCASCapability(name string) (TCASCapability, bool) {
+	for c := TCASPerfect; c < tcasCapabilityCount; c++ {
+		if c.String() == name {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// reportsAltitude reports whether the plane has a transponder replying with its altitude.
+// TCAS only tracks those planes, a plane without a transponder is never the intruder of an advisory.
+func (c TCASCapability) reportsAltitude() bool {
+	return c != TCASNoTransponder
+}
+
+// reportedAltitude returns the altitude, in meters, the transponder of the plane reports for its actual altitude,
+// rounded to the resolution of its mode. TCAS and the ground radar only know the altitudes of other planes from it.
+func (c TCASCapability) reportedAltitude(altitude float64) float64 {
+	step := ModeSAltitudeStep
+	if c == TCASModeC {
+		step = ModeCAltitudeStep
+	}
+	return math.Round(altitude/FeetToMeters/step) * step * FeetToMeters
+}
+
+// issuesTAs reports whether the TCAS of the plane gives its crew Traffic Advisories.
+func (c TCASCapability) issuesTAs() bool {
+	return c == TCASPerfect || c == TCASI || c == TCASIITAOnly
+}
+
+// issuesRAs reports whether the TCAS of the plane gives its crew Resolution Advisories.
+// Only a working TCAS II in TA/RA mode does, and only its RAs are coordinated with the intruder: against any other
+// intruder the RA is one-sided, chosen on the assumption that the intruder carries on as it flies.
+func (c TCASCapability) issuesRAs() bool {
+	return c == TCASPerfect
+}
+
+// FleetMix is the share of the fleet, between 0 and 1, fitted with each equipage. The shares add up to 1.
+type FleetMix map[TCASCapability]float64
+
+// NewFleetMix returns the fleet mix of the configuration, which is keyed by the short names of the capabilities.
+// Without a configured mix, the fleet is made of working and faulty TCAS II as the faulty TCAS ratio says.
+func NewFleetMix(conf *config.Config) FleetMix {
+	if len(conf.FleetMix) == 0 {
+		return FleetMix{TCASPerfect: 1 - conf.FaultyTCASRatio, TCASFaulty: conf.FaultyTCASRatio}
+	}
+	mix := FleetMix{}
+	for name, share := range conf.FleetMix {
+		if c, ok := parseTCASCapability(name); ok {
+			mix[c] = share
+		}
+	}
+	return mix
+}
+
+// ParseFleetMix parses a fleet mix written as comma separated name=share pairs, such as
+// "perfect=0.6,mode_s=0.3,none=0.1", with the short names of the capabilities. The shares must add up to 1.
+func ParseFleetMix(s string) (map[string]float64, error) {
+	mix := map[string]float64{}
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("the fleet mix entry %q is not of the form name=share", pair)
+		}
+		if _, known := parseTCASCapability(name); !known {
+			return nil, fmt.Errorf("unknown equipage %q in the fleet mix, use one of %s", name, strings.Join(capabilityNames(), ", "))
+		}
+		share, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("the share of %s in the fleet mix is not a number: %q", name, value)
+		}
+		mix[name] += share
+	}
+	if err := NewFleetMix(&config.Config{FleetMix: mix}).Validate(); err != nil {
+		return nil, err
+	}
+	return mix, nil
+}
+
+// capabilityNames returns the short names of every capability.
+func capabilityNames() []string {
+	names := []string{}
+	for c := TCASPerfect; c < tcasCapabilityCount; c++ {
+		names = append(names, c.String())
+	}
+	return names
+}
+
+// Validate reports an error if a share is outside [0, 1] or the shares do not add up to 1.
+func (m FleetMix) Validate() error {
+	total := 0.0
+	for c, share := range m {
+		if share < 0 || share > 1 {
+			return fmt.Errorf("the share of %s in the fleet mix must be between 0 and 1, got %v", c, share)
+		}
+		total += share
+	}
+	if math.Abs(total-1) > 1e-6 {
+		return fmt.Errorf("the shares of the fleet mix must add up to 1, got %v", total)
+	}
+	return nil
+}
+
+// Names returns the fleet mix keyed by the short names of the capabilities, as it is configured.
+func (m FleetMix) Names() map[string]float64 {
+	names := map[string]float64{}
+	for c, share := range m {
+		names[c.String()] = share
+	}
+	return names
+}
+
+// draw picks the equipage of a new plane, each with the odds of its share of the fleet.
+func (m FleetMix) draw(r *rand.Rand) TCASCapability {
+	x := r.Float64()
+	for c := TCASPerfect; c < tcasCapabilityCount; c++ {
+		x -= m[c]
+		if x < 0 {
+			return c
+		}
+	}
+	return TCASPerfect
+}
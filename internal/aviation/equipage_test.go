@@ -0,0 +1,51 @@
+package aviation
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReportedAltitude(t *testing.T) {
+	tests := []struct {
+		name       string
+		capability TCASCapability
+		feet       float64
+		want       float64
+	}{
+		{name: "Mode C rounds down", capability: TCASModeC, feet: 32841.2, want: 32800},
+		{name: "Mode C rounds up", capability: TCASModeC, feet: 10560, want: 10600},
+		{name: "Mode S rounds up", capability: TCASModeS, feet: 32841.2, want: 32850},
+		{name: "Mode S rounds down", capability: TCASModeS, feet: 10560, want: 10550},
+		{name: "TCAS II replies in Mode S", capability: TCASPerfect, feet: 10560, want: 10550},
+		{name: "failed TCAS II still replies in Mode S", capability: TCASFaulty, feet: 10560, want: 10550},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.capability.reportedAltitude(tt.feet*FeetToMeters) / FeetToMeters
+			if math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("reportedAltitude(%v ft) = %v ft, want %v ft", tt.feet, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReportedAltitudeVerticalTest(t *testing.T) {
+	// Own is level at 10,000 ft and the intruder level 560 ft above, within the RA ZTHR of 600 ft. A Mode S intruder
+	// reports 10,550 ft and stays within it, a Mode C one reports 10,600 ft and falls outside.
+	own, intruder := 10000*FeetToMeters, 10560*FeetToMeters
+	tests := []struct {
+		capability TCASCapability
+		want       bool
+	}{
+		{capability: TCASModeS, want: true},
+		{capability: TCASModeC, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.capability.String(), func(t *testing.T) {
+			separation := tt.capability.reportedAltitude(intruder) - own
+			if got := verticalTest(separation, 0, 35, 600); got != tt.want {
+				t.Errorf("verticalTest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package aviation
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventKind identifies what happens when a scheduled event is processed.
+type EventKind int
+
+// EventKind defines every kind of event the simulation engine processes.
+const (
+	EventLaunchAttempt   EventKind = iota // an airport tries to launch its next parked plane
+	EventTakeoffComplete                  // a plane leaves the runway (releasing it) and its flight begins
+	EventLandingRequest                   // a plane reaches its destination and asks for a runway
+	EventLandingComplete                  // a plane has landed, the runway is released and the plane is parked
+	EventTCASCheck                        // one cycle of the TCAS proximity detection
+	EventRadarSweep                       // one sweep of the ground radar and its Short-Term Conflict Alert
+	EventCrashShutdown                    // the simulation halts after a collision
+	EventSimulationEnd                    // the configured duration of the simulation has been reached
+)
+
+// String returns a readable name for the event kind, used in logs.
+func (k EventKind) String() string {
+	switch k {
+	case EventLaunchAttempt:
+		return "launch attempt"
+	case EventTakeoffComplete:
+		return "takeoff complete"
+	case EventLandingRequest:
+		return "landing request"
+	case EventLandingComplete:
+		return "landing complete"
+	case EventTCASCheck:
+		return "TCAS check"
+	case EventRadarSweep:
+		return "radar sweep"
+	case EventCrashShutdown:
+		return "crash shutdown"
+	case EventSimulationEnd:
+		return "simulation end"
+	default:
+		return "unknown event"
+	}
+}
+
+// parseEventKind returns the event kind with the given name, as returned by String.
+func parseEventKind(name string) (EventKind, bool) {
+	for k := EventLaunchAttempt; k <= EventSimulationEnd; k++ {
+		if k.String() == name {
+			return k, true
+		}
+	}
+	return 0, false
+}
+
+// Event is a single state change scheduled to happen at a given simulation time.
+// Airport and Plane are set only for the kinds of event that concern them.
+type Event struct {
+	Time    time.Time
+	Kind    EventKind
+	Airport *Airport
+	Plane   *Plane
+
+	seq uint64 // order in which the event was scheduled, breaks ties between events due at the same time
+}
+
+// eventQueue is a priority queue of events ordered by simulation time, it implements heap.Interface.
+type eventQueue []*Event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].Time.Equal(q[j].Time) {
+		return q[i].Time.Before(q[j].Time)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*Event)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	n := len(old)
+	ev := old[n-1]
+	old[n-1] = nil
+	*q = old[:n-1]
+	return ev
+}
+
+// EventScheduler holds the events of a simulation that are still to be processed.
+// Events due at the same simulation time are processed in the order they were scheduled,
+// which keeps runs with the same seed identical.
+type EventScheduler struct {
+	mu      sync.Mutex
+	queue   eventQueue
+	nextSeq uint64
+}
+
+// newEventScheduler creates an empty EventScheduler.
+func newEventScheduler() *EventScheduler {
+	return &EventScheduler{queue: eventQueue{}}
+}
+
+// Schedule adds an event to the queue.
+func (s *EventScheduler) Schedule(ev *Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ev.seq = s.nextSeq
+	s.nextSeq++
+	heap.Push(&s.queue, ev)
+}
+
+// Peek returns the next event due without removing it, or nil if the queue is empty.
+func (s *EventScheduler) Peek() *Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil
+	}
+	return s.queue[0]
+}
+
+// Pop removes and returns the next event due, or nil if the queue is empty.
+func (s *EventScheduler) Pop() *Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return nil
+	}
+	return heap.Pop(&s.queue).(*Event)
+}
+
+// Len returns the number of events waiting to be processed.
+func (s *EventScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// pending returns a copy of the events waiting to be processed, in the order they will be processed.
+func (s *EventScheduler) pending() []*Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := make([]*Event, len(s.queue))
+	copy(events, s.queue)
+	sort.Slice(events, func(i, j int) bool { return eventQueue(events).Less(i, j) })
+	return events
+}
@@ -2,6 +2,7 @@ package aviation
 
 import (
 	"fmt"
+	"math"
 	"time"
 )
 
@@ -17,6 +18,308 @@ type Flight struct {
 	FlightStatus           string
 	ActualLandingTime      time.Time
 	FlightPath             FlightPath
+	VerticalProfile        []VerticalManeuver // altitude changes ordered by time, the plane flies level at CruisingAltitude before the first
+}
+
+// TerminalAltitude is the altitude, in meters, at which planes leave the terminal area of their departure airport
+// and join the one of their destination. It is below the lowest cruising altitude, so a plane climbing to a higher
+// level, or descending from it, crosses the levels of the other planes.
+const TerminalAltitude = 9000.0
+
+// TerminalAreaRadius is the distance, in map units, from an airport within which departures climb from its elevation
+// to TerminalAltitude and arrivals descend from TerminalAltitude to its elevation. Like the distances between the
+// airports it is much shorter than in real flight, so the planes cross it far more steeply than real ones.
+const TerminalAreaRadius = 50.0
+
+// InitialClimbHeight is the height above the airport, in meters (1000 ft), up to which departures climb at ClimbRate
+// and from which arrivals descend at DescentRate, as real planes do near the ground. The low-altitude sensitivity
+// levels of TCAS, and its RA inhibitions, apply to them there.
+const InitialClimbHeight = 1000 * FeetToMeters
+
+// ClimbRate is the vertical rate of the climb to the cruising altitude (2500 ft/min), in meters per simulation second
+const ClimbRate = 2500 * FeetToMeters / 60 / TCASTimeScale
+
+// DescentRate is the vertical rate of the descent to the destination (2000 ft/min), in meters per simulation second
+const DescentRate = 2000 * FeetToMeters / 60 / TCASTimeScale
+
+// Reasons of the planned vertical manoeuvres of a flight
+const (
+	ManeuverClimb    = "climb"
+	ManeuverDescent  = "descent"
+	ManeuverApproach = "approach"
+)
+
+// VerticalManeuver is a change of the vertical profile of a flight: from Time, the plane moves at VerticalRate
+// from Altitude until it reaches TargetAltitude, where it levels off. With an Acceleration, the plane first
+// changes its vertical rate from InitialRate to VerticalRate, otherwise it takes the new rate at once.
+type VerticalManeuver struct {
+	Time           time.Time
+	Altitude       float64 // Meters, altitude at Time
+	InitialRate    float64 // Meters per simulation second, vertical rate at Time
+	VerticalRate   float64 // Meters per simulation second, positive when climbing
+	Acceleration   float64 // Meters per simulation second squared, 0 for an immediate change of rate
+	TargetAltitude float64 // Meters
+	Reason         string  // what caused the manoeuvre, e.g. "climb RA" or "clear of conflict"
+}
+
+// stateAfter returns the altitude and vertical rate of the plane dt simulation seconds into the manoeuvre.
+func (m VerticalManeuver) stateAfter(dt float64) (altitude, verticalRate float64) {
+	// A level off holds the altitude it starts at, or with an acceleration the one reached once the plane stops climbing or descending
+	if m.VerticalRate == 0 && (m.Acceleration <= 0 || m.InitialRate == 0) {
+		return m.Altitude, 0
+	}
+
+	altitude, verticalRate = m.Altitude+m.VerticalRate*dt, m.VerticalRate
+	if m.Acceleration > 0 && m.InitialRate != m.VerticalRate {
+		acceleration := m.Acceleration
+		if m.VerticalRate < m.InitialRate {
+			acceleration = -acceleration
+		}
+		ramp := (m.VerticalRate - m.InitialRate) / acceleration
+		if dt < ramp {
+			altitude = m.Altitude + m.InitialRate*dt + acceleration*dt*dt/2
+			verticalRate = m.InitialRate + acceleration*dt
+		} else {
+			altitude = m.Altitude + m.InitialRate*ramp + acceleration*ramp*ramp/2 + m.VerticalRate*(dt-ramp)
+		}
+	}
+
+	if (m.VerticalRate > 0 && altitude >= m.TargetAltitude) || (m.VerticalRate < 0 && altitude <= m.TargetAltitude) {
+		return m.TargetAltitude, 0
+	}
+	return altitude, verticalRate
+}
+
+// flightPhase is a part of the flight plan, from start to end: the plane flies from whatever altitude it is at when
+// the phase starts to target, at VerticalRate or, without one, at the rate that takes it there at the end.
+type flightPhase struct {
+	Start, End   time.Time
+	Reason       string
+	Target       float64 // Meters
+	VerticalRate float64 // Meters per simulation second, 0 to reach Target at End
+	EnRoute      bool    // the climb to and cruise at the cruising altitude, between the terminal areas
+}
+
+// maneuver returns the manoeuvre of the phase for a plane at altitude at simTime, false when it is already there.
+func (p flightPhase) maneuver(simTime time.Time, altitude float64) (VerticalManeuver, bool) {
+	if math.Abs(p.Target-altitude) < 1 {
+		return VerticalManeuver{}, false
+	}
+	rate := p.VerticalRate
+	if rate == 0 {
+		remaining := p.End.Sub(simTime).Seconds()
+		if remaining <= 0 {
+			return VerticalManeuver{}, false
+		}
+		rate = math.Abs(p.Target-altitude) / remaining
+	}
+	if p.Target < altitude {
+		rate = -rate
+	}
+	return VerticalManeuver{Time: simTime, Altitude: altitude, VerticalRate: rate, TargetAltitude: p.Target,
+		Reason: p.Reason}, true
+}
+
+// terminalDuration returns how long the plane takes to cross the terminal area of an airport, at most half the flight.
+func (f Flight) terminalDuration() time.Duration {
+	duration := f.DestinationArrivalTime.Sub(f.TakeoffTime)
+	distance := math.Hypot(f.FlightSchedule.Destination.X-f.FlightSchedule.Depature.X,
+		f.FlightSchedule.Destination.Y-f.FlightSchedule.Depature.Y)
+	if distance <= 2*TerminalAreaRadius {
+		return duration / 2
+	}
+	return time.Duration(float64(duration) * TerminalAreaRadius / distance)
+}
+
+// phases returns the flight plan of the vertical profile, in order: the initial climb from the departure airport,
+// the climb to TerminalAltitude across its terminal area, the climb to the cruising altitude, the descent to
+// TerminalAltitude from the top of descent, the approach across the terminal area of the destination and the final
+// descent to it. Phases a flight is too short for are left out.
+func (f Flight) phases() []flightPhase {
+	terminal := f.terminalDuration()
+	seconds := func(s float64) time.Duration { return min(time.Duration(s*float64(time.Second)), terminal/2) }
+	initialClimb, finalDescent := seconds(InitialClimbHeight/ClimbRate), seconds(InitialClimbHeight/DescentRate)
+	departure, arrival := f.FlightSchedule.Depature.Z, f.FlightSchedule.Destination.Z
+	enRouteStart := f.TakeoffTime.Add(terminal)
+	enRouteEnd := f.DestinationArrivalTime.Add(-terminal)
+	topOfDescent := f.TopOfDescent()
+
+	phases := []flightPhase{
+		{Start: f.TakeoffTime, End: f.TakeoffTime.Add(initialClimb), Reason: ManeuverClimb,
+			Target: departure + InitialClimbHeight, VerticalRate: ClimbRate},
+		{Start: f.TakeoffTime.Add(initialClimb), End: enRouteStart, Reason: ManeuverClimb, Target: TerminalAltitude},
+		{Start: enRouteStart, End: topOfDescent, Reason: ManeuverClimb, Target: f.CruisingAltitude, VerticalRate: ClimbRate,
+			EnRoute: true},
+		{Start: topOfDescent, End: enRouteEnd, Reason: ManeuverDescent, Target: TerminalAltitude, VerticalRate: DescentRate},
+		{Start: enRouteEnd, End: f.DestinationArrivalTime.Add(-finalDescent), Reason: ManeuverApproach,
+			Target: arrival + InitialClimbHeight},
+		{Start: f.DestinationArrivalTime.Add(-finalDescent), End: f.DestinationArrivalTime, Reason: ManeuverApproach,
+			Target: arrival},
+	}
+	planned := []flightPhase{}
+	for _, phase := range phases {
+		if phase.End.After(phase.Start) {
+			planned = append(planned, phase)
+		}
+	}
+	return planned
+}
+
+// phaseAt returns the phase of the flight plan at simTime, false before the takeoff and after the arrival.
+func (f Flight) phaseAt(simTime time.Time) (flightPhase, bool) {
+	for _, phase := range f.phases() {
+		if !simTime.Before(phase.Start) && simTime.Before(phase.End) {
+			return phase, true
+		}
+	}
+	return flightPhase{}, false
+}
+
+// started reports whether the vertical profile holds a manoeuvre of the phase, which is then under way.
+func (f Flight) started(phase flightPhase) bool {
+	for _, maneuver := range f.VerticalProfile {
+		if maneuver.Reason == phase.Reason && !maneuver.Time.Before(phase.Start) {
+			return true
+		}
+	}
+	return false
+}
+
+// climbManeuver returns the planned initial climb of a flight from the elevation of its departure airport.
+func climbManeuver(f Flight) VerticalManeuver {
+	phase := f.phases()[0]
+	maneuver, _ := phase.maneuver(f.TakeoffTime, f.FlightSchedule.Depature.Z)
+	return maneuver
+}
+
+// plannedProfile returns the flight with the manoeuvre of every phase of its plan not started yet added to its
+// vertical profile, as if the plane starts each phase on time. A plane in an RA starts it later.
+func (f Flight) plannedProfile() Flight {
+	planned := f
+	for _, phase := range f.phases() {
+		if planned.started(phase) {
+			continue
+		}
+		if maneuver, ok := phase.maneuver(phase.Start, planned.AltitudeAt(phase.Start)); ok {
+			planned.insertManeuver(maneuver)
+		}
+	}
+	return planned
+}
+
+// TopOfDescent returns the time at which the plane starts its descent to reach TerminalAltitude as it enters the
+// terminal area of its destination. A flight too short to reach its cruising altitude starts the descent where its
+// climb and descent meet.
+func (f Flight) TopOfDescent() time.Time {
+	terminal := f.terminalDuration()
+	enRouteStart := f.TakeoffTime.Add(terminal)
+	enRouteEnd := f.DestinationArrivalTime.Add(-terminal)
+	descent := (f.CruisingAltitude - TerminalAltitude) / DescentRate
+	topOfDescent := enRouteEnd.Add(-time.Duration(descent * float64(time.Second)))
+
+	duration := enRouteEnd.Sub(enRouteStart)
+	meeting := enRouteStart.Add(time.Duration(float64(duration) * DescentRate / (ClimbRate + DescentRate)))
+	if meeting.After(topOfDescent) {
+		return meeting
+	}
+	return topOfDescent
+}
+
+// followFlightPlans starts the phase of the flight plan of every plane in flight that reached it, see Flight.phases.
+// A plane in an RA follows the RA first, it starts the phase once it is clear of conflict.
+// It must be called with simState.Mu held.
+func followFlightPlans(simState *SimulationState) {
+	simTime := simState.CurrentSimTime
+	for _, p := range simState.PlanesInFlight {
+		flight := p.FlightLog[len(p.FlightLog)-1]
+		phase, ok := flight.phaseAt(simTime)
+		if !ok || flight.started(phase) || hasActiveRA(simState, p) {
+			continue
+		}
+		if maneuver, ok := phase.maneuver(simTime, flight.AltitudeAt(simTime)); ok {
+			addManeuver(simState, p, maneuver)
+		}
+	}
+}
+
+// AltitudeAt returns the altitude of the plane at simTime following the vertical profile of the flight.
+func (f Flight) AltitudeAt(simTime time.Time) float64 {
+	altitude, _ := f.verticalStateAt(simTime)
+	return altitude
+}
+
+// VerticalRateAt returns the vertical rate of the plane at simTime, zero when it flies level.
+func (f Flight) VerticalRateAt(simTime time.Time) float64 {
+	_, rate := f.verticalStateAt(simTime)
+	return rate
+}
+
+// verticalStateAt applies the last manoeuvre started at or before simTime.
+func (f Flight) verticalStateAt(simTime time.Time) (altitude, verticalRate float64) {
+	var current *VerticalManeuver
+	for i := range f.VerticalProfile {
+		if f.VerticalProfile[i].Time.After(simTime) {
+			break
+		}
+		current = &f.VerticalProfile[i]
+	}
+	if current == nil {
+		return f.CruisingAltitude, 0
+	}
+	return current.stateAfter(simTime.Sub(current.Time).Seconds())
+}
+
+// insertManeuver adds a manoeuvre to the vertical profile in order of time. A manoeuvre can start in the future,
+// e.g. once the crew responds to an RA, so the manoeuvres after the new one are made to start from the altitude
+// and rate the profile now gives them. The profile is copied, so profiles shared with a replay timeline are not changed.
+func (f *Flight) insertManeuver(maneuver VerticalManeuver) {
+	i := len(f.VerticalProfile)
+	for i > 0 && f.VerticalProfile[i-1].Time.After(maneuver.Time) {
+		i--
+	}
+	profile := make([]VerticalManeuver, 0, len(f.VerticalProfile)+1)
+	profile = append(profile, f.VerticalProfile[:i]...)
+	profile = append(profile, maneuver)
+	f.VerticalProfile = append(profile, f.VerticalProfile[i:]...)
+	f.rebaseManeuvers(i)
+}
+
+// removeManeuver removes a manoeuvre that has not started yet from the vertical profile.
+// It reports whether the manoeuvre was found.
+func (f *Flight) removeManeuver(maneuver VerticalManeuver) bool {
+	for i, m := range f.VerticalProfile {
+		if m.Time.Equal(maneuver.Time) && m.Reason == maneuver.Reason {
+			profile := append([]VerticalManeuver{}, f.VerticalProfile[:i]...)
+			f.VerticalProfile = append(profile, f.VerticalProfile[i+1:]...)
+			f.rebaseManeuvers(i)
+			return true
+		}
+	}
+	return false
+}
+
+// rebaseManeuvers makes the manoeuvres of the profile from index from start where the manoeuvres before them leave the plane.
+func (f *Flight) rebaseManeuvers(from int) {
+	for i := max(from, 1); i < len(f.VerticalProfile); i++ {
+		previous := Flight{CruisingAltitude: f.CruisingAltitude, VerticalProfile: f.VerticalProfile[:i]}
+		f.VerticalProfile[i].Altitude, f.VerticalProfile[i].InitialRate = previous.verticalStateAt(f.VerticalProfile[i].Time)
+	}
+}
+
+// pendingManeuvers returns the manoeuvres of the profile that start after simTime.
+func (f Flight) pendingManeuvers(simTime time.Time) []VerticalManeuver {
+	pending := []VerticalManeuver{}
+	for _, m := range f.VerticalProfile {
+		if m.Time.After(simTime) {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// verticalSpeedFPM converts a vertical rate in meters per simulation second to feet per minute of real flight.
+func verticalSpeedFPM(verticalRate float64) float64 {
+	return math.Round(verticalRate * TCASTimeScale * 60 / FeetToMeters)
 }
 
 // FlightPath to store the movement of plane from one location to the other
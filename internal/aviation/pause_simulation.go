@@ -0,0 +1,55 @@
+package aviation
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// errSimulationNotRunning is returned by the pause, resume and step controls when there is no simulation to control.
+var errSimulationNotRunning = errors.New("no simulation is running")
+
+// Pause freezes the running simulation: plane positions, runway timers and TCAS evaluation
+// all hold still until the simulation is resumed or stepped.
+func (simState *SimulationState) Pause() error {
+	if !simState.SimIsRunning || simState.Clock == nil {
+		return errSimulationNotRunning
+	}
+	simState.Clock.Pause()
+
+	log.Printf("\n--- Simulation paused at %s ---\n", simState.Clock.Now().Format("15:04:05"))
+	fmt.Fprintf(simState.ConsoleLog, "%s\n--- Simulation paused ---\n",
+		simState.Clock.Now().Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// Resume lets a paused simulation run again at its current speed.
+func (simState *SimulationState) Resume() error {
+	if !simState.SimIsRunning || simState.Clock == nil {
+		return errSimulationNotRunning
+	}
+	simState.Clock.Resume()
+
+	log.Printf("\n--- Simulation resumed at %s ---\n", simState.Clock.Now().Format("15:04:05"))
+	fmt.Fprintf(simState.ConsoleLog, "%s\n--- Simulation resumed ---\n",
+		simState.Clock.Now().Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// Step advances a paused simulation by d of simulation time and pauses it again,
+// a running simulation is paused first. It returns the simulation time reached by the step.
+func (simState *SimulationState) Step(d time.Duration) (time.Time, error) {
+	if !simState.SimIsRunning || simState.Clock == nil {
+		return time.Time{}, errSimulationNotRunning
+	}
+	if d <= 0 {
+		return time.Time{}, fmt.Errorf("step duration must be positive, got %s", d)
+	}
+	reached := simState.Clock.Step(d)
+
+	log.Printf("\n--- Simulation stepped by %s to %s ---\n", d, reached.Format("15:04:05"))
+	fmt.Fprintf(simState.ConsoleLog, "%s\n--- Simulation stepped by %s ---\n",
+		reached.Format("2006-01-02 15:04:05"), d)
+	return reached, nil
+}
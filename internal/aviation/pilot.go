@@ -0,0 +1,279 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// Responses of a crew to a Resolution Advisory
+const (
+	ResponseComplied = "complied" // the crew flew the RA
+	ResponseIgnored  = "ignored"  // the crew did not respond to the RA
+	ResponseOpposite = "opposite" // the crew manoeuvred against the sense of the RA
+	ResponseNoRA     = "no RA"    // the plane got no RA, without a working TCAS II (TCAS I, TA-only, Mode C or S, no transponder, faulty) or too low for RAs
+	ResponseUnknown  = "unknown"  // the engagement was recorded before crew responses were modelled
+)
+
+// Gravity converts the vertical acceleration of a crew, given in g, to m/s²
+const Gravity = 9.80665
+
+// PilotModel describes how the crew of a plane responds to Resolution Advisories. Times and rates are those of real
+// flight; like the TCAS II thresholds, they are scaled by TCASTimeScale to the simulated world.
+type PilotModel struct {
+	ResponseDelay            float64 `json:"response_delay"`             // seconds before the crew starts flying an initial RA
+	StrengthenedDelay        float64 `json:"strengthened_delay"`         // seconds before the crew follows a strengthened or reversed RA
+	VerticalAcceleration     float64 `json:"vertical_acceleration"`      // g, how quickly the crew changes the vertical rate
+	VerticalRate             float64 `json:"vertical_rate"`              // ft/min, vertical rate the crew achieves during an RA
+	NonComplianceProbability float64 `json:"non_compliance_probability"` // chance, between 0 and 1, that the crew ignores an RA
+	OppositeProbability      float64 `json:"opposite_probability"`       // chance, between 0 and 1, that the crew manoeuvres the opposite way
+}
+
+// DefaultPilotModel is the standard pilot the TCAS II logic is designed around: the crew responds to an initial RA
+// within 5 s and to a strengthened one within 2.5 s, with a 0.25 g pull to 1500 ft/min, and always complies.
+var DefaultPilotModel = PilotModel{ResponseDelay: 5, StrengthenedDelay: 2.5, VerticalAcceleration: 0.25, VerticalRate: 1500}
+
+// NewPilotModel returns the pilot model configured for the fleet. An acceleration or vertical rate of 0 takes the default one.
+func NewPilotModel(conf *config.Config) PilotModel {
+	pilot := PilotModel{
+		ResponseDelay:            conf.PilotResponseDelay,
+		StrengthenedDelay:        conf.PilotStrengthenedDelay,
+		VerticalAcceleration:     conf.PilotAcceleration,
+		VerticalRate:             conf.PilotVerticalRate,
+		NonComplianceProbability: conf.PilotNonCompliance,
+		OppositeProbability:      conf.PilotOpposite,
+	}
+	if pilot.VerticalAcceleration <= 0 {
+		pilot.VerticalAcceleration = DefaultPilotModel.VerticalAcceleration
+	}
+	if pilot.VerticalRate <= 0 {
+		pilot.VerticalRate = DefaultPilotModel.VerticalRate
+	}
+	return pilot
+}
+
+// Validate checks that the model describes a possible crew.
+func (p PilotModel) Validate() error {
+	switch {
+	case p.ResponseDelay < 0 || p.StrengthenedDelay < 0:
+		return fmt.Errorf("the pilot response delays must not be negative, got %v s and %v s", p.ResponseDelay, p.StrengthenedDelay)
+	case p.VerticalAcceleration <= 0 || p.VerticalRate <= 0:
+		return fmt.Errorf("the pilot vertical acceleration and rate must be positive, got %v g and %v ft/min", p.VerticalAcceleration, p.VerticalRate)
+	case p.NonComplianceProbability < 0 || p.OppositeProbability < 0 || p.NonComplianceProbability+p.OppositeProbability > 1:
+		return fmt.Errorf("the pilot non-compliance and opposite probabilities must be between 0 and 1 together, got %v and %v",
+			p.NonComplianceProbability, p.OppositeProbability)
+	}
+	return nil
+}
+
+// PilotPopulation describes the crews of a fleet: the pilot model of the average crew, and how much the crews differ
+// from it. The crew of each plane is drawn from it when the plane is created, see draw.
+type PilotPopulation struct {
+	Mean             PilotModel
+	DelaySpread      float64 // seconds, standard deviation of the initial response delay between crews
+	ComplianceSpread float64 // how far, either way, the chances that a crew ignores or opposes an RA may be from the mean ones
+}
+
+// NewPilotPopulation returns the crews configured for the fleet.
+func NewPilotPopulation(conf *config.Config) PilotPopulation {
+	return PilotPopulation{
+		Mean:             NewPilotModel(conf),
+		DelaySpread:      conf.PilotDelaySpread,
+		ComplianceSpread: conf.PilotComplianceSpread,
+	}
+}
+
+// Validate checks that the mean model describes a possible crew and that the spreads are possible.
+func (p PilotPopulation) Validate() error {
+	if err := p.Mean.Validate(); err != nil {
+		return err
+	}
+	if p.DelaySpread < 0 || p.ComplianceSpread < 0 || p.ComplianceSpread > 1 {
+		return fmt.Errorf("the pilot delay spread must not be negative and the compliance spread must be between 0 and 1, got %v s and %v",
+			p.DelaySpread, p.ComplianceSpread)
+	}
+	return nil
+}
+
+// draw returns the pilot model of the crew of a new plane. Its response delays follow a normal distribution around
+// the mean ones, the strengthened delay varying half as much, and its chances of ignoring or opposing an RA are
+// uniform within the compliance spread of the mean ones. A population without spread draws nothing, so every crew
+// is the mean one and the other random choices of a seeded simulation do not change.
+func (p PilotPopulation) draw(r *rand.Rand) PilotModel {
+	pilot := p.Mean
+	if p.DelaySpread > 0 {
+		z := r.NormFloat64()
+		pilot.ResponseDelay = math.Max(0, pilot.ResponseDelay+z*p.DelaySpread)
+		pilot.StrengthenedDelay = math.Max(0, pilot.StrengthenedDelay+z*p.DelaySpread/2)
+	}
+	if p.ComplianceSpread > 0 {
+		spread := func(chance float64) float64 {
+			return math.Min(1, math.Max(0, chance+(2*r.Float64()-1)*p.ComplianceSpread))
+		}
+		pilot.NonComplianceProbability = spread(pilot.NonComplianceProbability)
+		pilot.OppositeProbability = spread(pilot.OppositeProbability)
+		if total := pilot.NonComplianceProbability + pilot.OppositeProbability; total > 1 {
+			pilot.NonComplianceProbability /= total
+			pilot.OppositeProbability /= total
+		}
+	}
+	return pilot
+}
+
+// responseDelay returns the simulation time the crew takes to respond to an initial RA.
+func (p PilotModel) responseDelay() time.Duration {
+	return time.Duration(p.ResponseDelay * TCASTimeScale * float64(time.Second))
+}
+
+// strengthenedDelay returns the simulation time the crew takes to follow a strengthened, weakened or reversed RA.
+func (p PilotModel) strengthenedDelay() time.Duration {
+	return time.Duration(p.StrengthenedDelay * TCASTimeScale * float64(time.Second))
+}
+
+// verticalRate returns the vertical rate the crew achieves during an RA, in meters per simulation second.
+// A plane saved before pilots were modelled flies the rate required by TCAS II.
+func (p PilotModel) verticalRate() float64 {
+	if p.VerticalRate <= 0 {
+		return RAVerticalRate
+	}
+	return p.VerticalRate * FeetToMeters / 60 / TCASTimeScale
+}
+
+// acceleration returns the vertical acceleration of the crew in meters per simulation second squared,
+// 0 for a plane saved before pilots were modelled, which changes its rate at once.
+func (p PilotModel) acceleration() float64 {
+	return p.VerticalAcceleration * Gravity / (TCASTimeScale * TCASTimeScale)
+}
+
+// respond draws how the crew responds to an RA.
+func (p PilotModel) respond(r *simRand) string {
+	draw := r.Float64()
+	switch {
+	case draw < p.NonComplianceProbability:
+		return ResponseIgnored
+	case draw < p.NonComplianceProbability+p.OppositeProbability:
+		return ResponseOpposite
+	default:
+		return ResponseComplied
+	}
+}
+
+// pilotManeuver returns the manoeuvre the crew of a plane flies in response to an RA issued at simTime,
+// and false when the crew does not manoeuvre. The manoeuvre starts after the response delay of the crew,
+// who then changes the vertical rate with its acceleration.
+func pilotManeuver(plane *Plane, simTime time.Time, climb bool, response string) (VerticalManeuver, bool) {
+	switch response {
+	case ResponseComplied:
+	case ResponseOpposite:
+		climb = !climb
+	default:
+		return VerticalManeuver{}, false
+	}
+
+	ra := RADescend
+	if climb {
+		ra = RAClimb
+	}
+	return raManeuver(plane, simTime.Add(plane.Pilot.responseDelay()), ra), true
+}
+
+// raManeuver returns the manoeuvre of the crew of a plane flying the given RA from start: up to RAAltitudeDeviation
+// away from its altitude then, at the rate of its pilot model, at the rate of an increase RA, or levelling off.
+// A plane already climbing or descending faster in the sense of the RA keeps its rate.
+func raManeuver(plane *Plane, start time.Time, ra string) VerticalManeuver {
+	flight := plane.FlightLog[len(plane.FlightLog)-1]
+	altitude, rate := flight.verticalStateAt(start)
+	maneuver := VerticalManeuver{Time: start, Altitude: altitude, InitialRate: rate, Acceleration: plane.Pilot.acceleration(),
+		TargetAltitude: altitude}
+	switch ra {
+	case RAClimb:
+		maneuver.VerticalRate = math.Max(rate, plane.Pilot.verticalRate())
+		maneuver.TargetAltitude = altitude + RAAltitudeDeviation
+		maneuver.Reason = ManeuverClimbRA
+	case RAIncreaseClimb:
+		maneuver.VerticalRate = math.Max(rate, IncreaseRAVerticalRate)
+		maneuver.TargetAltitude = altitude + RAAltitudeDeviation
+		maneuver.Reason = ManeuverIncreaseClimbRA
+	case RADescend:
+		maneuver.VerticalRate = math.Min(rate, -plane.Pilot.verticalRate())
+		maneuver.TargetAltitude = altitude - RAAltitudeDeviation
+		maneuver.Reason = ManeuverDescendRA
+	case RAIncreaseDescent:
+		maneuver.VerticalRate = math.Min(rate, -IncreaseRAVerticalRate)
+		maneuver.TargetAltitude = altitude - RAAltitudeDeviation
+		maneuver.Reason = ManeuverIncreaseDescentRA
+	default:
+		maneuver.Reason = ManeuverLevelOffRA
+	}
+	return maneuver
+}
+
+// responsePairing names the pair of crew responses of an engagement, in the same order whichever plane comes first.
+func responsePairing(engagement TCASEngagement) string {
+	responses := []string{engagement.PlaneResponse, engagement.OtherPlaneResponse}
+	for i, response := range responses {
+		if response == "" {
+			responses[i] = ResponseUnknown
+		}
+	}
+	sort.Strings(responses)
+	return responses[0] + " / " + responses[1]
+}
+
+// responseOutcomes counts the RAs of the simulation and the crash that ended it, if any, by pair of crew responses.
+// The crash is put down to the last RA between the two planes that collided.
+// It must be called with simState.Mu held, or once the simulation has stopped.
+func responseOutcomes(simState *SimulationState, planes map[string]*Plane) (engagements, crashes map[string]int) {
+	engagements = map[string]int{}
+	crashes = map[string]int{}
+
+	for _, engagement := range simState.Engagements {
+		if engagement.Engaged {
+			engagements[responsePairing(*engagement)]++
+		}
+	}
+
+	crashedPlanes := simState.CrashedPlanes
+	if len(crashedPlanes) == 2 && planes[crashedPlanes[0]] != nil {
+		var last *TCASEngagement
+		for _, engagement := range simState.engagementsOf(planes[crashedPlanes[0]]) {
+			if engagement.Engaged && (engagement.PlaneSerial == crashedPlanes[1] || engagement.OtherPlaneSerial == crashedPlanes[1]) {
+				last = engagement
+			}
+		}
+		if last != nil {
+			crashes[responsePairing(*last)]++
+		}
+	}
+	return engagements, crashes
+}
+
+// logTCASOutcomes writes to the TCAS log the table of the RAs of the simulation and the crash, if any,
+// by pair of crew responses, which shows how the behaviour of the crews drove the outcome of each encounter.
+// It must be called once the event loop has stopped.
+func logTCASOutcomes(simState *SimulationState) {
+	simState.Mu.Lock()
+	engagements, crashes := responseOutcomes(simState, planesBySerial(simState))
+	simState.Mu.Unlock()
+
+	pairings := make([]string, 0, len(engagements))
+	for pairing := range engagements {
+		pairings = append(pairings, pairing)
+	}
+	sort.Strings(pairings)
+
+	timestamp := simState.CurrentSimTime.Format("2006-01-02 15:04:05")
+	fmt.Fprintf(simState.TCASLog, "%s --- TCAS outcomes by crew response ---\n", timestamp)
+	fmt.Fprintf(simState.TCASLog, "%s   %-28s %6s %8s\n", timestamp, "Crew responses", "RAs", "Crashes")
+	for _, pairing := range pairings {
+		fmt.Fprintf(simState.TCASLog, "%s   %-28s %6d %8d\n", timestamp, pairing, engagements[pairing], crashes[pairing])
+	}
+	if len(pairings) == 0 {
+		fmt.Fprintf(simState.TCASLog, "%s   No RA was issued.\n", timestamp)
+	}
+	fmt.Fprintln(simState.TCASLog)
+}
@@ -0,0 +1,488 @@
+package aviation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// ReplayVersion is the version of the replay file format, it is increased whenever the format changes.
+// A replay of an older version from minReplayVersion is upgraded when it is loaded, see upgradeReplayHeader, and plays
+// back as it was recorded: what its version did not record simply never happens in it.
+//
+//	1: the first format; the manoeuvres, the RA revisions, the threats, the encounters and the STCA alerts were added
+//	   to it without a change of version, so any of them may be missing
+//	2: every state change of the TCAS and STCA models is recorded
+//	3: the header holds the record of each RA already issued once, in engagements, and planes refer to theirs by ID
+const ReplayVersion = 3
+
+// minReplayVersion is the oldest version of the replay file format that can still be played back.
+const minReplayVersion = 1
+
+// ReplayFilePath is where every simulation records its replay, it is overwritten by the next simulation.
+const ReplayFilePath = "logs/replay.jsonl"
+
+// Kinds of the entries of a replay file, one for each state change of a simulation.
+const (
+	ReplayTakeoff           = "takeoff"            // a plane starts its takeoff roll, with the flight planned for it
+	ReplayFlight            = "flight"             // the takeoff roll is over and the flight begins
+	ReplayLanding           = "landing"            // a plane starts landing
+	ReplayLanded            = "landed"             // a plane has landed and is parked
+	ReplayTCAS              = "tcas"               // the TCAS state of a plane changed (warning, engagement or clear)
+	ReplayEngagement        = "engagement"         // a new TCAS engagement was recorded between two planes
+	ReplayManeuver          = "maneuver"           // a vertical manoeuvre was added to the profile of a plane, because of an RA or to return to its cleared altitude
+	ReplayManeuverCancelled = "maneuver cancelled" // a manoeuvre that had not started yet was dropped from the profile of a plane
+	ReplayRARevised         = "ra revised"         // the RA of an engagement was strengthened, weakened or reversed
+	ReplayEncounter         = "encounter"          // an encounter between two planes started, got an RA, changed it or ended
+	ReplaySTCA              = "stca"               // an STCA alert of the ground system was raised, got an RA or was cleared
+	ReplayCrash             = "crash"              // two planes collided
+	ReplayEnd               = "end"                // the simulation stopped
+)
+
+// ReplayHeader is the first line of a replay file, it holds the state of the simulation when the recording started.
+type ReplayHeader struct {
+	Version            int               `json:"version"`
+	StartTime          time.Time         `json:"start_time"`
+	Seed               int64             `json:"seed"`
+	DurationMinutes    int64             `json:"duration_minutes"`
+	DifferentAltitudes bool              `json:"different_altitudes"`
+	Airports           []ReplayAirport   `json:"airports"`
+	Engagements        []*TCASEngagement `json:"engagements,omitempty"` // the RAs issued before the recording started
+}
+
+// ReplayAirport is an airport of a replay with the planes parked at it when the recording started.
+type ReplayAirport struct {
+	Serial   string     `json:"serial"`
+	Location Coordinate `json:"location"`
+	Planes   []*Plane   `json:"planes"`
+}
+
+// ReplayEntry is a single state change of a recorded simulation.
+// Only the fields that concern its kind are set.
+type ReplayEntry struct {
+	Time       time.Time         `json:"time"`
+	Kind       string            `json:"kind"`
+	Airport    string            `json:"airport,omitempty"`
+	Plane      string            `json:"plane,omitempty"`
+	OtherPlane string            `json:"other_plane,omitempty"`
+	Flight     *Flight           `json:"flight,omitempty"`
+	Engagement *TCASEngagement   `json:"engagement,omitempty"`
+	Threats    []TCASEngagement  `json:"threats,omitempty"` // every advisory of the plane, with ReplayTCAS
+	Encounter  *Encounter        `json:"encounter,omitempty"`
+	Maneuver   *VerticalManeuver `json:"maneuver,omitempty"`
+	STCA       *STCAAlert        `json:"stca,omitempty"`
+}
+
+// replayRecorder writes the replay of a simulation, one JSON document per line.
+type replayRecorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// newReplayRecorder creates the replay file at path, replacing the replay of the previous simulation.
+func newReplayRecorder(path string) (*replayRecorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &replayRecorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// write appends a line to the replay file.
+func (r *replayRecorder) write(v any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(v); err != nil {
+		log.Printf("failed to record replay: %v", err)
+	}
+}
+
+// close closes the replay file.
+func (r *replayRecorder) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.f.Close()
+}
+
+// recordReplayHeader records the current state of the simulation as the start of its replay.
+// Planes in flight are recorded with the airport they took off from, their flight log tells where they are.
+func (simState *SimulationState) recordReplayHeader() {
+	if simState.recorder == nil {
+		return
+	}
+
+	simState.Mu.Lock()
+	header := ReplayHeader{
+		Version:            ReplayVersion,
+		StartTime:          simState.CurrentSimTime,
+		Seed:               simState.Seed,
+		DurationMinutes:    int64(simState.durationMinutes),
+		DifferentAltitudes: simState.DifferentAltitudes,
+		Engagements:        simState.sortedEngagements(),
+	}
+	inFlightFrom := map[string][]*Plane{}
+	for _, p := range simState.PlanesInFlight {
+		from := p.FlightLog[len(p.FlightLog)-1].DepatureAirPort
+		inFlightFrom[from] = append(inFlightFrom[from], p)
+	}
+	for _, ap := range simState.Airports {
+		ap.Mu.Lock()
+		planes := append(append([]*Plane{}, ap.Planes...), inFlightFrom[ap.Serial]...)
+		header.Airports = append(header.Airports, ReplayAirport{Serial: ap.Serial, Location: ap.Location, Planes: planes})
+		ap.Mu.Unlock()
+	}
+
+	// The planes are encoded while simState.Mu is held, since the event loop changes them
+	simState.recorder.write(header)
+	simState.timeline = newTimeline(header.StartTime, simState.PlanesInFlight)
+	simState.Mu.Unlock()
+}
+
+// record adds a state change to the replay of the simulation, at the current simulation time.
+func (simState *SimulationState) record(entry ReplayEntry) {
+	if simState.recorder == nil {
+		return
+	}
+	entry.Time = simState.CurrentSimTime
+	simState.recorder.write(entry)
+	simState.timeline.add(entry)
+}
+
+// Replay is a recorded simulation ready to be played back.
+type Replay struct {
+	Header  ReplayHeader
+	Entries []ReplayEntry
+}
+
+// LoadReplay reads a replay file and resets the simulation state to the state its recording started from.
+// Call RunReplay afterwards to play it back.
+func (simState *SimulationState) LoadReplay(path string) (*Replay, error) {
+	if simState.SimIsRunning {
+		return nil, fmt.Errorf("a simulation is running, stop it before starting a replay")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024) // The header holds every plane
+	replay := &Replay{}
+	for line := 0; scanner.Scan(); line++ {
+		if line == 0 {
+			if err := json.Unmarshal(scanner.Bytes(), &replay.Header); err != nil {
+				return nil, fmt.Errorf("failed to decode replay header: %w", err)
+			}
+			if replay.Header.Version < minReplayVersion || replay.Header.Version > ReplayVersion {
+				return nil, fmt.Errorf("replay version %d is not supported, expected version %d to %d",
+					replay.Header.Version, minReplayVersion, ReplayVersion)
+			}
+			if err := upgradeReplayHeader(&replay.Header, scanner.Bytes()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var entry ReplayEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode replay line %d: %w", line+1, err)
+		}
+		replay.Entries = append(replay.Entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read replay: %w", err)
+	}
+	if replay.Header.Version == 0 {
+		return nil, fmt.Errorf("replay file %s is empty", path)
+	}
+
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+	simState.Airports = []*Airport{}
+	simState.PlanesInFlight = []*Plane{}
+	for _, apReplay := range replay.Header.Airports {
+		ap := &Airport{Serial: apReplay.Serial, Location: apReplay.Location, Planes: []*Plane{}}
+		for _, p := range apReplay.Planes {
+			if p.PlaneInFlight {
+				simState.PlanesInFlight = append(simState.PlanesInFlight, p)
+			} else {
+				ap.Planes = append(ap.Planes, p)
+			}
+		}
+		simState.Airports = append(simState.Airports, ap)
+	}
+	simState.timeline = newTimeline(replay.Header.StartTime, simState.PlanesInFlight)
+	for _, entry := range replay.Entries {
+		simState.timeline.add(entry)
+	}
+	simState.CurrentSimTime = replay.Header.StartTime
+	simState.Seed = replay.Header.Seed
+	simState.durationMinutes = time.Duration(replay.Header.DurationMinutes)
+	simState.DifferentAltitudes = replay.Header.DifferentAltitudes
+	simState.CrashedPlanes = []string{}
+	simState.FlightCount = 0
+	simState.Engagements = map[string]*TCASEngagement{}
+	for _, engagement := range replay.Header.Engagements {
+		simState.Engagements[engagement.EngagementID] = engagement
+	}
+	simState.Encounters = map[string][]*Encounter{}
+	simState.STCAAlerts = map[string][]*STCAAlert{}
+	simState.scheduler = nil
+	return replay, nil
+}
+
+// upgradeReplayHeader brings the header of a replay of an older version up to ReplayVersion. line is the header as
+// recorded, for what the current format no longer reads.
+func upgradeReplayHeader(header *ReplayHeader, line []byte) error {
+	if header.Version < 3 {
+		var legacy struct {
+			Airports []struct {
+				Planes []legacyPlane `json:"planes"`
+			} `json:"airports"`
+		}
+		if err := json.Unmarshal(line, &legacy); err != nil {
+			return fmt.Errorf("failed to decode the engagements of replay version %d: %w", header.Version, err)
+		}
+		planes := map[string]*Plane{}
+		legacyPlanes := []legacyPlane{}
+		for i, ap := range header.Airports {
+			for _, p := range ap.Planes {
+				planes[p.Serial] = p
+			}
+			legacyPlanes = append(legacyPlanes, legacy.Airports[i].Planes...)
+		}
+		header.Engagements = upgradeEngagements(legacyPlanes, planes)
+		header.Version = 3
+	}
+	return nil
+}
+
+// RunReplay plays a replay back on the simulation clock, so it can be paused, stepped and sped up like a simulation.
+// None of the aviation logic runs: the recorded state changes are applied as they happen, in steps of TCASCheckInterval.
+func RunReplay(simState *SimulationState, replay *Replay) {
+	simState.SimIsRunning = true
+	simState.Clock = NewSimClock(simState.CurrentSimTime, simState.SimSpeed)
+	defer func() { simState.SimIsRunning = false }()
+	defer func() { simState.SimEndedTime = simState.Clock.Now() }()
+	defer func() {
+		if !simState.Quiet {
+			fmt.Print("\nTCAS-simulator > ")
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	simState.Mu.Lock()
+	simState.cancel = cancel
+	simState.Mu.Unlock()
+	defer func() {
+		simState.Mu.Lock()
+		simState.cancel = nil
+		simState.Mu.Unlock()
+	}()
+
+	log.Printf("\n--- Replay Started (seed %d) at %s speed ---", replay.Header.Seed, SimSpeedString(simState.Clock.Speed()))
+
+	planes := map[string]*Plane{}
+	airports := map[string]*Airport{}
+	for _, ap := range simState.Airports {
+		airports[ap.Serial] = ap
+		for _, p := range ap.Planes {
+			planes[p.Serial] = p
+		}
+	}
+	for _, p := range simState.PlanesInFlight {
+		planes[p.Serial] = p
+	}
+
+	simTime := simState.CurrentSimTime
+	next := 0
+	for next < len(replay.Entries) {
+		simTime = simTime.Add(TCASCheckInterval)
+		if !simState.Clock.WaitUntil(ctx, simTime) {
+			return
+		}
+
+		simState.engineMu.Lock()
+		simState.Mu.Lock()
+		simState.CurrentSimTime = simTime
+		simState.Mu.Unlock()
+		for next < len(replay.Entries) && !replay.Entries[next].Time.After(simTime) {
+			applyReplayEntry(simState, replay.Entries[next], planes, airports)
+			next++
+		}
+		simState.engineMu.Unlock()
+	}
+
+	log.Printf("\n--- Replay Ended ---")
+}
+
+// applyReplayEntry applies a recorded state change to the simulation state and notifies the UI.
+func applyReplayEntry(simState *SimulationState, entry ReplayEntry, planes map[string]*Plane, airports map[string]*Airport) {
+	plane := planes[entry.Plane]
+	airport := airports[entry.Airport]
+	if entry.Plane != "" && plane == nil {
+		log.Printf("Replay: unknown plane %s, skipping %s", entry.Plane, entry.Kind)
+		return
+	}
+	if entry.Airport != "" && airport == nil {
+		log.Printf("Replay: unknown airport %s, skipping %s", entry.Airport, entry.Kind)
+		return
+	}
+
+	switch entry.Kind {
+	case ReplayTakeoff:
+		// Recordings before flights were planned on the runway have no plan on the takeoff
+		simState.Mu.Lock()
+		plane.PlannedFlight = entry.Flight
+		simState.Mu.Unlock()
+		log.Printf("Replay: Plane %s is taking off from Airport %s\n\n", plane.Serial, airport.Serial)
+
+	case ReplayFlight:
+		airport.Mu.Lock()
+		for i, p := range airport.Planes {
+			if p == plane {
+				airport.Planes = append(airport.Planes[:i], airport.Planes[i+1:]...)
+				break
+			}
+		}
+		airport.Mu.Unlock()
+
+		simState.Mu.Lock()
+		plane.PlaneInFlight = true
+		plane.PlannedFlight = nil
+		plane.FlightLog = append(plane.FlightLog, *entry.Flight)
+		simState.PlanesInFlight = append(simState.PlanesInFlight, plane)
+		simState.FlightCount++
+		simState.Mu.Unlock()
+
+		log.Printf("Replay: Plane %s took off from Airport %s, heading to Airport %s\n\n",
+			plane.Serial, entry.Flight.DepatureAirPort, entry.Flight.ArrivalAirPort)
+		if simState.OnPlaneTakeOffCallback != nil {
+			fyne.Do(func() {
+				simState.OnPlaneTakeOffCallback(plane)
+			})
+		}
+
+	case ReplayLanding:
+		simState.Mu.Lock()
+		plane.FlightLog[len(plane.FlightLog)-1].FlightStatus = "about to land"
+		simState.Mu.Unlock()
+		log.Printf("Replay: Plane %s is now landing at Airport %s\n\n", plane.Serial, airport.Serial)
+
+	case ReplayLanded:
+		simState.Mu.Lock()
+		for i, p := range simState.PlanesInFlight {
+			if p == plane {
+				simState.PlanesInFlight = append(simState.PlanesInFlight[:i], simState.PlanesInFlight[i+1:]...)
+				break
+			}
+		}
+		plane.PlaneInFlight = false
+		plane.CurrentTCASEngagement = nil
+		plane.TCASThreats = nil
+		plane.FlightLog[len(plane.FlightLog)-1].FlightStatus = "landed"
+		plane.FlightLog[len(plane.FlightLog)-1].ActualLandingTime = entry.Time
+		simState.Mu.Unlock()
+
+		airport.Mu.Lock()
+		airport.Planes = append(airport.Planes, plane)
+		airport.Mu.Unlock()
+
+		log.Printf("Replay: Plane %s landed at Airport %s\n\n", plane.Serial, airport.Serial)
+		if simState.OnPlaneLandCallback != nil {
+			fyne.Do(func() {
+				simState.OnPlaneLandCallback(plane.Serial)
+			})
+		}
+
+	case ReplayTCAS:
+		simState.Mu.Lock()
+		// An RA refers to its single record, so a later revision of it shows on the plane; a TA has no record
+		plane.CurrentTCASEngagement = entry.Engagement
+		if entry.Engagement != nil {
+			if record, ok := simState.Engagements[entry.Engagement.EngagementID]; ok {
+				plane.CurrentTCASEngagement = record
+			}
+		}
+		plane.TCASThreats = entry.Threats
+		// Replays recorded before every threat was tracked only have the most critical one
+		if entry.Threats == nil && entry.Engagement != nil {
+			plane.TCASThreats = []TCASEngagement{*entry.Engagement}
+		}
+		simState.Mu.Unlock()
+
+	case ReplayEngagement:
+		simState.Mu.Lock()
+		simState.addEngagement(*entry.Engagement, plane, planes[entry.OtherPlane])
+		simState.Mu.Unlock()
+		log.Printf("Replay: TCAS engagement between Plane %s and Plane %s (will crash: %v)\n\n",
+			entry.Plane, entry.OtherPlane, entry.Engagement.WillCrash)
+
+	case ReplayRARevised:
+		simState.Mu.Lock()
+		if record, ok := simState.Engagements[entry.Engagement.EngagementID]; ok {
+			*record = *entry.Engagement
+		}
+		// The threats of the planes are copies taken at their last TCAS change
+		for _, p := range []*Plane{plane, planes[entry.OtherPlane]} {
+			if p == nil {
+				continue
+			}
+			for i := range p.TCASThreats {
+				if p.TCASThreats[i].EngagementID == entry.Engagement.EngagementID {
+					p.TCASThreats[i] = *entry.Engagement
+				}
+			}
+		}
+		simState.Mu.Unlock()
+		log.Printf("Replay: the RA between Plane %s and Plane %s was revised\n\n", entry.Plane, entry.OtherPlane)
+
+	case ReplayEncounter:
+		simState.Mu.Lock()
+		simState.applyEncounter(*entry.Encounter)
+		if entry.Encounter.Outcome != EncounterOngoing {
+			storeMissDistance(simState, *entry.Encounter)
+		}
+		simState.Mu.Unlock()
+
+	case ReplaySTCA:
+		simState.Mu.Lock()
+		simState.applySTCAAlert(*entry.STCA)
+		updateSTCAConflicts(planes, *entry.STCA)
+		simState.Mu.Unlock()
+
+	case ReplayManeuver:
+		simState.Mu.Lock()
+		flight := &plane.FlightLog[len(plane.FlightLog)-1]
+		flight.insertManeuver(*entry.Maneuver)
+		simState.Mu.Unlock()
+		log.Printf("Replay: Plane %s flies a %s manoeuvre from %s at %.0f m\n\n", plane.Serial, entry.Maneuver.Reason,
+			entry.Maneuver.Time.Format("15:04:05.0"), entry.Maneuver.Altitude)
+
+	case ReplayManeuverCancelled:
+		simState.Mu.Lock()
+		flight := &plane.FlightLog[len(plane.FlightLog)-1]
+		flight.removeManeuver(*entry.Maneuver)
+		simState.Mu.Unlock()
+		log.Printf("Replay: Plane %s no longer flies its %s manoeuvre\n\n", plane.Serial, entry.Maneuver.Reason)
+
+	case ReplayCrash:
+		simState.Mu.Lock()
+		simState.CrashedPlanes = []string{entry.Plane, entry.OtherPlane}
+		simState.Mu.Unlock()
+		log.Printf("Replay: DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n", entry.Plane, entry.OtherPlane)
+
+	case ReplayEnd:
+		log.Printf("Replay: the recorded simulation stopped here\n\n")
+	}
+}
@@ -0,0 +1,207 @@
+package aviation
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Simulation speeds, expressed as how many simulated seconds pass for every second of wall clock time.
+const (
+	// SimSpeedRealTime runs the simulation at the speed of the wall clock.
+	SimSpeedRealTime = 1.0
+
+	// MaxPacedSpeed is the highest speed at which the clock still follows the wall clock, faster speeds are capped to it.
+	MaxPacedSpeed = 1000.0
+)
+
+// SimSpeedMax runs the simulation as fast as possible: the clock is unpaced, it no longer follows the wall clock and
+// jumps straight from one event to the next. It is apart from every paced speed, so no number typed by the user
+// makes a run unpaced.
+var SimSpeedMax = math.Inf(1)
+
+// clockPollInterval caps how long a waiter sleeps before checking the clock again,
+// so a change of speed is picked up by a wait already in progress.
+const clockPollInterval = 50 * time.Millisecond
+
+// SimClock is the virtual clock the simulation reads its time from.
+// The event loop waits on the SimClock before processing each event instead of waiting on the wall clock,
+// so the same scenario can be run at 1x, 10x, 100x or as fast as possible.
+type SimClock struct {
+	mu         sync.Mutex
+	simAnchor  time.Time // simulation time at the last change of speed
+	realAnchor time.Time // wall clock time at the last change of speed
+	speed      float64
+	paused     bool // a paused clock holds the simulation time still until it is resumed or stepped
+}
+
+// NewSimClock creates a SimClock starting at the given simulation time and running at the given speed.
+// A speed that is not positive falls back to real time, see clampSpeed.
+func NewSimClock(start time.Time, speed float64) *SimClock {
+	return &SimClock{
+		simAnchor:  start,
+		realAnchor: time.Now(),
+		speed:      clampSpeed(speed),
+	}
+}
+
+// clampSpeed returns the speed the clock runs at for a requested speed: real time for a speed that is not positive,
+// and at most MaxPacedSpeed unless it is SimSpeedMax.
+func clampSpeed(speed float64) float64 {
+	switch {
+	case speed <= 0 || math.IsNaN(speed):
+		return SimSpeedRealTime
+	case speed == SimSpeedMax:
+		return speed
+	default:
+		return math.Min(speed, MaxPacedSpeed)
+	}
+}
+
+// unpaced reports whether the clock runs as fast as possible, it must be called with c.mu held.
+func (c *SimClock) unpaced() bool {
+	return c.speed == SimSpeedMax
+}
+
+// Now returns the current simulation time.
+func (c *SimClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now()
+}
+
+// now returns the current simulation time, it must be called with c.mu held.
+func (c *SimClock) now() time.Time {
+	if c.paused || c.unpaced() {
+		return c.simAnchor
+	}
+	elapsed := float64(time.Since(c.realAnchor)) * c.speed
+	return c.simAnchor.Add(time.Duration(elapsed))
+}
+
+// Speed returns the current speed of the clock.
+func (c *SimClock) Speed() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.speed
+}
+
+// SetSpeed changes the speed of the clock without making the simulation time jump, see clampSpeed.
+func (c *SimClock) SetSpeed(speed float64) {
+	speed = clampSpeed(speed)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.simAnchor = c.now()
+	c.realAnchor = time.Now()
+	c.speed = speed
+}
+
+// Pause freezes the simulation time, nothing scheduled after the current time happens until the clock is resumed or stepped.
+func (c *SimClock) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return
+	}
+	c.simAnchor = c.now()
+	c.paused = true
+}
+
+// Resume lets the simulation time run again from where it was paused.
+func (c *SimClock) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		return
+	}
+	c.realAnchor = time.Now()
+	c.paused = false
+}
+
+// Paused reports whether the clock is paused.
+func (c *SimClock) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Step moves the simulation time forward by d and leaves the clock paused, a running clock is paused first.
+// Every event due within the step is then processed in order, and the simulation holds still again at the new time.
+// It returns the simulation time reached by the step.
+func (c *SimClock) Step(d time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.paused {
+		c.simAnchor = c.now()
+		c.paused = true
+	}
+	c.simAnchor = c.simAnchor.Add(d)
+	return c.simAnchor
+}
+
+// WaitUntil blocks until the simulation time reaches t, it returns false if ctx is cancelled first.
+// When the clock is unpaced (SimSpeedMax) it does not wait at all, the simulation time jumps straight to t,
+// unless the clock is paused, in which case it waits like any other speed.
+func (c *SimClock) WaitUntil(ctx context.Context, t time.Time) bool {
+	for {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		c.mu.Lock()
+		if c.unpaced() && !c.paused {
+			if t.After(c.simAnchor) {
+				c.simAnchor = t
+			}
+			c.mu.Unlock()
+			return true
+		}
+		remaining := t.Sub(c.now())
+		speed := c.speed
+		paused := c.paused
+		c.mu.Unlock()
+
+		if remaining <= 0 {
+			return true
+		}
+		wait := clockPollInterval
+		if !paused {
+			wait = min(time.Duration(float64(remaining)/speed), clockPollInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(wait):
+		}
+	}
+}
+
+// ParseSimSpeed converts a speed typed by the user, such as "10", "100x" or "max", into a simulation speed.
+func ParseSimSpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return SimSpeedMax, nil
+	}
+	s = strings.TrimSuffix(s, "x")
+	speed, err := strconv.ParseFloat(s, 64)
+	if err != nil || speed <= 0 || math.IsInf(speed, 0) {
+		return 0, fmt.Errorf("invalid simulation speed %q, use a positive number such as 1, 10, 100 or 'max'", s)
+	}
+	if speed > MaxPacedSpeed {
+		return 0, fmt.Errorf("simulation speed %q is above the highest paced speed %s, use 'max' to run as fast as possible",
+			s, SimSpeedString(MaxPacedSpeed))
+	}
+	return speed, nil
+}
+
+// SimSpeedString returns a human readable form of a simulation speed.
+func SimSpeedString(speed float64) string {
+	if speed == SimSpeedMax {
+		return "max"
+	}
+	return strconv.FormatFloat(speed, 'f', -1, 64) + "x"
+}
@@ -0,0 +1,49 @@
+package aviation
+
+import "math/rand"
+
+// countingSource is a random source that counts how many values it has produced,
+// so its exact state can be saved in a snapshot as a seed and a number of draws.
+type countingSource struct {
+	src   rand.Source64
+	seed  int64
+	draws uint64
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.seed = seed
+	s.draws = 0
+}
+
+// simRand is the random generator used by a running simulation, its state can be saved and restored.
+type simRand struct {
+	*rand.Rand
+	src *countingSource
+}
+
+// newSimRand creates a simRand seeded with the given seed.
+func newSimRand(seed int64) *simRand {
+	src := &countingSource{src: rand.NewSource(seed).(rand.Source64), seed: seed}
+	return &simRand{Rand: rand.New(src), src: src}
+}
+
+// restoreSimRand recreates a simRand in the state it was in after the given number of draws from the given seed.
+func restoreSimRand(seed int64, draws uint64) *simRand {
+	r := newSimRand(seed)
+	for range draws {
+		r.src.src.Uint64()
+	}
+	r.src.draws = draws
+	return r
+}
@@ -2,6 +2,7 @@ package aviation
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"log"
 	"math"
@@ -15,20 +16,56 @@ import (
 	"github.com/josephus-git/TCAS-simulation-Fyne/internal/util"
 )
 
-// SimulationState holds the collection of live domain objects and their current state
+// SimulationState holds the collection of live domain objects and their current state.
+// It also owns everything a running simulation needs (context, clock, event scheduler, counters and log files),
+// so several independent simulations can run in the same process, each with its own SimulationState.
 type SimulationState struct {
 	Airports           []*Airport
 	PlanesInFlight     []*Plane
 	Mu                 sync.Mutex
 	DifferentAltitudes bool
+	SimSpeed           float64
+	Seed               int64     // seed of the current simulation, the same seed and configuration reproduce the same run
+	Clock              *SimClock // virtual clock of the running simulation, the event loop waits on it before each event
 	SimIsRunning       bool
 	SimEndedTime       time.Time
 	SimWindowOpened    bool
 	CurrentSimTime     time.Time
+	CrashedPlanes      []string                   // Serials of the first pair of planes that collided, empty if no crash occurred
+	FlightCount        int                        // number of flights that took off during the current simulation
+	Collision          CollisionVolume            // two planes whose closest approach falls inside it collide
+	VerticalThresholds VerticalThresholds         // altitude thresholds of the TCAS vertical test
+	Engagements        map[string]*TCASEngagement // every RA of the simulation, keyed by EngagementID, see Plane.TCASEngagementIDs
+	Encounters         map[string][]*Encounter    // lifecycle of the encounters of each pair of planes, keyed by pairKey, oldest first
+	STCA               STCAConfig                 // Short-Term Conflict Alert of the ground system
+	Surveillance       *Surveillance              // picture of the traffic the ground radar built on its last sweep
+	STCAAlerts         map[string][]*STCAAlert    // STCA alerts of each pair of planes, keyed by pairKey, oldest first
+	CostTable          *CostTable                 // cost table of the acasx logic, nil when no plane flies with it or it could not be read
+	Quiet              bool                       // suppresses the messages printed to the terminal, used by batch runs
+
+	// rng drives the random choices of the simulation that do not belong to a single airport
+	rng *simRand
+
+	// scheduler holds the pending events of the running simulation
+	scheduler *EventScheduler
+
+	// cancel stops the running simulation, it is nil when no simulation is running
+	cancel context.CancelFunc
+
+	// engineMu is held by the event loop while it processes an event, so a snapshot always sees a consistent state
+	engineMu sync.Mutex
+
+	// durationMinutes is the configured duration of the current simulation
+	durationMinutes time.Duration
 
 	// Log files to be closed at end of each simulation
 	ConsoleLog *os.File
 	TCASLog    *os.File
+	STCALog    *os.File        // alerts of the ground system, kept apart from the airborne ones of the TCAS log
+	recorder   *replayRecorder // records the replay of the simulation, nil when no replay is recorded
+
+	// timeline keeps the recorded history of the simulation or replay in memory for the timeline scrubber
+	timeline *Timeline
 
 	// Callbacks for UI updates
 	OnPlaneTakeOffCallback func(*Plane)
@@ -99,18 +136,68 @@ func GetNumberOfPlanes(conf *config.Config) {
 
 }
 
+// GetSimulationDuration prompts the user to input the duration of the simulation in minutes.
+// It validates the input to ensure it's a positive integer, this is used when no setup window is available.
+func GetSimulationDuration() int {
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for {
+		fmt.Print("Input the duration of the simulation in minutes > ")
+		scanner.Scan()
+		input := util.CleanInput(scanner.Text())
+		if len(input) == 0 {
+			fmt.Println("")
+			continue
+		}
+		num, err := strconv.Atoi(input[0])
+		if err != nil {
+			fmt.Println("Please input a valid integer")
+			continue
+		}
+		if num < 1 {
+			fmt.Println("1 minute minimum")
+			continue
+		}
+		return num
+	}
+}
+
 // InitializeAirports creates appropriate amount of airports and airplanes
 func InitializeAirports(conf *config.Config, simState *SimulationState) {
 	simState.DifferentAltitudes = conf.DifferentAltitudes
+	simState.SimSpeed = conf.SimSpeed
+	simState.Collision = NewCollisionVolume(conf.CollisionRadius, conf.CollisionHeight)
+	simState.VerticalThresholds = NewVerticalThresholds(conf.TAZTHR, conf.RAZTHR)
+	simState.STCA = NewSTCAConfig(conf)
+
+	// The cost table is read once per simulation state, unless the caller already read it, as main and RunBatch do
+	if simState.CostTable == nil {
+		table, err := NewCostTable(conf)
+		if err != nil {
+			log.Printf("%v, the planes flying with %s use %s instead\n", err, ACASXa, DefaultCollisionAvoidance)
+		}
+		simState.CostTable = table
+	}
+
+	// A single seed feeds every random choice of the simulation, a seed of 0 picks a new one for this run
+	simState.Seed = conf.Seed
+	if simState.Seed == 0 {
+		simState.Seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(simState.Seed))
+	simState.rng = newSimRand(r.Int63())
 
 	planesCreated := 0
 	airportsCreated := 0
+	pilots := NewPilotPopulation(conf)
+	fleet := NewFleetMix(conf)
+	avoidance := AvoidanceMix(conf.AvoidanceMix)
 
 	for i := 0; planesCreated < conf.NoOfAirplanes; i++ {
-		newAirport := createAirport(airportsCreated, planesCreated, conf.NoOfAirplanes)
+		newAirport := createAirport(airportsCreated, planesCreated, conf.NoOfAirplanes, r)
 		planesGenerated := planesCreated
 		for range newAirport.InitialPlaneAmount {
-			newPlane := createPlane(planesGenerated)
+			newPlane := createPlane(planesGenerated, r, fleet, avoidance, pilots)
 			newAirport.Planes = append(newAirport.Planes, newPlane)
 			planesGenerated += 1
 		}
@@ -119,15 +206,17 @@ func InitializeAirports(conf *config.Config, simState *SimulationState) {
 		airportsCreated = i + 1
 	}
 
-	listOfAirportCoordinates := generateCoordinates(len(simState.Airports))
+	listOfAirportCoordinates := generateCoordinates(len(simState.Airports), r)
 
 	for i := range simState.Airports {
 		newLocation := Coordinate{listOfAirportCoordinates[i].X, listOfAirportCoordinates[i].Y, 0.0}
 		simState.Airports[i].Location = newLocation
 	}
 
-	fmt.Printf("\nInitialized: %d airports, %d planes distributed among airports.\n\n",
-		len(simState.Airports), conf.NoOfAirplanes)
+	if !simState.Quiet {
+		fmt.Printf("\nInitialized: %d airports, %d planes distributed among airports (seed %d).\n\n",
+			len(simState.Airports), conf.NoOfAirplanes, simState.Seed)
+	}
 }
 
 // Point represents a 2D coordinate with X and Y components.
@@ -143,16 +232,12 @@ type Point struct {
 // Parameters:
 //
 //	numCoordinates: The total number of coordinates to generate.
+//	r: The random source, seeded from the simulation seed so the layout can be reproduced.
 //
 // Returns:
 //
 //	A slice of Point structs containing the generated coordinates.
-func generateCoordinates(numCoordinates int) []Point {
-	// Initialize a new random number generator.
-	// Using time.Now().UnixNano() as the seed ensures that the generated
-	// coordinates will be different each time the function is called.
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-
+func generateCoordinates(numCoordinates int, r *rand.Rand) []Point {
 	// Initialize an empty slice to store the generated points.
 	points := []Point{}
 
@@ -228,6 +313,10 @@ func generateCoordinates(numCoordinates int) []Point {
 // startInit parses the duration string and initializes the simulation,
 // handles input validation, ensuring a positive integer for simulation duration.
 func OpenLogFiles(cfg *config.Config, simState *SimulationState) {
+	// Make sure the logs directory exists, headless runs may be started from a fresh checkout
+	if err := os.MkdirAll("logs", 0755); err != nil {
+		log.Fatalf("failed to create logs directory: %v", err)
+	}
 
 	logFilePath := "logs/console_log.txt"
 	// Open the file in append mode. Create it if it doesn't exist.
@@ -242,13 +331,31 @@ func OpenLogFiles(cfg *config.Config, simState *SimulationState) {
 		log.Fatalf("failed to open log file: %v", err)
 	}
 
+	logFilePath = "logs/stcaLog.txt"
+	stcaLog, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Fatalf("failed to open log file: %v", err)
+	}
+
+	recorder, err := newReplayRecorder(ReplayFilePath)
+	if err != nil {
+		log.Fatalf("failed to open replay file: %v", err)
+	}
+
 	simState.SimIsRunning = true
 	simState.SimEndedTime = time.Time{}
 	simState.ConsoleLog = f
 	simState.TCASLog = tcasLog
+	simState.STCALog = stcaLog
+	simState.recorder = recorder
 }
 
 func CloseLogFiles(simState *SimulationState) {
 	simState.ConsoleLog.Close()
 	simState.TCASLog.Close()
+	simState.STCALog.Close()
+	if simState.recorder != nil {
+		simState.recorder.close()
+		simState.recorder = nil
+	}
 }
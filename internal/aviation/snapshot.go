@@ -0,0 +1,339 @@
+package aviation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot file format, it is increased whenever the format changes.
+// A snapshot of an older version still supported is upgraded when it is loaded, see upgradeSnapshot, any other is
+// rejected instead of being restored wrongly.
+//
+//	1: the first format; the collision volume, the vertical thresholds, the encounters, STCA and its radar picture
+//	   were added to it without a change of version, so any of them may be missing
+//	2: every setting and the whole state of the TCAS and STCA models are saved
+//	3: the record of each RA is saved once, in engagements, and planes refer to theirs by ID
+const SnapshotVersion = 3
+
+// minSnapshotVersion is the oldest version of the snapshot file format that can still be loaded.
+const minSnapshotVersion = 1
+
+// snapshot is the content of a snapshot file: everything needed to carry on a simulation from the moment it was saved.
+// Planes and airports refer to each other by serial.
+type snapshot struct {
+	Version            int                `json:"version"`
+	SavedAt            time.Time          `json:"saved_at"`
+	SimTime            time.Time          `json:"sim_time"`
+	DurationMinutes    int64              `json:"duration_minutes"`
+	Seed               int64              `json:"seed"`
+	DifferentAltitudes bool               `json:"different_altitudes"`
+	CrashedPlanes      []string           `json:"crashed_planes"`
+	FlightCount        int                `json:"flight_count"`
+	Collision          CollisionVolume    `json:"collision_volume"`
+	VerticalThresholds VerticalThresholds `json:"vertical_thresholds"`
+	Engagements        []*TCASEngagement  `json:"engagements,omitempty"`
+	Encounters         []*Encounter       `json:"encounters,omitempty"`
+	STCA               STCAConfig         `json:"stca"`
+	Surveillance       *Surveillance      `json:"surveillance,omitempty"`
+	STCAAlerts         []*STCAAlert       `json:"stca_alerts,omitempty"`
+	RNG                rngSnapshot        `json:"rng"`
+	Airports           []airportSnapshot  `json:"airports"`
+	Planes             []*Plane           `json:"planes"`
+	PlanesInFlight     []string           `json:"planes_in_flight"`
+	Events             []eventSnapshot    `json:"events"`
+}
+
+// rngSnapshot is the state of a simRand.
+type rngSnapshot struct {
+	Seed  int64  `json:"seed"`
+	Draws uint64 `json:"draws"`
+}
+
+// airportSnapshot is the state of an airport, including its runway usage and parked planes.
+type airportSnapshot struct {
+	Serial             string      `json:"serial"`
+	Location           Coordinate  `json:"location"`
+	InitialPlaneAmount int         `json:"initial_plane_amount"`
+	NumberOfRunways    int         `json:"number_of_runways"`
+	RunwaysInUse       int         `json:"runways_in_use"`
+	ReceivingPlane     bool        `json:"receiving_plane"`
+	Planes             []string    `json:"planes"`
+	RNG                rngSnapshot `json:"rng"`
+}
+
+// eventSnapshot is a pending event of the scheduler.
+type eventSnapshot struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Airport string    `json:"airport,omitempty"`
+	Plane   string    `json:"plane,omitempty"`
+}
+
+// SaveSnapshot writes the whole state of the running simulation to a versioned JSON file.
+// The event loop is held while the state is copied, so the snapshot never contains half an event.
+func (simState *SimulationState) SaveSnapshot(path string) error {
+	if !simState.SimIsRunning || simState.scheduler == nil {
+		return errSimulationNotRunning
+	}
+
+	// The planes are encoded while the event loop is held, since it is the one changing them
+	simState.engineMu.Lock()
+	data, err := json.MarshalIndent(simState.takeSnapshot(), "", "  ")
+	simState.engineMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return nil
+}
+
+// takeSnapshot copies the state of the simulation, it must be called with simState.engineMu held.
+func (simState *SimulationState) takeSnapshot() snapshot {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+
+	snap := snapshot{
+		Version:            SnapshotVersion,
+		SavedAt:            time.Now(),
+		SimTime:            simState.CurrentSimTime,
+		DurationMinutes:    int64(simState.durationMinutes),
+		Seed:               simState.Seed,
+		DifferentAltitudes: simState.DifferentAltitudes,
+		CrashedPlanes:      simState.CrashedPlanes,
+		FlightCount:        simState.FlightCount,
+		Collision:          simState.Collision,
+		VerticalThresholds: simState.VerticalThresholds,
+		STCA:               simState.STCA,
+		Surveillance:       simState.Surveillance,
+		Engagements:        simState.sortedEngagements(),
+		RNG:                rngSnapshot{Seed: simState.rng.src.seed, Draws: simState.rng.src.draws},
+	}
+	keys := []string{}
+	for key := range simState.Encounters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		snap.Encounters = append(snap.Encounters, simState.Encounters[key]...)
+	}
+	for _, key := range sortedSTCAKeys(simState) {
+		snap.STCAAlerts = append(snap.STCAAlerts, simState.STCAAlerts[key]...)
+	}
+
+	for _, ap := range simState.Airports {
+		ap.Mu.Lock()
+		apSnap := airportSnapshot{
+			Serial:             ap.Serial,
+			Location:           ap.Location,
+			InitialPlaneAmount: ap.InitialPlaneAmount,
+			NumberOfRunways:    ap.Runway.numberOfRunway,
+			RunwaysInUse:       ap.Runway.noOfRunwayinUse,
+			ReceivingPlane:     ap.ReceivingPlane,
+			Planes:             []string{},
+			RNG:                rngSnapshot{Seed: ap.rng.src.seed, Draws: ap.rng.src.draws},
+		}
+		for _, p := range ap.Planes {
+			apSnap.Planes = append(apSnap.Planes, p.Serial)
+			snap.Planes = append(snap.Planes, p)
+		}
+		ap.Mu.Unlock()
+		snap.Airports = append(snap.Airports, apSnap)
+	}
+
+	// Every plane is either parked at an airport or in flight
+	saved := map[string]bool{}
+	for _, p := range snap.Planes {
+		saved[p.Serial] = true
+	}
+	for _, p := range simState.PlanesInFlight {
+		snap.PlanesInFlight = append(snap.PlanesInFlight, p.Serial)
+		if !saved[p.Serial] {
+			snap.Planes = append(snap.Planes, p)
+		}
+	}
+
+	for _, ev := range simState.scheduler.pending() {
+		evSnap := eventSnapshot{Time: ev.Time, Kind: ev.Kind.String()}
+		if ev.Airport != nil {
+			evSnap.Airport = ev.Airport.Serial
+		}
+		if ev.Plane != nil {
+			evSnap.Plane = ev.Plane.Serial
+		}
+		snap.Events = append(snap.Events, evSnap)
+	}
+
+	return snap
+}
+
+// LoadSnapshot replaces the state of a stopped simulation with the one saved in a snapshot file.
+// Call StartLoadedSimulation afterwards to carry on the simulation.
+func (simState *SimulationState) LoadSnapshot(path string) error {
+	if simState.SimIsRunning {
+		return fmt.Errorf("a simulation is running, stop it before loading a snapshot")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snap.Version < minSnapshotVersion || snap.Version > SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is not supported, expected version %d to %d", snap.Version,
+			minSnapshotVersion, SnapshotVersion)
+	}
+	if err := upgradeSnapshot(&snap, data); err != nil {
+		return err
+	}
+
+	planes := map[string]*Plane{}
+	for _, p := range snap.Planes {
+		if p.FlightLog == nil {
+			p.FlightLog = []Flight{}
+		}
+		planes[p.Serial] = p
+	}
+	findPlane := func(serial string) (*Plane, error) {
+		p, ok := planes[serial]
+		if !ok {
+			return nil, fmt.Errorf("snapshot refers to unknown plane %s", serial)
+		}
+		return p, nil
+	}
+
+	airports := map[string]*Airport{}
+	restoredAirports := []*Airport{}
+	for _, apSnap := range snap.Airports {
+		ap := &Airport{
+			Serial:             apSnap.Serial,
+			Location:           apSnap.Location,
+			InitialPlaneAmount: apSnap.InitialPlaneAmount,
+			Runway:             runway{numberOfRunway: apSnap.NumberOfRunways, noOfRunwayinUse: apSnap.RunwaysInUse},
+			Planes:             []*Plane{},
+			ReceivingPlane:     apSnap.ReceivingPlane,
+			rng:                restoreSimRand(apSnap.RNG.Seed, apSnap.RNG.Draws),
+		}
+		for _, serial := range apSnap.Planes {
+			p, err := findPlane(serial)
+			if err != nil {
+				return err
+			}
+			ap.Planes = append(ap.Planes, p)
+		}
+		airports[ap.Serial] = ap
+		restoredAirports = append(restoredAirports, ap)
+	}
+
+	planesInFlight := []*Plane{}
+	for _, serial := range snap.PlanesInFlight {
+		p, err := findPlane(serial)
+		if err != nil {
+			return err
+		}
+		planesInFlight = append(planesInFlight, p)
+	}
+
+	// Events are rescheduled in the order they were pending, which keeps ties between them in the same order
+	scheduler := newEventScheduler()
+	for _, evSnap := range snap.Events {
+		kind, ok := parseEventKind(evSnap.Kind)
+		if !ok {
+			return fmt.Errorf("snapshot contains unknown event %q", evSnap.Kind)
+		}
+		ev := &Event{Time: evSnap.Time, Kind: kind}
+		if evSnap.Airport != "" {
+			ap, ok := airports[evSnap.Airport]
+			if !ok {
+				return fmt.Errorf("snapshot refers to unknown airport %s", evSnap.Airport)
+			}
+			ev.Airport = ap
+		}
+		if evSnap.Plane != "" {
+			p, err := findPlane(evSnap.Plane)
+			if err != nil {
+				return err
+			}
+			ev.Plane = p
+		}
+		scheduler.Schedule(ev)
+	}
+
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+	simState.Airports = restoredAirports
+	simState.PlanesInFlight = planesInFlight
+	simState.CurrentSimTime = snap.SimTime
+	simState.durationMinutes = time.Duration(snap.DurationMinutes)
+	simState.Seed = snap.Seed
+	simState.DifferentAltitudes = snap.DifferentAltitudes
+	simState.CrashedPlanes = snap.CrashedPlanes
+	if simState.CrashedPlanes == nil {
+		simState.CrashedPlanes = []string{}
+	}
+	simState.FlightCount = snap.FlightCount
+	simState.Engagements = map[string]*TCASEngagement{}
+	for _, engagement := range snap.Engagements {
+		simState.Engagements[engagement.EngagementID] = engagement
+	}
+	simState.Encounters = map[string][]*Encounter{}
+	for _, encounter := range snap.Encounters {
+		simState.applyEncounter(*encounter)
+	}
+	simState.STCAAlerts = map[string][]*STCAAlert{}
+	for _, alert := range snap.STCAAlerts {
+		simState.applySTCAAlert(*alert)
+	}
+	simState.STCA = snap.STCA
+	simState.Surveillance = snap.Surveillance
+	simState.Collision = snap.Collision
+	simState.VerticalThresholds = snap.VerticalThresholds
+	simState.rng = restoreSimRand(snap.RNG.Seed, snap.RNG.Draws)
+	simState.scheduler = scheduler
+	return nil
+}
+
+// upgradeSnapshot brings a snapshot of an older version up to SnapshotVersion, each step filling in what the next
+// version added. data is the content of the snapshot file, for what the current format no longer reads.
+func upgradeSnapshot(snap *snapshot, data []byte) error {
+	if snap.Version < 2 {
+		// Whatever was not modelled yet when the snapshot was saved takes its default, the radar starts afresh
+		snap.Collision = NewCollisionVolume(snap.Collision.Radius, snap.Collision.Height/FeetToMeters)
+		snap.VerticalThresholds = NewVerticalThresholds(snap.VerticalThresholds.TAZTHR, snap.VerticalThresholds.RAZTHR)
+		snap.STCA = snap.STCA.withDefaults()
+		if snap.Surveillance == nil {
+			snap.Surveillance = newSurveillance()
+		}
+		sweeping := false
+		for _, evSnap := range snap.Events {
+			sweeping = sweeping || evSnap.Kind == EventRadarSweep.String()
+		}
+		if !sweeping {
+			snap.Events = append(snap.Events, eventSnapshot{Time: snap.SimTime.Add(snap.STCA.radarInterval()),
+				Kind: EventRadarSweep.String()})
+		}
+		snap.Version = 2
+	}
+	if snap.Version < 3 {
+		var legacy struct {
+			Planes []legacyPlane `json:"planes"`
+		}
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("failed to decode the engagements of snapshot version %d: %w", snap.Version, err)
+		}
+		planes := map[string]*Plane{}
+		for _, p := range snap.Planes {
+			planes[p.Serial] = p
+		}
+		snap.Engagements = upgradeEngagements(legacy.Planes, planes)
+		snap.Version = 3
+	}
+	return nil
+}
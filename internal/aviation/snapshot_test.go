@@ -0,0 +1,74 @@
+package aviation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// readSnapshot returns the content of a snapshot file without the time it was saved at, which is all that differs
+// between two saves of the same state.
+func readSnapshot(t *testing.T, path string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		t.Fatal(err)
+	}
+	snap.SavedAt = time.Time{}
+	data, err = json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	// A short run leaves planes in flight, on the runways and parked, with their events pending
+	conf := config.Config{NoOfAirplanes: 20, DifferentAltitudes: true, FaultyTCASRatio: 0.2, Seed: 7}
+	simState := &SimulationState{Quiet: true}
+	InitializeAirports(&conf, simState)
+	simState.SimSpeed = SimSpeedMax
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simState.ConsoleLog, simState.TCASLog, simState.STCALog = devNull, devNull, devNull
+	StartSimulation(simState, 2)
+	if len(simState.PlanesInFlight) == 0 {
+		t.Fatal("no plane is in flight at the end of the run")
+	}
+
+	dir := t.TempDir()
+	saved, resaved := filepath.Join(dir, "saved.json"), filepath.Join(dir, "resaved.json")
+	simState.SimIsRunning = true
+	if err := simState.SaveSnapshot(saved); err != nil {
+		t.Fatalf("SaveSnapshot() = %v", err)
+	}
+
+	loaded := &SimulationState{}
+	if err := loaded.LoadSnapshot(saved); err != nil {
+		t.Fatalf("LoadSnapshot() = %v", err)
+	}
+	loaded.SimIsRunning = true
+	if err := loaded.SaveSnapshot(resaved); err != nil {
+		t.Fatalf("SaveSnapshot() of the loaded state = %v", err)
+	}
+
+	if want, got := readSnapshot(t, saved), readSnapshot(t, resaved); !bytes.Equal(want, got) {
+		t.Errorf("the loaded state saves a different snapshot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -2,181 +2,133 @@ package aviation
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"sync"
 	"time"
 )
 
-// Simulation parameters
-// FlightMonitorInterval is how often the monitor checks planes for landing time
-const FlightMonitorInterval = 100 * time.Millisecond
+// StartSimulation initializes and starts the TCAS simulation, then processes its events until the end.
+// It sets up a context for graceful shutdown and returns once the simulation has stopped.
+func StartSimulation(simState *SimulationState, durationMinutes time.Duration) {
+	simState.Mu.Lock()
+	simState.CurrentSimTime = time.Now()
+	simState.CrashedPlanes = []string{}
+	simState.FlightCount = 0
+	simState.Engagements = map[string]*TCASEngagement{}
+	simState.Encounters = map[string][]*Encounter{}
+	simState.Surveillance = newSurveillance()
+	simState.STCAAlerts = map[string][]*STCAAlert{}
+	simState.STCA = simState.STCA.withDefaults()
+	simState.durationMinutes = durationMinutes
+	simState.Mu.Unlock()
 
-// FlightNumberCount is a global counter used to generate unique flight numbers.
-var FlightNumberCount int
+	// Every state change of the simulation is an event processed in order of simulation time.
+	simState.scheduler = newEventScheduler()
 
-// simulationCancelFunc is a global variable to hold the cancel function for the simulation context,
-// this allows EmergencyStop to trigger cancellation of the simulation from anywhere
-var simulationCancelFunc context.CancelFunc
+	runSimulation(simState, false, func() {
+		// The simulation stops once the specified duration has passed in simulation time,
+		// even if EmergencyStop is not called.
+		simulationDuration := time.Duration(durationMinutes) * time.Minute
+		simState.scheduler.Schedule(&Event{Time: simState.CurrentSimTime.Add(simulationDuration), Kind: EventSimulationEnd})
 
-// stopTrigger is a pointer to time.Timer, it is stopped during emergency stop
-var stopTrigger *time.Timer
+		scheduleAirportLaunches(simState)
 
-// startSimulationInit initializes and starts the TCAS simulation, managing goroutines for takeoffs and landings.
-// It sets up a context for graceful shutdown and waits for all simulation activities to complete.
-func StartSimulation(simState *SimulationState, durationMinutes time.Duration) {
+		// TCAS runs as a periodic event, so it works the same whether or not a window is rendering the simulation.
+		simState.scheduler.Schedule(&Event{Time: simState.CurrentSimTime.Add(TCASCheckInterval), Kind: EventTCASCheck})
+
+		// The ground radar sweeps the traffic at its own pace, slower than TCAS
+		simState.scheduler.Schedule(&Event{Time: simState.CurrentSimTime.Add(simState.STCA.radarInterval()), Kind: EventRadarSweep})
+	})
+}
+
+// StartLoadedSimulation runs a simulation restored by LoadSnapshot, from the simulation time it was saved at.
+// The simulation starts paused so it can be examined before it is resumed.
+func StartLoadedSimulation(simState *SimulationState) {
+	runSimulation(simState, true, nil)
+}
+
+// runSimulation runs the event loop of a simulation whose scheduler is ready, until the simulation stops.
+// scheduleStart schedules the first events of a new simulation, it is nil for a loaded one whose events are already scheduled.
+func runSimulation(simState *SimulationState, startPaused bool, scheduleStart func()) {
 	simState.SimIsRunning = true
 	f := simState.ConsoleLog
-	FlightNumberCount = 0
+	durationMinutes := simState.durationMinutes
+
+	// Every wait in the simulation goes through this clock, so the run can be faster than real time
+	simState.Clock = NewSimClock(simState.CurrentSimTime, simState.SimSpeed)
+	if startPaused {
+		simState.Clock.Pause()
+	}
 
 	defer func() { simState.SimIsRunning = false }()
-	defer func() { simState.SimEndedTime = time.Now() }()
-	defer func() { fmt.Print("\nTCAS-simulator > ") }()
+	defer func() { simState.SimEndedTime = simState.Clock.Now() }()
+	defer func() {
+		if !simState.Quiet {
+			fmt.Print("\nTCAS-simulator > ")
+		}
+	}()
 
 	defer func() { f.Close() }()
-	log.Printf("\n--- TCAS Simulation Started for %d minute(s) ---", durationMinutes)
-	fmt.Fprintf(f, "%s\n--- TCAS Simulation Started for %d minute(s) ---\n",
-		time.Now().Format("2006-01-02 15:04:05"), durationMinutes)
+	if scheduleStart != nil {
+		log.Printf("\n--- TCAS Simulation Started for %d minute(s) at %s speed ---", durationMinutes, SimSpeedString(simState.Clock.Speed()))
+		fmt.Fprintf(f, "%s\n--- TCAS Simulation Started for %d minute(s) at %s speed ---\n",
+			simState.Clock.Now().Format("2006-01-02 15:04:05"), durationMinutes, SimSpeedString(simState.Clock.Speed()))
+	} else {
+		log.Printf("\n--- TCAS Simulation Loaded from a Snapshot (%d minute(s) run) at %s speed ---", durationMinutes, SimSpeedString(simState.Clock.Speed()))
+		fmt.Fprintf(f, "%s\n--- TCAS Simulation Loaded from a Snapshot (%d minute(s) run) at %s speed ---\n",
+			simState.Clock.Now().Format("2006-01-02 15:04:05"), durationMinutes, SimSpeedString(simState.Clock.Speed()))
+	}
 
-	fmt.Printf("TCAS logs can be found in logs/tcasLogs.txt. \n\n")
+	log.Printf("--- Seed: %d ---", simState.Seed)
+	fmt.Fprintf(f, "%s--- Seed: %d ---\n",
+		simState.Clock.Now().Format("2006-01-02 15:04:05"), simState.Seed)
 
-	// WaitGroup to keep track of running goroutines
-	var wg sync.WaitGroup
+	if !simState.Quiet {
+		fmt.Printf("TCAS logs can be found in logs/tcasLogs.txt. \n\n")
+	}
 
 	// Create a cancellable context for the simulation.
-	// This context will be passed to all goroutines.
-	// The cancel function is stored globally and also called when the duration expires.
-	var ctx context.Context
-	simulationDuration := time.Duration(durationMinutes) * time.Minute
-	ctx, simulationCancelFunc = context.WithCancel(context.Background())
-
-	// Set a timer to automatically call cancel after the specified duration.
-	// This ensures the simulation stops even if EmergencyStop is not called.
-	stopTrigger = time.AfterFunc(simulationDuration, func() {
-		if simState.SimIsRunning {
-			log.Printf("\n--- Simulation Duration (%d minutes) Reached. Initiating shutdown... ---", durationMinutes)
-			fmt.Fprintf(f, "%s\n--- Simulation Duration (%d minutes) Reached. Initiating shutdown... ---\n",
-				time.Now().Format("2006-01-02 15:04:05"), durationMinutes)
-		}
-		if simulationCancelFunc != nil {
-			simulationCancelFunc() // Trigger cancellation
-		}
-	})
-
-	// Start the takeoff simulation (using your provided startSimulation function)
-	// Pass ctx and wg to startSimulation so airport goroutines can respect shutdown
-	startAirports(simState, ctx, &wg)
+	// The cancel function is stored on the simulation state so EmergencyStop can interrupt the event loop.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	simState.Mu.Lock()
+	simState.cancel = cancel
+	simState.Mu.Unlock()
+	defer func() {
+		simState.Mu.Lock()
+		simState.cancel = nil
+		simState.Mu.Unlock()
+	}()
+
+	simState.recordReplayHeader()
+	if scheduleStart != nil {
+		scheduleStart()
+	}
 
-	// --- Start Flight Monitoring Goroutine (for landings) ---
 	log.Printf("--- Starting Flight Landing and TCAS Monitor ---\n\n")
 	fmt.Fprintf(f, "%s--- Starting Flight Landing and TCAS Monitor ---, \n\n",
-		time.Now().Format("2006-01-02 15:04:05"))
+		simState.Clock.Now().Format("2006-01-02 15:04:05"))
 	log.Printf("--- Varying Altitudes: %v ---\n\n", simState.DifferentAltitudes)
 	fmt.Fprintf(f, "%s--- Varying Altitudes: %v ---, \n\n",
-		time.Now().Format("2006-01-02 15:04:05"), simState.DifferentAltitudes)
-	fmt.Println("Remember type 'q' and hit Enter to immediately stop the simulation if needed")
-
-	wg.Add(1) // Add for the monitor goroutine
-	go func(globalSimState *SimulationState, ctx context.Context) {
-		defer wg.Done()
-
-		for simState.SimIsRunning {
-			select {
-			case <-ctx.Done(): // Check if the main simulation context is done
-				log.Printf("Flight monitor stopping.")
-				fmt.Fprintf(f, "%sFlight monitor stopping .\n",
-					time.Now().Format("2006-01-02 15:04:05"))
-				return // Exit goroutine
-			default:
-				// Continue monitoring
-			}
-
-			select {
-			case <-time.After(FlightMonitorInterval):
-				// This case executes if the FlightMonitorInterval duration passes.
-			case <-ctx.Done():
-				// This case executes if the context (ctx) is cancelled.
-				log.Printf("Flight monitor stopping during sleep.")
-				fmt.Fprintf(f, "%sFlight monitor stopping during sleep.\n",
-					time.Now().Format("2006-01-02 15:04:05"))
-				return // Exits the goroutine immediately.
-			}
-
-			// We need to safely access and potentially modify globalSimState.PlanesInFlight.
-			// It's safer to copy the list of planes to be processed, then release the lock,
-			// and then process the copy. This prevents deadlocks if Land() tries to acquire
-			// other locks (like airport.Mu) while globalSimState.Mu is held.
-			globalSimState.Mu.Lock()
-			planesToLand := []*Plane{}
-
-			for _, p := range globalSimState.PlanesInFlight {
-				if len(p.FlightLog) > 0 {
-					currentFlight := p.FlightLog[len(p.FlightLog)-1]
-					// Check if current time is past or at the plane's scheduled landing time
-					if simState.CurrentSimTime.After(currentFlight.DestinationArrivalTime) || simState.CurrentSimTime.Equal(currentFlight.DestinationArrivalTime) {
-						planesToLand = append(planesToLand, p)
-					}
-				}
-			}
-			globalSimState.Mu.Unlock() // Release lock on global state after identifying planes
-
-			// Process the planes that are ready to land
-			for _, p := range planesToLand {
-				select {
-				case <-ctx.Done():
-					log.Printf("Flight monitor stopping while processing planes.")
-					fmt.Fprintf(f, "%sFlight monitor stopping while processing planes.\n",
-						time.Now().Format("2006-01-02 15:04:05"))
-					return
-				default:
-				}
-
-				// Find the corresponding destination airport object
-				currentFlight := p.FlightLog[len(p.FlightLog)-1]
-				var destinationAirport *Airport = nil
-				for i := range globalSimState.Airports {
-					ap := globalSimState.Airports[i]
-					// Match airport by location, using Epsilon for robust float comparison
-					if Distance(ap.Location, currentFlight.FlightSchedule.Destination) <
-						Epsilon {
-						destinationAirport = ap
-						break
-					}
-				}
-
-				if destinationAirport != nil {
-					// Call the Land function. It handles its own internal locking for runway use
-					// and updates globalSimState.PlanesInFlight by removing the landed plane.
-					// The Land function itself acquires the necessary simState.Mu lock for its modification.
-					err := destinationAirport.Land(p, globalSimState)
-					if err != nil {
-						// This error could be due to runway busy. The plane remains in PlanesInFlight
-						// and will be retried in the next monitor interval.
-					}
-				} else {
-					log.Printf("Monitor Error: Destination airport not found for plane %s (arrival coord: %s)\n",
-						p.Serial, currentFlight.FlightSchedule.Destination.String())
-					fmt.Fprintf(f, "%sMonitor Error: Destination airport not found for plane %s (arrival coord: %s)\n",
-						time.Now().Format("2006-01-02 15:04:05"), p.Serial, currentFlight.FlightSchedule.Destination.String())
-				}
-			}
-
-		}
-	}(simState, ctx)
+		simState.Clock.Now().Format("2006-01-02 15:04:05"), simState.DifferentAltitudes)
+	if !simState.Quiet {
+		fmt.Println("Remember type 'q' and hit Enter to immediately stop the simulation if needed")
+	}
 
-	// This wg.Wait() will block Start() until all goroutines have gracefully exited
-	wg.Wait()
+	runEventLoop(ctx, simState, durationMinutes)
+	simState.record(ReplayEntry{Kind: ReplayEnd})
 
-	log.Printf("\n--- All simulation goroutines have stopped. ---")
-	fmt.Fprintf(f, "%s\n--- All simulation goroutines have stopped. ---\n",
-		time.Now().Format("2006-01-02 15:04:05"))
+	log.Printf("\n--- Simulation event loop has stopped. ---")
+	fmt.Fprintf(f, "%s\n--- Simulation event loop has stopped. ---\n",
+		simState.Clock.Now().Format("2006-01-02 15:04:05"))
 	log.Printf("Final Simulation State Summary:")
 	fmt.Fprintf(f, "%sFinal Simulation State Summary:\n",
-		time.Now().Format("2006-01-02 15:04:05"))
+		simState.Clock.Now().Format("2006-01-02 15:04:05"))
 	simState.Mu.Lock() // Acquire lock to safely read final count of planes in flight
 	log.Printf("  Planes currently in flight: %d", len(simState.PlanesInFlight))
 	fmt.Fprintf(f, "%s  Planes currently in flight: %d\n",
-		time.Now().Format("2006-01-02 15:04:05"), len(simState.PlanesInFlight))
+		simState.Clock.Now().Format("2006-01-02 15:04:05"), len(simState.PlanesInFlight))
 	simState.Mu.Unlock()
 
 	for i := range simState.Airports {
@@ -184,11 +136,113 @@ func StartSimulation(simState *SimulationState, durationMinutes time.Duration) {
 		ap.Mu.Lock() // Acquire lock for each airport to safely read its parked planes count
 		log.Printf("  Airport %s has %d planes parked.", ap.Serial, len(ap.Planes))
 		fmt.Fprintf(f, "%s  Airport %s has %d planes parked.\n",
-			time.Now().Format("2006-01-02 15:04:05"), ap.Serial, len(ap.Planes))
+			simState.Clock.Now().Format("2006-01-02 15:04:05"), ap.Serial, len(ap.Planes))
 		ap.Mu.Unlock()
 	}
+	logTCASOutcomes(simState)
+	logSTCAOutcomes(simState)
+
 	log.Printf("--- TCAS Simulation Ended ---")
 	fmt.Fprintf(f, "%s--- TCAS Simulation Ended ---\n",
-		time.Now().Format("2006-01-02 15:04:05"))
+		simState.Clock.Now().Format("2006-01-02 15:04:05"))
+
+}
+
+// runEventLoop processes the scheduled events in order of simulation time until the simulation ends,
+// a crash halts it or the context is cancelled by EmergencyStop.
+// Before each event the loop waits on the simulation clock, which is what paces the run at the chosen speed.
+func runEventLoop(ctx context.Context, simState *SimulationState, durationMinutes time.Duration) {
+	for {
+		ev := simState.scheduler.Peek()
+		if ev == nil {
+			return
+		}
+		if !simState.Clock.WaitUntil(ctx, ev.Time) {
+			return
+		}
+		simState.scheduler.Pop()
+
+		// A snapshot is never taken in the middle of an event
+		simState.engineMu.Lock()
+		simState.Mu.Lock()
+		simState.CurrentSimTime = ev.Time
+		simState.Mu.Unlock()
+
+		keepRunning := processEvent(simState, ev, durationMinutes)
+		simState.engineMu.Unlock()
+		if !keepRunning {
+			return
+		}
+	}
+}
+
+// processEvent applies a single event to the simulation state.
+// It returns false when the event ends the simulation.
+func processEvent(simState *SimulationState, ev *Event, durationMinutes time.Duration) bool {
+	f := simState.ConsoleLog
+
+	switch ev.Kind {
+	case EventLaunchAttempt:
+		ev.Airport.launchAttempt(simState)
+
+	case EventTakeoffComplete:
+		if _, err := ev.Airport.completeTakeoff(ev.Plane, simState); err != nil {
+			log.Printf("error taking off from %s: %v", ev.Airport.Serial, err)
+			fmt.Fprintf(f, "%s error taking off from %s: %v\n",
+				simState.CurrentSimTime.Format("2006-01-02 15:04:05"), ev.Airport.Serial, err)
+		}
+		ev.Airport.scheduleNextLaunch(simState, 0)
+
+	case EventLandingRequest:
+		err := ev.Airport.Land(ev.Plane, simState)
+		if errors.Is(err, errRunwayInUse) {
+			// The plane holds until the runways in use are free.
+			simState.scheduler.Schedule(&Event{
+				Time:    simState.CurrentSimTime.Add(TakeoffDuration),
+				Kind:    EventLandingRequest,
+				Airport: ev.Airport,
+				Plane:   ev.Plane,
+			})
+		} else if err != nil {
+			log.Printf("Landing Error: %v\n", err)
+			fmt.Fprintf(f, "%sLanding Error: %v\n",
+				simState.CurrentSimTime.Format("2006-01-02 15:04:05"), err)
+		}
+
+	case EventLandingComplete:
+		if err := ev.Airport.completeLanding(ev.Plane, simState); err != nil {
+			log.Printf("Landing Error: %v\n", err)
+			fmt.Fprintf(f, "%sLanding Error: %v\n",
+				simState.CurrentSimTime.Format("2006-01-02 15:04:05"), err)
+		}
+
+	case EventTCASCheck:
+		checkTCAS(simState)
+		simState.scheduler.Schedule(&Event{Time: simState.CurrentSimTime.Add(TCASCheckInterval), Kind: EventTCASCheck})
+
+	case EventRadarSweep:
+		radarSweep(simState)
+		simState.scheduler.Schedule(&Event{Time: simState.CurrentSimTime.Add(simState.STCA.radarInterval()), Kind: EventRadarSweep})
+
+	case EventCrashShutdown:
+		simState.Mu.Lock()
+		crashed := simState.CrashedPlanes
+		simState.Mu.Unlock()
+		log.Printf("DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n",
+			crashed[0], crashed[1])
+		fmt.Fprintf(simState.TCASLog, "%s DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n",
+			simState.CurrentSimTime.Format("2006-01-02 15:04:05"), crashed[0], crashed[1])
+		fmt.Fprintf(f, "%s DISASTER OCCURED!: Plane %s and Plane %s CRASHED\n\n",
+			simState.CurrentSimTime.Format("2006-01-02 15:04:05"), crashed[0], crashed[1])
+		// at this point, the simulation ends
+		return false
+
+	case EventSimulationEnd:
+		log.Printf("\n--- Simulation Duration (%d minutes) Reached. Initiating shutdown... ---", durationMinutes)
+		fmt.Fprintf(f, "%s\n--- Simulation Duration (%d minutes) Reached. Initiating shutdown... ---\n",
+			simState.CurrentSimTime.Format("2006-01-02 15:04:05"), durationMinutes)
+		return false
+	}
 
+	return true
 }
@@ -0,0 +1,522 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/josephus-git/TCAS-simulation-Fyne/internal/config"
+)
+
+// Sweeps of the radar before an STCA alert is raised or cleared, so a single odd prediction neither raises nor
+// clears one
+const (
+	STCAConfirmSweeps = 2 // consecutive sweeps predicting a conflict before the alert is raised
+	STCAClearSweeps   = 3 // consecutive sweeps without a conflict before the alert is cleared
+)
+
+// STCAConfig describes the Short-Term Conflict Alert of the ground system: how often its radar sweeps the traffic,
+// how far ahead it predicts, and the separation minima whose loss it alerts about. Times and distances are those
+// of real flight; like the TCAS II thresholds, they are scaled by TCASTimeScale to the simulated world.
+type STCAConfig struct {
+	RadarInterval     float64 `json:"radar_interval"`     // seconds between two sweeps of the radar
+	LookAhead         float64 `json:"look_ahead"`         // seconds ahead the tracks are predicted
+	HorizontalMinimum float64 `json:"horizontal_minimum"` // nautical miles
+	VerticalMinimum   float64 `json:"vertical_minimum"`   // feet
+}
+
+// DefaultSTCAConfig is the STCA of a terminal area: a radar sweeping every 4.8 s, two minutes of prediction,
+// and the 3 NM and 1000 ft separation minima. It looks more than twice as far ahead as the TA of TCAS II.
+var DefaultSTCAConfig = STCAConfig{RadarInterval: 4.8, LookAhead: 120, HorizontalMinimum: 3, VerticalMinimum: 1000}
+
+// NewSTCAConfig returns the STCA configured for the simulation. A radar interval, look-ahead or minimum of 0 takes
+// the default one.
+func NewSTCAConfig(conf *config.Config) STCAConfig {
+	return STCAConfig{
+		RadarInterval: conf.STCARadarInterval,
+		LookAhead:     conf.STCALookAhead,
+	}.withDefaults()
+}
+
+// withDefaults returns the configuration with the default value of each setting left at 0, as in a snapshot saved
+// before STCA was modelled.
+func (c STCAConfig) withDefaults() STCAConfig {
+	if c.RadarInterval <= 0 {
+		c.RadarInterval = DefaultSTCAConfig.RadarInterval
+	}
+	if c.LookAhead <= 0 {
+		c.LookAhead = DefaultSTCAConfig.LookAhead
+	}
+	if c.HorizontalMinimum <= 0 {
+		c.HorizontalMinimum = DefaultSTCAConfig.HorizontalMinimum
+	}
+	if c.VerticalMinimum <= 0 {
+		c.VerticalMinimum = DefaultSTCAConfig.VerticalMinimum
+	}
+	return c
+}
+
+// Validate checks that the radar sweeps the traffic at least once within the look-ahead.
+func (c STCAConfig) Validate() error {
+	if c.RadarInterval <= 0 || c.LookAhead < c.RadarInterval {
+		return fmt.Errorf("the radar interval must be positive and the STCA look-ahead at least as long, got %v s and %v s",
+			c.RadarInterval, c.LookAhead)
+	}
+	return nil
+}
+
+// radarInterval returns the simulation time between two sweeps of the radar.
+func (c STCAConfig) radarInterval() time.Duration {
+	return time.Duration(c.RadarInterval * TCASTimeScale * float64(time.Second))
+}
+
+// RadarTrack is a plane as the ground system sees it: the plot of the last radar sweep, with the velocities
+// estimated from the plot before. Its altitude is the one the Mode C transponder reports, to the nearest 100 ft.
+type RadarTrack struct {
+	Serial string    `json:"serial"`
+	Time   time.Time `json:"time"` // of the last plot
+	Track  Track     `json:"track"`
+	Plots  int       `json:"plots"` // plots since the plane was first seen, the velocities are known from the second
+}
+
+// Surveillance is the picture of the traffic the ground system builds from its radar, one sweep at a time,
+// with the pairs of planes whose alerts are being confirmed or cleared.
+type Surveillance struct {
+	Tracks     map[string]*RadarTrack `json:"tracks"`     // planes seen on the last sweep, by serial
+	Confirming map[string]int         `json:"confirming"` // sweeps in a row a pair without an alert was predicted in conflict, by pairKey
+	Clearing   map[string]int         `json:"clearing"`   // sweeps in a row a pair with an alert was predicted clear, by pairKey
+}
+
+// newSurveillance returns a picture without any plane.
+func newSurveillance() *Surveillance {
+	return &Surveillance{Tracks: map[string]*RadarTrack{}, Confirming: map[string]int{}, Clearing: map[string]int{}}
+}
+
+// STCAAlert is a Short-Term Conflict Alert of the ground system about two planes, from the sweep it was raised to
+// the sweep it was cleared. It keeps what the ground predicted when it raised the alert and the RA of the planes'
+// TCAS against each other, so the ground alert and the airborne one can be compared. Like encounters, alerts are
+// stored on the simulation state, keyed by the pair of planes (see pairKey).
+type STCAAlert struct {
+	ID            string    `json:"id"`
+	Planes        [2]string `json:"planes"`                  // serials of the two planes, in order
+	Raised        time.Time `json:"raised"`                  // sweep the alert was raised at
+	Cleared       time.Time `json:"cleared,omitempty"`       // sweep the alert was cleared at, zero while it is on
+	PredictedLoss time.Time `json:"predicted_loss"`          // when the planes were predicted to lose separation, when the alert was raised
+	Horizontal    float64   `json:"horizontal"`              // map units, predicted distance at the closest approach within the look-ahead
+	Vertical      float64   `json:"vertical"`                // meters, predicted distance at that time
+	RAIssued      time.Time `json:"ra_issued,omitempty"`     // first RA between the planes while the alert was on, or already on when it was raised
+	EngagementID  string    `json:"engagement_id,omitempty"` // that RA, empty without one
+}
+
+// Active reports whether the alert is still on.
+func (a *STCAAlert) Active() bool {
+	return a.Cleared.IsZero()
+}
+
+// RALead returns how long, in real time, before the RA the alert was raised, negative when the RA came first, and
+// false without an RA.
+func (a *STCAAlert) RALead() (time.Duration, bool) {
+	if a.RAIssued.IsZero() {
+		return 0, false
+	}
+	return time.Duration(float64(a.RAIssued.Sub(a.Raised)) / TCASTimeScale), true
+}
+
+// HorizontalNM returns the predicted horizontal distance at the closest approach in real nautical miles, see
+// tcasRangeScale.
+func (a *STCAAlert) HorizontalNM() float64 {
+	return a.Horizontal / tcasRangeScale / NauticalMileToMeters
+}
+
+// VerticalFeet returns the predicted vertical distance at the closest approach in feet.
+func (a *STCAAlert) VerticalFeet() float64 {
+	return a.Vertical / FeetToMeters
+}
+
+// stcaPrediction is a predicted loss of separation between two radar tracks.
+type stcaPrediction struct {
+	loss                 float64 // simulation seconds until the separation is lost, 0 when it already is
+	horizontal, vertical float64 // distances at the closest approach within the look-ahead, map units and meters
+}
+
+// predictConflict extrapolates two radar tracks in straight lines over the look-ahead and returns when both the
+// horizontal and the vertical separation minima are lost at once, false if they are not.
+func (c STCAConfig) predictConflict(own, intruder Track) (stcaPrediction, bool) {
+	lookAhead := c.LookAhead * TCASTimeScale
+	minimum := c.HorizontalMinimum * NauticalMileToMeters * tcasRangeScale
+	verticalMinimum := c.VerticalMinimum * FeetToMeters
+
+	rx := intruder.Position.X - own.Position.X
+	ry := intruder.Position.Y - own.Position.Y
+	vx := intruder.VelocityX - own.VelocityX
+	vy := intruder.VelocityY - own.VelocityY
+	h := intruder.Altitude - own.Altitude
+	w := intruder.VerticalRate - own.VerticalRate
+
+	// Times the planes are within the horizontal minimum: |r + v t| < minimum
+	start, end := 0.0, lookAhead
+	a, b, d := vx*vx+vy*vy, rx*vx+ry*vy, rx*rx+ry*ry-minimum*minimum
+	if a == 0 {
+		if d >= 0 {
+			return stcaPrediction{}, false
+		}
+	} else {
+		discriminant := b*b - a*d
+		if discriminant <= 0 {
+			return stcaPrediction{}, false
+		}
+		root := math.Sqrt(discriminant)
+		start, end = math.Max(start, (-b-root)/a), math.Min(end, (-b+root)/a)
+	}
+
+	// Times they are within the vertical minimum: |h + w t| < verticalMinimum
+	if w == 0 {
+		if math.Abs(h) >= verticalMinimum {
+			return stcaPrediction{}, false
+		}
+	} else {
+		t1, t2 := (-verticalMinimum-h)/w, (verticalMinimum-h)/w
+		start, end = math.Max(start, math.Min(t1, t2)), math.Min(end, math.Max(t1, t2))
+	}
+	if start >= end {
+		return stcaPrediction{}, false
+	}
+
+	closest := 0.0
+	if a > 0 {
+		closest = math.Min(lookAhead, math.Max(0, -b/a))
+	}
+	return stcaPrediction{
+		loss:       start,
+		horizontal: math.Hypot(rx+vx*closest, ry+vy*closest),
+		vertical:   math.Abs(h + w*closest),
+	}, true
+}
+
+// radarSweep runs one sweep of the ground radar and of the STCA behind it. Every plane in flight whose transponder
+// reports its altitude is plotted; a plane without one is only a primary return, which STCA cannot predict
+// vertically, so it is not tracked. The velocities of a track are estimated from its last two plots, so the ground
+// learns of a manoeuvre only a sweep or two after it starts, unlike TCAS which tracks every cycle.
+// Each pair of tracks is predicted over the look-ahead; an alert is raised after STCAConfirmSweeps sweeps predicting
+// a conflict and cleared after STCAClearSweeps without. Planes on the runway or its approach are left to the
+// airport, as for collisions.
+func radarSweep(simState *SimulationState) {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+
+	simTime := simState.CurrentSimTime
+	if simState.Surveillance == nil {
+		simState.Surveillance = newSurveillance()
+	}
+	picture := simState.Surveillance
+
+	// Plot every plane in flight, the tracks of the planes no longer seen are dropped
+	planes := map[string]*Plane{}
+	tracks := map[string]*RadarTrack{}
+	for _, p := range simState.PlanesInFlight {
+		if !p.TCASCapability.reportsAltitude() {
+			continue
+		}
+		track, ok := planeTrack(p, simTime)
+		if !ok {
+			continue
+		}
+		plot := Track{Position: track.Position, Altitude: p.TCASCapability.reportedAltitude(track.Altitude)}
+		radar := &RadarTrack{Serial: p.Serial, Time: simTime, Track: plot, Plots: 1}
+		if previous, ok := picture.Tracks[p.Serial]; ok {
+			if dt := simTime.Sub(previous.Time).Seconds(); dt > 0 {
+				radar.Track.VelocityX = (plot.Position.X - previous.Track.Position.X) / dt
+				radar.Track.VelocityY = (plot.Position.Y - previous.Track.Position.Y) / dt
+				radar.Track.VerticalRate = (plot.Altitude - previous.Track.Altitude) / dt
+				radar.Plots = previous.Plots + 1
+			}
+		}
+		planes[p.Serial] = p
+		tracks[p.Serial] = radar
+	}
+	picture.Tracks = tracks
+
+	serials := []string{}
+	for serial := range tracks {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+
+	// Predict every pair of tracks with known velocities
+	predictions := map[string]stcaPrediction{}
+	for i, serial := range serials {
+		for _, other := range serials[i+1:] {
+			own, intruder := tracks[serial], tracks[other]
+			if own.Plots < 2 || intruder.Plots < 2 || atRunway(planes[serial], own.Track) || atRunway(planes[other], intruder.Track) {
+				continue
+			}
+			if prediction, ok := simState.STCA.predictConflict(own.Track, intruder.Track); ok {
+				predictions[pairKey(serial, other)] = prediction
+			}
+		}
+	}
+
+	// Every pair predicted in conflict, being confirmed or in an alert is taken, in order, so alerts are recorded
+	// the same way every run
+	pairs := map[string]bool{}
+	for key := range predictions {
+		pairs[key] = true
+	}
+	for key := range picture.Confirming {
+		pairs[key] = true
+	}
+	for key := range simState.STCAAlerts {
+		if simState.activeSTCAAlert(key) != nil {
+			pairs[key] = true
+		}
+	}
+	keys := []string{}
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		prediction, inConflict := predictions[key]
+		alert := simState.activeSTCAAlert(key)
+		switch {
+		case inConflict && alert == nil:
+			picture.Confirming[key]++
+			if picture.Confirming[key] >= STCAConfirmSweeps {
+				delete(picture.Confirming, key)
+				raiseSTCAAlert(simState, key, prediction)
+			}
+		case inConflict:
+			delete(picture.Clearing, key)
+		case alert == nil:
+			delete(picture.Confirming, key)
+		default:
+			// A plane that is no longer tracked, landed or on the runway, is out of the alert at once
+			picture.Clearing[key]++
+			if picture.Clearing[key] >= STCAClearSweeps || tracks[alert.Planes[0]] == nil || tracks[alert.Planes[1]] == nil ||
+				atRunway(planes[alert.Planes[0]], tracks[alert.Planes[0]].Track) || atRunway(planes[alert.Planes[1]], tracks[alert.Planes[1]].Track) {
+				delete(picture.Clearing, key)
+				clearSTCAAlert(simState, alert)
+			}
+		}
+	}
+
+	// The alerts on note the RA of the planes' TCAS against each other
+	for _, key := range sortedSTCAKeys(simState) {
+		alert := simState.activeSTCAAlert(key)
+		if alert == nil || alert.EngagementID != "" {
+			continue
+		}
+		p1, p2 := planes[alert.Planes[0]], planes[alert.Planes[1]]
+		if p1 == nil || p2 == nil {
+			continue
+		}
+		if engagement := activeRA(simState, p1, p2); engagement != nil {
+			alert.RAIssued = engagement.TimeOfEngagement
+			alert.EngagementID = engagement.EngagementID
+			simState.recordSTCAAlert(alert)
+		}
+	}
+}
+
+// sortedSTCAKeys returns the pairs of planes that had STCA alerts, in order.
+// It must be called with simState.Mu held.
+func sortedSTCAKeys(simState *SimulationState) []string {
+	keys := []string{}
+	for key := range simState.STCAAlerts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// activeSTCAAlert returns the alert on for a pair of planes, nil if there is none.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) activeSTCAAlert(key string) *STCAAlert {
+	alerts := simState.STCAAlerts[key]
+	if n := len(alerts); n > 0 && alerts[n-1].Active() {
+		return alerts[n-1]
+	}
+	return nil
+}
+
+// raiseSTCAAlert raises an alert for a pair of planes predicted to lose separation.
+// It must be called with simState.Mu held.
+func raiseSTCAAlert(simState *SimulationState, key string, prediction stcaPrediction) {
+	simTime := simState.CurrentSimTime
+	first, second, _ := strings.Cut(key, "/")
+	serials := [2]string{first, second}
+	if simState.STCAAlerts == nil {
+		simState.STCAAlerts = map[string][]*STCAAlert{}
+	}
+	alert := &STCAAlert{
+		ID:            fmt.Sprintf("STCA-%s-%s-%d", serials[0], serials[1], len(simState.STCAAlerts[key])+1),
+		Planes:        serials,
+		Raised:        simTime,
+		PredictedLoss: simTime.Add(time.Duration(prediction.loss * float64(time.Second))),
+		Horizontal:    prediction.horizontal,
+		Vertical:      prediction.vertical,
+	}
+	simState.STCAAlerts[key] = append(simState.STCAAlerts[key], alert)
+
+	fmt.Fprintf(simState.STCALog, "%s STCA: alert %s between %s and %s, separation lost in %.1f s, %.1f NM and %.0f ft apart at closest approach.\n\n",
+		simTime.Format("2006-01-02 15:04:05"), alert.ID, serials[0], serials[1], prediction.loss, alert.HorizontalNM(), alert.VerticalFeet())
+	simState.recordSTCAAlert(alert)
+}
+
+// clearSTCAAlert ends an alert whose planes are no longer predicted to lose separation.
+// It must be called with simState.Mu held.
+func clearSTCAAlert(simState *SimulationState, alert *STCAAlert) {
+	alert.Cleared = simState.CurrentSimTime
+	fmt.Fprintf(simState.STCALog, "%s STCA: alert %s between %s and %s cleared after %.1f s.\n\n",
+		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), alert.ID, alert.Planes[0], alert.Planes[1],
+		alert.Cleared.Sub(alert.Raised).Seconds())
+	simState.recordSTCAAlert(alert)
+}
+
+// recordSTCAAlert records a change of an alert for replays and shows it on the data blocks of both planes.
+// The alert is copied, as it carries on changing afterwards.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) recordSTCAAlert(alert *STCAAlert) {
+	updateSTCAConflicts(planesBySerial(simState), *alert)
+	recorded := *alert
+	simState.record(ReplayEntry{Kind: ReplaySTCA, Plane: alert.Planes[0], OtherPlane: alert.Planes[1], STCA: &recorded})
+}
+
+// updateSTCAConflicts adds the planes of an alert to each other's STCA conflicts while it is on, and removes them
+// once it is cleared.
+func updateSTCAConflicts(planes map[string]*Plane, alert STCAAlert) {
+	for i, serial := range alert.Planes {
+		if p := planes[serial]; p != nil {
+			p.STCAConflicts = stcaConflictsAfter(p.STCAConflicts, alert.Planes[1-i], alert.Active())
+		}
+	}
+}
+
+// stcaConflictsAfter returns the STCA conflicts of a plane once its alert with other is on, or cleared.
+// The list is copied, timelines share it between the states they build.
+func stcaConflictsAfter(conflicts []string, other string, active bool) []string {
+	after := []string{}
+	for _, serial := range conflicts {
+		if serial != other {
+			after = append(after, serial)
+		}
+	}
+	if active {
+		after = append(after, other)
+		sort.Strings(after)
+	}
+	return after
+}
+
+// applySTCAAlert stores an alert read from a replay or snapshot, replacing the earlier record of it.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) applySTCAAlert(alert STCAAlert) {
+	key := pairKey(alert.Planes[0], alert.Planes[1])
+	for i, a := range simState.STCAAlerts[key] {
+		if a.ID == alert.ID {
+			simState.STCAAlerts[key][i] = &alert
+			return
+		}
+	}
+	if simState.STCAAlerts == nil {
+		simState.STCAAlerts = map[string][]*STCAAlert{}
+	}
+	simState.STCAAlerts[key] = append(simState.STCAAlerts[key], &alert)
+}
+
+// STCAAlertList returns a copy of every STCA alert of the simulation, in the order they were raised.
+func (simState *SimulationState) STCAAlertList() []STCAAlert {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+
+	list := []STCAAlert{}
+	for _, alerts := range simState.STCAAlerts {
+		for _, a := range alerts {
+			list = append(list, *a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Raised.Equal(list[j].Raised) {
+			return list[i].Raised.Before(list[j].Raised)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// stcaOutcomes counts how the ground alerts and the airborne RAs of a simulation met: the RAs the STCA alerted
+// about before they were issued, with the time it gave, the RAs already on when it alerted, and the RAs it missed.
+type stcaOutcomes struct {
+	Alerts          int           // STCA alerts raised
+	AlertsWithoutRA int           // alerts whose planes never got an RA against each other while it was on
+	RAs             int           // RA engagements
+	RAsAlerted      int           // RAs issued while an STCA alert about the planes was on
+	RAsBeforeAlert  int           // RAs already on when the STCA alerted about the planes
+	RAsMissed       int           // RAs the STCA never alerted about
+	TotalLead       time.Duration // sum of the real time between the alert and the RA, of the RAs alerted
+}
+
+// outcomesOfSTCA counts the STCA alerts and RA engagements of the simulation, see stcaOutcomes.
+// It must be called with simState.Mu held, or once the simulation has stopped.
+func outcomesOfSTCA(simState *SimulationState) stcaOutcomes {
+	outcomes := stcaOutcomes{}
+	alerted := map[string]time.Duration{}
+	for _, key := range sortedSTCAKeys(simState) {
+		for _, alert := range simState.STCAAlerts[key] {
+			outcomes.Alerts++
+			lead, ok := alert.RALead()
+			if !ok {
+				outcomes.AlertsWithoutRA++
+				continue
+			}
+			// An RA may outlast an alert that is raised again, the first one counts
+			if _, seen := alerted[alert.EngagementID]; !seen {
+				alerted[alert.EngagementID] = lead
+			}
+		}
+	}
+
+	for _, engagement := range simState.Engagements {
+		if !engagement.Engaged {
+			continue
+		}
+		outcomes.RAs++
+		lead, ok := alerted[engagement.EngagementID]
+		switch {
+		case !ok:
+			outcomes.RAsMissed++
+		case lead >= 0:
+			outcomes.RAsAlerted++
+			outcomes.TotalLead += lead
+		default:
+			outcomes.RAsBeforeAlert++
+		}
+	}
+	return outcomes
+}
+
+// logSTCAOutcomes writes to the STCA log how the ground alerts of the simulation met the airborne RAs.
+// It must be called once the event loop has stopped.
+func logSTCAOutcomes(simState *SimulationState) {
+	simState.Mu.Lock()
+	outcomes := outcomesOfSTCA(simState)
+	simState.Mu.Unlock()
+
+	timestamp := simState.CurrentSimTime.Format("2006-01-02 15:04:05")
+	f := simState.STCALog
+	fmt.Fprintf(f, "%s --- STCA alerts and TCAS RAs ---\n", timestamp)
+	fmt.Fprintf(f, "%s   STCA alerts:               %d (%d without an RA)\n", timestamp, outcomes.Alerts, outcomes.AlertsWithoutRA)
+	fmt.Fprintf(f, "%s   RAs:                       %d\n", timestamp, outcomes.RAs)
+	if outcomes.RAsAlerted > 0 {
+		fmt.Fprintf(f, "%s   RAs after an STCA alert:   %d (%.1f real s after it on average)\n", timestamp, outcomes.RAsAlerted,
+			outcomes.TotalLead.Seconds()/float64(outcomes.RAsAlerted))
+	} else {
+		fmt.Fprintf(f, "%s   RAs after an STCA alert:   0\n", timestamp)
+	}
+	fmt.Fprintf(f, "%s   RAs before an STCA alert:  %d\n", timestamp, outcomes.RAsBeforeAlert)
+	fmt.Fprintf(f, "%s   RAs without an STCA alert: %d\n", timestamp, outcomes.RAsMissed)
+	fmt.Fprintln(f)
+}
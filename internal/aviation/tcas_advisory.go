@@ -0,0 +1,213 @@
+package aviation
+
+import (
+	"math"
+	"time"
+)
+
+// Unit conversions used by the TCAS II thresholds, which are defined in aviation units
+const (
+	FeetToMeters         = 0.3048
+	NauticalMileToMeters = 1852.0
+)
+
+// TCASTimeScale is how much faster the simulated world runs than real flight: a plane crosses the map in about
+// a minute of simulation time, not ten. The TCAS II time thresholds are scaled by it.
+const TCASTimeScale = 0.1
+
+// RealCruiseSpeed is the real cruise speed, in m/s, that CruiseSpeed stands for.
+// Together with TCASTimeScale it gives the length of a map unit, which scales the TCAS II distance thresholds.
+const RealCruiseSpeed = 250.0
+
+// tcasRangeScale converts real meters to map units.
+const tcasRangeScale = CruiseSpeed * TCASTimeScale / RealCruiseSpeed
+
+// tcasThresholds holds the TCAS II thresholds of a sensitivity level, in real units.
+// Tau is the time to closest approach, DMOD the range at which slow closures alert anyway
+// and ZTHR the altitude separation below which the vertical test passes.
+// ALIM is the vertical separation an RA aims for at the closest approach.
+type tcasThresholds struct {
+	TATau, RATau   float64 // seconds
+	TADMOD, RADMOD float64 // nautical miles
+	TAZTHR, RAZTHR float64 // feet
+	ALIM           float64 // feet
+}
+
+// Default altitude thresholds, in feet, of the TCAS II vertical test at sensitivity level 7
+const (
+	DefaultTAZTHR = 850.0
+	DefaultRAZTHR = 700.0
+)
+
+// DescendInhibitAltitude is the altitude, in feet above the ground, below which TCAS II issues no descend RA
+const DescendInhibitAltitude = 1100.0
+
+// sensitivityLevel is a TCAS II sensitivity level: the thresholds used while own altitude is below Ceiling.
+// The lower levels alert later and closer, so traffic near airports does not set off advisories all the time.
+type sensitivityLevel struct {
+	Level       int
+	Ceiling     float64 // feet above the ground, the airports are at sea level so it is also the pressure altitude
+	Thresholds  tcasThresholds
+	RAInhibited bool // RAs are inhibited, close to the ground TCAS II only issues TAs
+	Cruise      bool // the level of the cruising altitudes, whose vertical thresholds are those of the simulation
+}
+
+// sensitivityLevels are the TCAS II sensitivity levels by altitude band, from the ground up.
+// Above 42000 ft the vertical thresholds widen, the altimeters being less accurate up there.
+var sensitivityLevels = []sensitivityLevel{
+	{Level: 2, Ceiling: 1000, Thresholds: tcasThresholds{TATau: 20, TADMOD: 0.30, TAZTHR: 850}, RAInhibited: true},
+	{Level: 3, Ceiling: 2350, Thresholds: tcasThresholds{TATau: 25, RATau: 15, TADMOD: 0.33, RADMOD: 0.20, TAZTHR: 850, RAZTHR: 600, ALIM: 300}},
+	{Level: 4, Ceiling: 5000, Thresholds: tcasThresholds{TATau: 30, RATau: 20, TADMOD: 0.48, RADMOD: 0.35, TAZTHR: 850, RAZTHR: 600, ALIM: 300}},
+	{Level: 5, Ceiling: 10000, Thresholds: tcasThresholds{TATau: 40, RATau: 25, TADMOD: 0.75, RADMOD: 0.55, TAZTHR: 850, RAZTHR: 600, ALIM: 350}},
+	{Level: 6, Ceiling: 20000, Thresholds: tcasThresholds{TATau: 45, RATau: 30, TADMOD: 1.00, RADMOD: 0.80, TAZTHR: 850, RAZTHR: 600, ALIM: 400}},
+	{Level: 7, Ceiling: 42000, Thresholds: tcasThresholds{TATau: 48, RATau: 35, TADMOD: 1.30, RADMOD: 1.10, TAZTHR: DefaultTAZTHR, RAZTHR: DefaultRAZTHR, ALIM: 600}, Cruise: true},
+	{Level: 7, Ceiling: math.Inf(1), Thresholds: tcasThresholds{TATau: 48, RATau: 35, TADMOD: 1.30, RADMOD: 1.10, TAZTHR: 1200, RAZTHR: 800, ALIM: 700}},
+}
+
+// sensitivityLevelAt returns the sensitivity level TCAS II uses at the given altitude, in meters.
+func sensitivityLevelAt(altitude float64) sensitivityLevel {
+	feet := altitude / FeetToMeters
+	for _, level := range sensitivityLevels {
+		if feet < level.Ceiling {
+			return level
+		}
+	}
+	return sensitivityLevels[len(sensitivityLevels)-1]
+}
+
+// VerticalThresholds are the altitude thresholds (ZTHR), in feet, of the TCAS II vertical test of a simulation at
+// sensitivity level 7 below 42000 ft, where the planes cruise: an intruder closer than them vertically passes
+// the test whatever its vertical rate. The other levels keep the thresholds of the standard.
+type VerticalThresholds struct {
+	TAZTHR float64 `json:"ta_zthr"`
+	RAZTHR float64 `json:"ra_zthr"`
+}
+
+// NewVerticalThresholds returns the vertical thresholds with the given TA and RA ZTHR, in feet.
+// A threshold of 0 takes the TCAS II one.
+func NewVerticalThresholds(taZTHR, raZTHR float64) VerticalThresholds {
+	if taZTHR <= 0 {
+		taZTHR = DefaultTAZTHR
+	}
+	if raZTHR <= 0 {
+		raZTHR = DefaultRAZTHR
+	}
+	return VerticalThresholds{TAZTHR: taZTHR, RAZTHR: raZTHR}
+}
+
+// thresholdsAt returns the thresholds of the sensitivity level used at the given altitude, in meters,
+// with the vertical thresholds of v at cruising altitudes.
+func (v VerticalThresholds) thresholdsAt(altitude float64) tcasThresholds {
+	level := sensitivityLevelAt(altitude)
+	th := level.Thresholds
+	if level.Cruise {
+		th.TAZTHR = v.TAZTHR
+		th.RAZTHR = v.RAZTHR
+	}
+	return th
+}
+
+// getsRA reports whether the TCAS of a plane at the given track may give its crew an RA:
+// it must be a working TCAS II, above the altitude where RAs are inhibited.
+func getsRA(plane *Plane, track Track) bool {
+	return plane.TCASCapability.issuesRAs() && !sensitivityLevelAt(track.Altitude).RAInhibited
+}
+
+// Advisory is the alert the collision avoidance logic of a plane issues about an intruder, see CollisionAvoidance.
+type Advisory int
+
+const (
+	AdvisoryNone Advisory = iota
+	AdvisoryTA            // Traffic Advisory, the orange warning
+	AdvisoryRA            // Resolution Advisory, the engagement
+)
+
+// Track is the position and velocity of a plane in flight, as TCAS sees it through the plane's transponder.
+// Horizontal values are in map units and simulation seconds, altitudes in meters.
+type Track struct {
+	Position             Coordinate
+	VelocityX, VelocityY float64
+	Altitude             float64
+	VerticalRate         float64
+}
+
+// planeTrack returns the track of a plane at simTime, ok is false when the plane is not in transit.
+func planeTrack(plane *Plane, simTime time.Time) (Track, bool) {
+	position, ok := PlaneCurrentPosition(plane, simTime)
+	if !ok {
+		return Track{}, false
+	}
+
+	currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
+	track := Track{
+		Position:     position,
+		Altitude:     currentFlight.AltitudeAt(simTime),
+		VerticalRate: currentFlight.VerticalRateAt(simTime),
+	}
+	duration := currentFlight.DestinationArrivalTime.Sub(currentFlight.TakeoffTime).Seconds()
+	if duration > 0 {
+		track.VelocityX = (currentFlight.FlightSchedule.Destination.X - currentFlight.FlightSchedule.Depature.X) / duration
+		track.VelocityY = (currentFlight.FlightSchedule.Destination.Y - currentFlight.FlightSchedule.Depature.Y) / duration
+	}
+	return track, true
+}
+
+// horizontalRange returns the horizontal distance between two tracks and the rate at which it changes,
+// negative when the planes are closing.
+func horizontalRange(own, intruder Track) (rangeNow, rangeRate float64) {
+	rx := intruder.Position.X - own.Position.X
+	ry := intruder.Position.Y - own.Position.Y
+	vx := intruder.VelocityX - own.VelocityX
+	vy := intruder.VelocityY - own.VelocityY
+
+	rangeNow = math.Hypot(rx, ry)
+	if rangeNow == 0 {
+		return 0, -math.Hypot(vx, vy)
+	}
+	return rangeNow, (rx*vx + ry*vy) / rangeNow
+}
+
+// evaluateTCAS returns the advisory TCAS II issues to own about intruder with the given thresholds.
+// An RA is issued when both the RA range test and the RA vertical test pass, otherwise a TA when both TA tests pass.
+func evaluateTCAS(own, intruder Track, th tcasThresholds) Advisory {
+	rangeNow, rangeRate := horizontalRange(own, intruder)
+	altitudeSeparation := intruder.Altitude - own.Altitude
+	verticalRate := intruder.VerticalRate - own.VerticalRate
+
+	if rangeTest(rangeNow, rangeRate, th.RATau, th.RADMOD) && verticalTest(altitudeSeparation, verticalRate, th.RATau, th.RAZTHR) {
+		return AdvisoryRA
+	}
+	if rangeTest(rangeNow, rangeRate, th.TATau, th.TADMOD) && verticalTest(altitudeSeparation, verticalRate, th.TATau, th.TAZTHR) {
+		return AdvisoryTA
+	}
+	return AdvisoryNone
+}
+
+// rangeTest is the TCAS II horizontal test: the intruder is within DMOD, or closing with a modified range tau
+// below the tau threshold. The modified tau adds DMOD to the range so slow closures alert before they are too close,
+// and planes that are not closing, such as two planes flying parallel, never pass beyond DMOD.
+func rangeTest(rangeNow, rangeRate, tauSeconds, dmodNM float64) bool {
+	dmod := dmodNM * NauticalMileToMeters * tcasRangeScale
+	tau := tauSeconds * TCASTimeScale
+	if rangeNow < dmod {
+		return true
+	}
+	if rangeRate >= 0 {
+		return false
+	}
+	modifiedTau := (dmod*dmod - rangeNow*rangeNow) / (rangeNow * rangeRate)
+	return modifiedTau < tau
+}
+
+// verticalTest is the TCAS II vertical test: the altitude separation is below ZTHR,
+// or the planes are closing vertically and will reach the same altitude within the tau threshold.
+func verticalTest(altitudeSeparation, verticalRate, tauSeconds, zthrFeet float64) bool {
+	if math.Abs(altitudeSeparation) < zthrFeet*FeetToMeters {
+		return true
+	}
+	if altitudeSeparation*verticalRate >= 0 {
+		return false // not closing vertically
+	}
+	verticalTau := -altitudeSeparation / verticalRate
+	return verticalTau < tauSeconds*TCASTimeScale
+}
@@ -0,0 +1,50 @@
+package aviation
+
+import "testing"
+
+func TestRangeTest(t *testing.T) {
+	// At SL7 DMOD is 1.1 NM, about 8.15 map units, and tau 35 s is 3.5 simulation seconds
+	tests := []struct {
+		name      string
+		rangeNow  float64
+		rangeRate float64
+		want      bool
+	}{
+		{name: "within DMOD and diverging", rangeNow: 5, rangeRate: 10, want: true},
+		{name: "fast closure", rangeNow: 30, rangeRate: -10, want: true},
+		{name: "slow closure", rangeNow: 30, rangeRate: -5, want: false},
+		{name: "parallel outside DMOD", rangeNow: 9, rangeRate: 0, want: false},
+		{name: "diverging outside DMOD", rangeNow: 20, rangeRate: 5, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rangeTest(tt.rangeNow, tt.rangeRate, 35, 1.1); got != tt.want {
+				t.Errorf("rangeTest(%v, %v) = %v, want %v", tt.rangeNow, tt.rangeRate, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerticalTest(t *testing.T) {
+	// ZTHR 600 ft is 182.88 m, and tau 35 s is 3.5 simulation seconds
+	tests := []struct {
+		name         string
+		separation   float64
+		verticalRate float64
+		want         bool
+	}{
+		{name: "within ZTHR above", separation: 100, verticalRate: 0, want: true},
+		{name: "within ZTHR below", separation: -150, verticalRate: 0, want: true},
+		{name: "outside ZTHR and diverging", separation: 500, verticalRate: 50, want: false},
+		{name: "closing within tau", separation: 500, verticalRate: -200, want: true},
+		{name: "closing too slowly", separation: 500, verticalRate: -100, want: false},
+		{name: "closing from below within tau", separation: -500, verticalRate: 200, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verticalTest(tt.separation, tt.verticalRate, 35, 600); got != tt.want {
+				t.Errorf("verticalTest(%v, %v) = %v, want %v", tt.separation, tt.verticalRate, got, tt.want)
+			}
+		})
+	}
+}
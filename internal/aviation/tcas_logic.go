@@ -0,0 +1,407 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// DefaultCollisionRadius is the horizontal size, in map units, of the collision volume when none is configured.
+// It is kept below the RA DMOD, so TCAS always has a chance to separate the planes vertically before they meet.
+const DefaultCollisionRadius = 5.0
+
+// DefaultCollisionHeight is the vertical size, in feet, of the collision volume when none is configured
+const DefaultCollisionHeight = 100.0
+
+// RunwayZoneRadius is the distance, in map units, from the airports within which a departing or arriving plane is on
+// the runway or its approach. Airports are points of the map, so planes meet there; the runway rules of the airport,
+// not TCAS, keep them apart.
+const RunwayZoneRadius = 10.0
+
+// CollisionVolume is the space around a plane that no other plane may enter: two planes collide when, at their
+// closest point of approach, they are less than Radius apart horizontally and less than Height apart vertically.
+type CollisionVolume struct {
+	Radius float64 `json:"radius"` // map units
+	Height float64 `json:"height"` // meters
+}
+
+// NewCollisionVolume returns the collision volume with the given radius, in map units, and height, in feet.
+// A radius or height of 0 takes the default one.
+func NewCollisionVolume(radius, heightFeet float64) CollisionVolume {
+	if radius <= 0 {
+		radius = DefaultCollisionRadius
+	}
+	if heightFeet <= 0 {
+		heightFeet = DefaultCollisionHeight
+	}
+	return CollisionVolume{Radius: radius, Height: heightFeet * FeetToMeters}
+}
+
+// Contains reports whether a plane at the given horizontal and vertical distances, in map units and meters,
+// is inside the collision volume.
+func (v CollisionVolume) Contains(horizontal, vertical float64) bool {
+	return horizontal < v.Radius && math.Abs(vertical) < v.Height
+}
+
+// TCASCheckInterval is how often the TCAS monitor evaluates the proximity of planes in flight
+const TCASCheckInterval = 50 * time.Millisecond
+
+// CrashShutdownDelay is how long (in simulation time) the simulation keeps running after a collision before it is halted
+const CrashShutdownDelay = 3 * time.Second
+
+// checkTCAS runs one cycle of TCAS II for every plane currently in flight.
+// It tracks every intruder: each plane's TCASThreats lists the advisories found against all of them, and its
+// CurrentTCASEngagement is the most critical one (or nil if none). A Resolution Advisory is an engagement,
+// a Traffic Advisory a warning. RAs that are over are cleared of conflict, and a plane in RAs against several
+// intruders at once is given a composite RA, see resolveMultiThreats.
+// What each plane sees and is told depends on the equipage of both planes, see TCASCapability.
+// A crash is recorded on the simulation state the first time two planes come within each other's collision volume.
+func checkTCAS(simState *SimulationState) {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+
+	simTime := simState.CurrentSimTime
+
+	// Planes reaching the next phase of their flight plan start it before their tracks are taken
+	followFlightPlans(simState)
+
+	// Pre-calculate plane tracks and reset their current engagement state for this cycle.
+	// This map helps avoid re-calculating tracks multiple times and ensures all planes start clean.
+	planeTracks := make(map[*Plane]Track)
+	planesToCheck := []*Plane{}
+	previousThreats := make(map[*Plane][]TCASEngagement)
+	for _, p := range simState.PlanesInFlight {
+		previousThreats[p] = p.TCASThreats
+		// Reset the plane's threats at the start of each cycle.
+		// They will be set again below if active interactions are found.
+		p.CurrentTCASEngagement = nil
+		p.TCASThreats = nil
+		track, ok := planeTrack(p, simTime)
+		if !ok {
+			continue
+		}
+		planeTracks[p] = track
+		planesToCheck = append(planesToCheck, p)
+	}
+
+	threats := make(map[*Plane][]TCASEngagement)
+	for _, plane := range planesToCheck {
+		// Each plane decides its advisories with its own collision avoidance logic
+		avoidance := simState.avoidanceOf(plane)
+		own := aircraftOf(plane, planeTracks[plane])
+
+		// Loop through all other planes to find potential interactions
+		for _, otherPlane := range planesToCheck {
+			// Don't compare a plane with itself
+			if plane.Serial == otherPlane.Serial {
+				continue
+			}
+			// The intruder is seen at the altitude its transponder reports, in coarser steps from Mode C
+			intruderTrack := planeTracks[otherPlane]
+			intruderTrack.Altitude = otherPlane.TCASCapability.reportedAltitude(intruderTrack.Altitude)
+			intruder := aircraftOf(otherPlane, intruderTrack)
+			// Logic that remembers its advisories is told the RA each plane flies against the other
+			own.RA, intruder.RA = "", ""
+			active := activeRA(simState, plane, otherPlane)
+			if active != nil {
+				ownRA, _ := active.raOf(plane.Serial)
+				intruderRA, _ := active.raOf(otherPlane.Serial)
+				own.RA, intruder.RA = *ownRA, *intruderRA
+			}
+
+			// TCAS only sees intruders whose transponder reports their altitude, and only the planes fitted with
+			// a working TCAS alert their crew. Without the equipage for RAs the TCAS goes no further than a TA.
+			advisory := AdvisoryNone
+			if plane.TCASCapability.issuesTAs() && otherPlane.TCASCapability.reportsAltitude() {
+				advisory = avoidance.Advise(own, intruder)
+			}
+			// An RA issued by the other plane involves this one as well, even when this plane cannot see it.
+			// It goes on as long as the logic of the plane that issued it says, so both agree when it ends.
+			if active != nil {
+				continues := avoidance.RAContinues(own, intruder)
+				if active.PlaneSerial != plane.Serial {
+					continues = simState.avoidanceOf(otherPlane).RAContinues(intruder, own)
+				}
+				if continues {
+					advisory = AdvisoryRA
+					// The RA is revised once per cycle, from the plane it was first issued to
+					if plane.Serial == active.PlaneSerial {
+						reviseRA(simState, plane, otherPlane, active, planeTracks[plane], planeTracks[otherPlane])
+					}
+				} else {
+					clearOfConflict(simState, plane, otherPlane, active)
+				}
+			}
+
+			if advisory == AdvisoryRA {
+				// This is a full engagement.
+				// Call tcasCore which handles finding/creating the persistent record.
+				// Every intruder is tracked, a plane can be in RAs against several of them at once.
+				threats[plane] = append(threats[plane], tcasCore(simState, plane, otherPlane, planeTracks[plane], planeTracks[otherPlane]))
+			} else if advisory == AdvisoryTA {
+				// This is a warning.
+				// This warning is *transient* and not persisted in SimulationState.Engagements.
+				threats[plane] = append(threats[plane], TCASEngagement{
+					EngagementID:     fmt.Sprintf("W-Disp-%s-%s-%d", plane.Serial, otherPlane.Serial, simTime.UnixNano()), // Transient ID
+					PlaneSerial:      plane.Serial,
+					OtherPlaneSerial: otherPlane.Serial,
+					TimeOfEngagement: simTime,
+					WillCrash:        false,
+					WarningTriggered: true,
+					Engaged:          false,
+				})
+			}
+		} // End of inner loop (otherPlane)
+	} // End of outer loop (plane)
+
+	// A plane in RAs against several intruders flies a single RA against all of them
+	resolveMultiThreats(simState, planesToCheck, planeTracks)
+
+	// Each plane lists its threats, RAs first, and shows the most critical one.
+	// The RAs are taken again from the records, resolveMultiThreats may have changed them.
+	for _, plane := range planesToCheck {
+		planeThreats := threats[plane]
+		for i := range planeThreats {
+			if record, ok := simState.Engagements[planeThreats[i].EngagementID]; ok && planeThreats[i].Engaged {
+				planeThreats[i] = *record
+			}
+		}
+		sort.SliceStable(planeThreats, func(i, j int) bool { return planeThreats[i].Engaged && !planeThreats[j].Engaged })
+		plane.TCASThreats = planeThreats
+		if len(planeThreats) > 0 {
+			plane.CurrentTCASEngagement = &planeThreats[0]
+		}
+	}
+
+	// Whatever TCAS did, two planes that come too close to each other collide
+	for i, plane := range planesToCheck {
+		for _, otherPlane := range planesToCheck[i+1:] {
+			if atRunway(plane, planeTracks[plane]) || atRunway(otherPlane, planeTracks[otherPlane]) {
+				continue
+			}
+			horizontal, vertical := closestApproachSince(planeTracks[plane], planeTracks[otherPlane], TCASCheckInterval.Seconds())
+			if simState.Collision.Contains(horizontal, vertical) {
+				recordCrash(simState, plane.Serial, otherPlane.Serial, horizontal, vertical)
+			}
+		}
+	}
+
+	// The encounters follow the threats of the cycle, and end with a collision
+	updateEncounters(simState, planesToCheck, planeTracks)
+
+	// Only the changes of TCAS state are recorded for replays, not every cycle
+	for _, p := range simState.PlanesInFlight {
+		if !sameTCASThreats(previousThreats[p], p.TCASThreats) {
+			simState.record(ReplayEntry{Kind: ReplayTCAS, Plane: p.Serial, Engagement: p.CurrentTCASEngagement, Threats: p.TCASThreats})
+		}
+	}
+}
+
+// sameTCASThreats reports whether two lists of threats of a plane would be displayed the same way.
+func sameTCASThreats(a, b []TCASEngagement) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameTCASState(&a[i], &b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameTCASState reports whether two TCAS states of a plane would be displayed the same way.
+func sameTCASState(a, b *TCASEngagement) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.OtherPlaneSerial == b.OtherPlaneSerial && a.Engaged == b.Engaged && a.WillCrash == b.WillCrash
+}
+
+// closestApproachSince returns the horizontal and vertical distances between two planes at their closest point of
+// approach during the last window seconds, going back along their tracks. Checking the whole interval since the
+// previous TCAS check means two fast planes cannot pass through each other between two checks.
+func closestApproachSince(own, intruder Track, window float64) (horizontal, vertical float64) {
+	rx := intruder.Position.X - own.Position.X
+	ry := intruder.Position.Y - own.Position.Y
+	vx := intruder.VelocityX - own.VelocityX
+	vy := intruder.VelocityY - own.VelocityY
+
+	t := 0.0
+	if speed2 := vx*vx + vy*vy; speed2 > 0 {
+		t = math.Min(0, math.Max(-window, -(rx*vx+ry*vy)/speed2))
+	}
+	horizontal = math.Hypot(rx+vx*t, ry+vy*t)
+	vertical = math.Abs(intruder.Altitude - own.Altitude + (intruder.VerticalRate-own.VerticalRate)*t)
+	return horizontal, vertical
+}
+
+// atRunway reports whether a plane is within RunwayZoneRadius of the airport it departed from or is flying to.
+func atRunway(plane *Plane, track Track) bool {
+	currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
+	return Distance(track.Position, currentFlight.FlightSchedule.Depature) < RunwayZoneRadius ||
+		Distance(track.Position, currentFlight.FlightSchedule.Destination) < RunwayZoneRadius
+}
+
+// recordCrash stores the first collision of the simulation, with the distances between the planes at their
+// closest point of approach, and schedules the halt of the simulation after CrashShutdownDelay,
+// giving observers time to see the collision.
+// It must be called with simState.Mu held.
+func recordCrash(simState *SimulationState, planeSerial, otherPlaneSerial string, horizontal, vertical float64) {
+	if len(simState.CrashedPlanes) > 0 {
+		return
+	}
+	simState.CrashedPlanes = []string{planeSerial, otherPlaneSerial}
+	crashEncounter(simState, planeSerial, otherPlaneSerial, horizontal, vertical)
+	fmt.Fprintf(simState.TCASLog, "%s Collision between %s and %s: %.2f units and %.0f m apart at closest approach.\n\n",
+		simState.CurrentSimTime.Format("2006-01-02 15:04:05"), planeSerial, otherPlaneSerial, horizontal, vertical)
+	simState.record(ReplayEntry{Kind: ReplayCrash, Plane: planeSerial, OtherPlane: otherPlaneSerial})
+
+	simState.scheduler.Schedule(&Event{Time: simState.CurrentSimTime.Add(CrashShutdownDelay), Kind: EventCrashShutdown})
+}
+
+// tcasCore issues a Resolution Advisory between two planes and ensures a TCASEngagement record
+// is stored only once per RA for a given pair of planes, until the RA is clear of conflict.
+// One plane is told to climb and the other to descend, the RA is coordinated when both have a working TCAS II and
+// one-sided otherwise. The crew of each plane given the RA responds as its pilot model draws, which changes the vertical profile of the plane. Whether the planes collide depends only on
+// where the manoeuvres take them.
+// The RA is revised every cycle after that, see reviseRA.
+// It returns the relevant TCASEngagement (either newly created or existing).
+func tcasCore(simState *SimulationState, plane1, plane2 *Plane, track1, track2 Track) TCASEngagement {
+	if len(plane1.FlightLog) == 0 || len(plane2.FlightLog) == 0 {
+		// These planes are not on an active flight. Defensive check.
+		return TCASEngagement{}
+	}
+
+	if existingEngagement := activeRA(simState, plane1, plane2); existingEngagement != nil {
+		// The RA is already going on for this pair, reuse it.
+		// Its WillCrash prediction is made when it was first issued, reviseRA changes its senses and strength.
+		return *existingEngagement
+	}
+
+	// No existing engagement found, so create a new one.
+	tcasLog := simState.TCASLog
+	engagementTime := simState.CurrentSimTime
+
+	// The logic of the plane issuing the RA chooses its sense, the other plane gets the complementary one
+	climbingPlane := plane2
+	if simState.avoidanceOf(plane1).Climb(aircraftOf(plane1, track1), aircraftOf(plane2, track2)) {
+		climbingPlane = plane1
+	}
+	tracks := map[*Plane]Track{plane1: track1, plane2: track2}
+	responses := map[*Plane]string{}
+	for _, p := range []*Plane{plane1, plane2} {
+		sense := "descend"
+		if p == climbingPlane {
+			sense = "climb"
+		}
+		// A plane without a working TCAS II gets no RA, so it carries on as if nothing happened.
+		// The RA of the other plane is then one-sided, there is nothing to coordinate it with.
+		if !p.TCASCapability.issuesRAs() {
+			responses[p] = ResponseNoRA
+			fmt.Fprintf(tcasLog, "%s TCAS: %s has no RA (%s), the RA of the other plane is not coordinated with it.\n\n",
+				engagementTime.Format("2006-01-02 15:04:05"), p.Serial, p.TCASCapability.Label())
+			continue
+		}
+		// Close to the ground the TCAS II of the plane inhibits its RAs
+		if !getsRA(p, tracks[p]) {
+			responses[p] = ResponseNoRA
+			fmt.Fprintf(tcasLog, "%s TCAS: %s gets no RA, RAs are inhibited below %.0f ft.\n\n",
+				engagementTime.Format("2006-01-02 15:04:05"), p.Serial, sensitivityLevels[0].Ceiling)
+			continue
+		}
+
+		responses[p] = p.Pilot.respond(simState.rng)
+		maneuver, ok := pilotManeuver(p, engagementTime, p == climbingPlane, responses[p])
+		if !ok {
+			fmt.Fprintf(tcasLog, "%s TCAS: RA, %s, %s (SL%d). The crew does not respond.\n\n",
+				engagementTime.Format("2006-01-02 15:04:05"), sense, p.Serial, sensitivityLevelAt(tracks[p].Altitude).Level)
+			continue
+		}
+		addManeuver(simState, p, maneuver)
+		flown := "climbs"
+		if maneuver.VerticalRate < 0 {
+			flown = "descends"
+		}
+		fmt.Fprintf(tcasLog, "%s TCAS: RA, %s, %s (SL%d). The crew %s after %.1f s at %.0f ft/min (%s).\n\n",
+			engagementTime.Format("2006-01-02 15:04:05"), sense, p.Serial, sensitivityLevelAt(tracks[p].Altitude).Level, flown,
+			p.Pilot.ResponseDelay, math.Abs(verticalSpeedFPM(maneuver.VerticalRate)), responses[p])
+	}
+
+	// Predict the separation at the closest approach with the manoeuvres now flown
+	timeToCPA, missDistance := closestApproach(track1, track2)
+	cpaTime := engagementTime.Add(time.Duration(timeToCPA * float64(time.Second)))
+	verticalSeparation := math.Abs(plane1.FlightLog[len(plane1.FlightLog)-1].AltitudeAt(cpaTime) -
+		plane2.FlightLog[len(plane2.FlightLog)-1].AltitudeAt(cpaTime))
+	shouldCrash := simState.Collision.Contains(missDistance, verticalSeparation)
+	if shouldCrash {
+		fmt.Fprintf(tcasLog, "%s TCAS: RA between %s and %s will not keep them apart (%.1f units, %.0f m at closest approach).\n\n",
+			engagementTime.Format("2006-01-02 15:04:05"), plane1.Serial, plane2.Serial, missDistance, verticalSeparation)
+	} else {
+		fmt.Fprintf(tcasLog, "%s TCAS: RA between %s and %s, %.1f units and %.0f m apart expected at closest approach.\n\n",
+			engagementTime.Format("2006-01-02 15:04:05"), plane1.Serial, plane2.Serial, missDistance, verticalSeparation)
+	}
+
+	newTcasEngagement := TCASEngagement{
+		EngagementID:       fmt.Sprintf("E-%s-%s-%d", plane1.Serial, plane2.Serial, engagementTime.UnixNano()), // Unique ID
+		FlightID:           plane1.FlightLog[len(plane1.FlightLog)-1].FlightID,                                 // Associate with the specific flights
+		OtherFlightID:      plane2.FlightLog[len(plane2.FlightLog)-1].FlightID,
+		PlaneSerial:        plane1.Serial,
+		OtherPlaneSerial:   plane2.Serial,
+		TimeOfEngagement:   engagementTime,
+		WillCrash:          shouldCrash, // Determined here, will be consistent for both planes.
+		WarningTriggered:   false,       // This is an *engagement*, not just a warning
+		Engaged:            true,        // Mark as engaged (green/red state)
+		ClimbingPlane:      climbingPlane.Serial,
+		PlaneResponse:      responses[plane1],
+		OtherPlaneResponse: responses[plane2],
+	}
+	for _, p := range []*Plane{plane1, plane2} {
+		if ra, response := newTcasEngagement.raOf(p.Serial); response != ResponseNoRA {
+			*ra = raSense(p == climbingPlane, false)
+		}
+	}
+
+	// A single record is kept for the pair, both planes' histories refer to it
+	simState.addEngagement(newTcasEngagement, plane1, plane2)
+	simState.record(ReplayEntry{Kind: ReplayEngagement, Plane: plane1.Serial, OtherPlane: plane2.Serial, Engagement: &newTcasEngagement})
+
+	return newTcasEngagement
+}
+
+// PlaneCurrentPosition calculates the current position of a plane along its flight path.
+// This is used both by the TCAS monitor and by the UI for real-time animation.
+func PlaneCurrentPosition(plane *Plane, simTime time.Time) (Coordinate, bool) {
+	if len(plane.FlightLog) == 0 {
+		return Coordinate{}, false
+	}
+
+	currentFlight := plane.FlightLog[len(plane.FlightLog)-1]
+
+	if simTime.Before(currentFlight.TakeoffTime) {
+		// Plane hasn't taken off yet, return its departure airport's location
+		return currentFlight.FlightSchedule.Depature, false
+	} else if simTime.After(currentFlight.DestinationArrivalTime) {
+		// Plane has landed, return its destination airport's location
+		return currentFlight.FlightSchedule.Destination, false
+	} else {
+		// Plane is in transit
+		totalDuration := float64(currentFlight.DestinationArrivalTime.Sub(currentFlight.TakeoffTime))
+		elapsedDuration := float64(simTime.Sub(currentFlight.TakeoffTime))
+
+		if totalDuration == 0 { // Avoid division by zero
+			return currentFlight.FlightSchedule.Depature, true
+		}
+
+		// Interpolation factor (0.0 at takeoff, 1.0 at arrival)
+		t := elapsedDuration / totalDuration
+
+		// Linear interpolation for X, Y, Z
+		x := currentFlight.FlightSchedule.Depature.X + t*(currentFlight.FlightSchedule.Destination.X-currentFlight.FlightSchedule.Depature.X)
+		y := currentFlight.FlightSchedule.Depature.Y + t*(currentFlight.FlightSchedule.Destination.Y-currentFlight.FlightSchedule.Depature.Y)
+		z := currentFlight.FlightSchedule.Depature.Z + t*(currentFlight.FlightSchedule.Destination.Z-currentFlight.FlightSchedule.Depature.Z)
+
+		return Coordinate{X: x, Y: y, Z: z}, true
+	}
+}
@@ -0,0 +1,57 @@
+package aviation
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClosestApproachSince(t *testing.T) {
+	tests := []struct {
+		name           string
+		own, intruder  Track
+		window         float64
+		wantHorizontal float64
+		wantVertical   float64
+	}{
+		{
+			name:           "static",
+			own:            Track{Altitude: 10000},
+			intruder:       Track{Position: Coordinate{X: 3, Y: 4}, Altitude: 10100},
+			window:         0.05,
+			wantHorizontal: 5,
+			wantVertical:   100,
+		},
+		{
+			name:           "still closing",
+			own:            Track{VelocityX: 10, Altitude: 10000},
+			intruder:       Track{Position: Coordinate{X: 1}, VelocityX: -10, Altitude: 10000},
+			window:         0.05,
+			wantHorizontal: 1,
+			wantVertical:   0,
+		},
+		{
+			name:           "passed through each other within the window",
+			own:            Track{VelocityX: 10, Altitude: 10000},
+			intruder:       Track{Position: Coordinate{X: -1}, VelocityX: -10, Altitude: 10100, VerticalRate: 200},
+			window:         0.05,
+			wantHorizontal: 0,
+			wantVertical:   90,
+		},
+		{
+			name:           "passed before the window",
+			own:            Track{VelocityX: 10, Altitude: 10000},
+			intruder:       Track{Position: Coordinate{X: -10}, VelocityX: -10, Altitude: 10000},
+			window:         0.05,
+			wantHorizontal: 9,
+			wantVertical:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			horizontal, vertical := closestApproachSince(tt.own, tt.intruder, tt.window)
+			if math.Abs(horizontal-tt.wantHorizontal) > 1e-9 || math.Abs(vertical-tt.wantVertical) > 1e-9 {
+				t.Errorf("closestApproachSince() = %v, %v, want %v, %v", horizontal, vertical, tt.wantHorizontal, tt.wantVertical)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// RAMultiThreat is the kind of change of an RA replaced by the composite RA of a plane in several RAs at once
+const RAMultiThreat = "multi-threat"
+
+// raVerticalSense returns 1 for an RA that climbs, -1 for one that descends and 0 for a level off.
+func raVerticalSense(ra string) float64 {
+	switch ra {
+	case RAClimb, RAIncreaseClimb:
+		return 1
+	case RADescend, RAIncreaseDescent:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// lastRAChange returns when the RA of an engagement was issued or last revised.
+func lastRAChange(engagement *TCASEngagement) time.Time {
+	if n := len(engagement.Transitions); n > 0 {
+		return engagement.Transitions[n-1].Time
+	}
+	return engagement.TimeOfEngagement
+}
+
+// resolveMultiThreats gives a composite RA to every plane in RAs against two or more intruders at once, as TCAS II
+// does in dense airspace: a plane can only fly one manoeuvre, so the RAs issued against each intruder are replaced
+// by a single one against all of them. It climbs, descends, or levels off to pass between intruders above and below,
+// whichever leaves the plane furthest from the closest of its intruders at their closest approaches, each intruder
+// carrying on at its vertical rate. A TCAS II intruder is told the complementary sense, unless it is in several
+// RAs itself. Once flown, the composite RA only changes after the crew had time to respond, for a clear gain.
+// It must be called with simState.Mu held, with the tracks of the planes in flight.
+func resolveMultiThreats(simState *SimulationState, planes []*Plane, tracks map[*Plane]Track) {
+	simTime := simState.CurrentSimTime
+	bySerial := map[string]*Plane{}
+	for _, p := range planes {
+		bySerial[p.Serial] = p
+	}
+
+	for _, plane := range planes {
+		track := tracks[plane]
+		engagements := []*TCASEngagement{}
+		intruders := []*Plane{}
+		for _, e := range activeRAs(simState, plane) {
+			other := e.OtherPlaneSerial
+			if other == plane.Serial {
+				other = e.PlaneSerial
+			}
+			if intruder := bySerial[other]; intruder != nil {
+				engagements = append(engagements, e)
+				intruders = append(intruders, intruder)
+			}
+		}
+		if len(engagements) < 2 || !getsRA(plane, track) {
+			continue
+		}
+
+		// score is the smallest vertical separation, in feet, predicted with any intruder at its closest approach
+		// if the plane flies the given RA from now on
+		score := func(ra string) float64 {
+			rate := raPredictedRate(track.VerticalRate, ra)
+			closest := math.Inf(1)
+			for _, intruder := range intruders {
+				timeToCPA, _ := closestApproach(track, tracks[intruder])
+				own := track.Altitude + math.Max(-RAAltitudeDeviation, math.Min(RAAltitudeDeviation, rate*timeToCPA))
+				other := tracks[intruder].Altitude + tracks[intruder].VerticalRate*timeToCPA
+				closest = math.Min(closest, math.Abs(own-other)/FeetToMeters)
+			}
+			return closest
+		}
+		best := RAClimb
+		for _, ra := range []string{RADescend, RALevelOff} {
+			if ra == RADescend && track.Altitude < DescendInhibitAltitude*FeetToMeters {
+				continue
+			}
+			if score(ra) > score(best) {
+				best = ra
+			}
+		}
+
+		// The RAs against each intruder may not agree yet, then the composite RA replaces them at once
+		senses := map[float64]bool{}
+		current, lastChange := "", time.Time{}
+		for _, e := range engagements {
+			ra, _ := e.raOf(plane.Serial)
+			senses[raVerticalSense(*ra)] = true
+			current = *ra
+			if change := lastRAChange(e); change.After(lastChange) {
+				lastChange = change
+			}
+		}
+		if len(senses) == 1 {
+			settled := !simTime.Before(lastChange.Add(plane.Pilot.strengthenedDelay()))
+			if !settled || raVerticalSense(best) == raVerticalSense(current) || score(best) < score(current)+RAWeakenMargin {
+				continue
+			}
+		}
+
+		serials := []string{}
+		for _, intruder := range intruders {
+			serials = append(serials, intruder.Serial)
+		}
+		fmt.Fprintf(simState.TCASLog, "%s TCAS: %s is in RAs against %s at once, composite RA %s.\n\n",
+			simTime.Format("2006-01-02 15:04:05"), plane.Serial, strings.Join(serials, ", "), best)
+
+		response := ""
+		for i, e := range engagements {
+			intruder := intruders[i]
+			revised := *e
+			revised.Transitions = append([]RATransition{}, e.Transitions...)
+			ra, planeResponse := revised.raOf(plane.Serial)
+			response = planeResponse
+			if *ra != best {
+				revised.Transitions = append(revised.Transitions, RATransition{Time: simTime, Plane: plane.Serial,
+					Kind: RAMultiThreat, From: *ra, To: best})
+				*ra = best
+			}
+
+			// The senses of a coordinated RA stay complementary
+			if best != RALevelOff {
+				climbing := intruder.Serial
+				if best == RAClimb {
+					climbing = plane.Serial
+				}
+				if revised.ClimbingPlane != climbing {
+					revised.ClimbingPlane = climbing
+					otherRA, otherResponse := revised.raOf(intruder.Serial)
+					if *otherRA != "" && len(activeRAs(simState, intruder)) < 2 {
+						next := raSense(climbing == intruder.Serial, false)
+						revised.Transitions = append(revised.Transitions, RATransition{Time: simTime, Plane: intruder.Serial,
+							Kind: RAMultiThreat, From: *otherRA, To: next})
+						*otherRA = next
+						followRA(simState, intruder, RAMultiThreat, next, otherResponse)
+					}
+				}
+			}
+			storeRevisedRA(simState, bySerial[revised.PlaneSerial], bySerial[revised.OtherPlaneSerial], revised)
+		}
+		// The crew responds to the composite RA as it did to the latest of its RAs
+		followRA(simState, plane, RAMultiThreat, best, response)
+	}
+}
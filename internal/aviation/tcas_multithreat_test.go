@@ -0,0 +1,101 @@
+package aviation
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestResolveMultiThreats(t *testing.T) {
+	// Own flies east between two intruders met head-on from either side, a simulation second from both
+	own := Track{VelocityX: 10, Altitude: 10000}
+	intruder := func(x, altitude float64) Track {
+		return Track{Position: Coordinate{X: x, Y: 1}, VelocityX: 10 - x, Altitude: altitude}
+	}
+	tests := []struct {
+		name            string
+		tracks          []Track // of the intruders
+		ras             []string
+		issued          time.Duration // before now
+		wantRA          string
+		wantIntruderRAs []string
+	}{
+		{
+			name:            "conflicting senses between intruders above and below level off",
+			tracks:          []Track{intruder(20, 10100), intruder(-20, 9900)},
+			ras:             []string{RADescend, RAClimb},
+			issued:          time.Second,
+			wantRA:          RALevelOff,
+			wantIntruderRAs: []string{RAClimb, RADescend},
+		},
+		{
+			name:            "both intruders below climb",
+			tracks:          []Track{intruder(20, 9800), intruder(-20, 9850)},
+			ras:             []string{RADescend, RADescend},
+			issued:          time.Second,
+			wantRA:          RAClimb,
+			wantIntruderRAs: []string{RADescend, RADescend},
+		},
+		{
+			name:            "agreeing RAs are left while the crew responds",
+			tracks:          []Track{intruder(20, 9800), intruder(-20, 9850)},
+			ras:             []string{RADescend, RADescend},
+			issued:          0,
+			wantRA:          RADescend,
+			wantIntruderRAs: []string{RAClimb, RAClimb},
+		},
+		{
+			name:            "a single RA is not a multi-threat",
+			tracks:          []Track{intruder(20, 10100)},
+			ras:             []string{RADescend},
+			issued:          time.Second,
+			wantRA:          RADescend,
+			wantIntruderRAs: []string{RAClimb},
+		},
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	// plane flies level from now with the default crew
+	plane := func(serial string, track Track) *Plane {
+		return &Plane{Serial: serial, TCASCapability: TCASPerfect, Pilot: DefaultPilotModel,
+			FlightLog: []Flight{{FlightID: serial + "-f1", VerticalProfile: []VerticalManeuver{{Time: now,
+				Altitude: track.Altitude, TargetAltitude: track.Altitude}}}}}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			simState := &SimulationState{CurrentSimTime: now, Engagements: map[string]*TCASEngagement{}}
+			ownPlane := plane("p0", own)
+			planes := []*Plane{ownPlane}
+			tracks := map[*Plane]Track{ownPlane: own}
+			for i, track := range tt.tracks {
+				other := plane(fmt.Sprintf("p%d", i+1), track)
+				planes = append(planes, other)
+				tracks[other] = track
+
+				// p0 got the RA given, the intruder the complementary one
+				ra, otherRA, climbing := tt.ras[i], RAClimb, other.Serial
+				if raVerticalSense(ra) > 0 {
+					otherRA, climbing = RADescend, ownPlane.Serial
+				}
+				id := "e" + other.Serial
+				simState.Engagements[id] = &TCASEngagement{EngagementID: id, FlightID: "p0-f1",
+					OtherFlightID: other.Serial + "-f1", PlaneSerial: "p0", OtherPlaneSerial: other.Serial,
+					TimeOfEngagement: now.Add(-tt.issued), Engaged: true, ClimbingPlane: climbing, PlaneRA: ra,
+					OtherPlaneRA: otherRA, PlaneResponse: ResponseComplied, OtherPlaneResponse: ResponseComplied}
+				ownPlane.TCASEngagementIDs = append(ownPlane.TCASEngagementIDs, id)
+				other.TCASEngagementIDs = append(other.TCASEngagementIDs, id)
+			}
+
+			resolveMultiThreats(simState, planes, tracks)
+
+			for i, other := range planes[1:] {
+				got := simState.Engagements["e"+other.Serial]
+				if got.PlaneRA != tt.wantRA {
+					t.Errorf("RA of p0 against %s = %q, want %q", other.Serial, got.PlaneRA, tt.wantRA)
+				}
+				if got.OtherPlaneRA != tt.wantIntruderRAs[i] {
+					t.Errorf("RA of %s = %q, want %q", other.Serial, got.OtherPlaneRA, tt.wantIntruderRAs[i])
+				}
+			}
+		})
+	}
+}
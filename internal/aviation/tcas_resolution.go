@@ -0,0 +1,295 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RAVerticalRate is the vertical rate flown during a climb or descend RA (1500 ft/min), in meters per simulation second
+const RAVerticalRate = 1500 * FeetToMeters / 60 / TCASTimeScale
+
+// RAAltitudeDeviation is how far, in meters, a plane climbs or descends from its altitude during an RA before levelling off
+const RAAltitudeDeviation = 1000 * FeetToMeters
+
+// ReturnVerticalRate is the vertical rate of the return to the cleared altitude after clear of conflict (1000 ft/min),
+// in meters per simulation second
+const ReturnVerticalRate = 1000 * FeetToMeters / 60 / TCASTimeScale
+
+// Reasons of the vertical manoeuvres flown because of TCAS
+const (
+	ManeuverClimbRA           = "climb RA"
+	ManeuverDescendRA         = "descend RA"
+	ManeuverIncreaseClimbRA   = "increase climb RA"
+	ManeuverIncreaseDescentRA = "increase descent RA"
+	ManeuverLevelOffRA        = "level off RA"
+	ManeuverClearOfConflict   = "clear of conflict"
+)
+
+// isRAManeuver reports whether a manoeuvre is flown in response to an RA.
+func isRAManeuver(reason string) bool {
+	switch reason {
+	case ManeuverClimbRA, ManeuverDescendRA, ManeuverIncreaseClimbRA, ManeuverIncreaseDescentRA, ManeuverLevelOffRA:
+		return true
+	}
+	return false
+}
+
+// addEngagement keeps the record of a new RA, shared by both its planes, and adds it to the history of each.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) addEngagement(engagement TCASEngagement, planes ...*Plane) *TCASEngagement {
+	if simState.Engagements == nil {
+		simState.Engagements = map[string]*TCASEngagement{}
+	}
+	record := &engagement
+	simState.Engagements[engagement.EngagementID] = record
+	for _, p := range planes {
+		if p != nil {
+			p.TCASEngagementIDs = append(p.TCASEngagementIDs, engagement.EngagementID)
+		}
+	}
+	return record
+}
+
+// sortedEngagements returns the records of every RA of the simulation in the order they were issued.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) sortedEngagements() []*TCASEngagement {
+	list := make([]*TCASEngagement, 0, len(simState.Engagements))
+	for _, record := range simState.Engagements {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].TimeOfEngagement.Equal(list[j].TimeOfEngagement) {
+			return list[i].TimeOfEngagement.Before(list[j].TimeOfEngagement)
+		}
+		return list[i].EngagementID < list[j].EngagementID
+	})
+	return list
+}
+
+// legacyPlane is what is read of a plane saved before version 3 of snapshots and replays, when each plane kept its
+// own copy of the records of its RAs.
+type legacyPlane struct {
+	Serial                string
+	TCASEngagementRecords []TCASEngagement
+}
+
+// upgradeEngagements turns the copies of the records of the RAs kept by legacy planes into a single record per RA,
+// the history of each plane referring to it by ID. It returns the records in the order they were issued.
+func upgradeEngagements(legacy []legacyPlane, planes map[string]*Plane) []*TCASEngagement {
+	byID := map[string]*TCASEngagement{}
+	list := []*TCASEngagement{}
+	for _, old := range legacy {
+		plane := planes[old.Serial]
+		if plane == nil {
+			continue
+		}
+		plane.TCASEngagementIDs = nil
+		for _, engagement := range old.TCASEngagementRecords {
+			plane.TCASEngagementIDs = append(plane.TCASEngagementIDs, engagement.EngagementID)
+			// Both copies were kept the same, the first one read is kept
+			if _, ok := byID[engagement.EngagementID]; !ok {
+				record := engagement
+				byID[engagement.EngagementID] = &record
+				list = append(list, &record)
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].TimeOfEngagement.Before(list[j].TimeOfEngagement) })
+	return list
+}
+
+// engagementsOf returns the records of the RAs in the history of a plane, oldest first.
+// It must be called with simState.Mu held.
+func (simState *SimulationState) engagementsOf(plane *Plane) []*TCASEngagement {
+	records := []*TCASEngagement{}
+	for _, id := range plane.TCASEngagementIDs {
+		if record, ok := simState.Engagements[id]; ok {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// EngagementsOf returns a copy of the records of the RAs of a plane, oldest first.
+func (simState *SimulationState) EngagementsOf(plane *Plane) []TCASEngagement {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+
+	list := []TCASEngagement{}
+	for _, record := range simState.engagementsOf(plane) {
+		list = append(list, *record)
+	}
+	return list
+}
+
+// activeRA returns the record of the RA between plane and otherPlane on their current flights that is not yet clear
+// of conflict, or nil if there is none.
+// It must be called with simState.Mu held.
+func activeRA(simState *SimulationState, plane, otherPlane *Plane) *TCASEngagement {
+	if len(plane.FlightLog) == 0 || len(otherPlane.FlightLog) == 0 {
+		return nil
+	}
+	planeFlightID := plane.FlightLog[len(plane.FlightLog)-1].FlightID
+	otherFlightID := otherPlane.FlightLog[len(otherPlane.FlightLog)-1].FlightID
+
+	for _, rec := range simState.engagementsOf(plane) {
+		if rec.Engaged && rec.ClearOfConflict.IsZero() &&
+			rec.involvesFlight(plane.Serial, planeFlightID) && rec.involvesFlight(otherPlane.Serial, otherFlightID) {
+			return rec
+		}
+	}
+	return nil
+}
+
+// raContinues reports whether an RA already issued goes on: it lasts as long as the RA range test passes,
+// whatever the vertical separation, so the manoeuvre is not called off before the planes have passed each other.
+func raContinues(own, intruder Track, th tcasThresholds) bool {
+	rangeNow, rangeRate := horizontalRange(own, intruder)
+	return rangeTest(rangeNow, rangeRate, th.RATau, th.RADMOD)
+}
+
+// selectClimbingPlane chooses the senses of a coordinated RA and returns the serial of the plane that climbs:
+// one plane climbs and the other descends, whichever way round leaves them further apart vertically at the
+// closest approach once both have manoeuvred.
+// A plane that gets no RA, without a working TCAS II or too low for RAs, is expected to carry on at its vertical rate.
+// A plane that gets the RA below DescendInhibitAltitude is never told to descend. When both ways are as good,
+// the plane with the lower serial climbs, as the lower Mode S address wins the coordination in TCAS II.
+func selectClimbingPlane(aircraft1, aircraft2 Aircraft) string {
+	timeToCPA, _ := closestApproach(aircraft1.Track, aircraft2.Track)
+	predict := func(a Aircraft, climb bool) float64 {
+		if !a.GetsRA {
+			return a.Track.Altitude + a.Track.VerticalRate*timeToCPA
+		}
+		rate := raVerticalRate(a.Track.VerticalRate, climb)
+		return a.Track.Altitude + math.Max(-RAAltitudeDeviation, math.Min(RAAltitudeDeviation, rate*timeToCPA))
+	}
+	descendInhibited := func(a Aircraft) bool {
+		return a.GetsRA && a.Track.Altitude < DescendInhibitAltitude*FeetToMeters
+	}
+	separation1Climbs := predict(aircraft1, true) - predict(aircraft2, false)
+	separation2Climbs := predict(aircraft2, true) - predict(aircraft1, false)
+
+	switch {
+	case descendInhibited(aircraft1) && !descendInhibited(aircraft2):
+		return aircraft1.Serial
+	case descendInhibited(aircraft2) && !descendInhibited(aircraft1):
+		return aircraft2.Serial
+	case separation1Climbs > separation2Climbs+1:
+		return aircraft1.Serial
+	case separation2Climbs > separation1Climbs+1:
+		return aircraft2.Serial
+	case aircraft1.Serial < aircraft2.Serial:
+		return aircraft1.Serial
+	default:
+		return aircraft2.Serial
+	}
+}
+
+// raVerticalRate returns the vertical rate flown during an RA by a plane currently at verticalRate:
+// RAVerticalRate in the sense of the RA, or the current rate if it is already faster in that sense.
+func raVerticalRate(verticalRate float64, climb bool) float64 {
+	if climb {
+		return math.Max(verticalRate, RAVerticalRate)
+	}
+	return math.Min(verticalRate, -RAVerticalRate)
+}
+
+// closestApproach returns the time, in simulation seconds, until two tracks are at their closest horizontally
+// and their horizontal distance then. The time is zero when they are already moving apart.
+func closestApproach(own, intruder Track) (timeToCPA, missDistance float64) {
+	rx := intruder.Position.X - own.Position.X
+	ry := intruder.Position.Y - own.Position.Y
+	vx := intruder.VelocityX - own.VelocityX
+	vy := intruder.VelocityY - own.VelocityY
+
+	speed2 := vx*vx + vy*vy
+	if speed2 > 0 {
+		timeToCPA = math.Max(0, -(rx*vx+ry*vy)/speed2)
+	}
+	return timeToCPA, math.Hypot(rx+vx*timeToCPA, ry+vy*timeToCPA)
+}
+
+// addManeuver adds a manoeuvre to the current flight of a plane and records it for replays.
+// It must be called with simState.Mu held.
+func addManeuver(simState *SimulationState, plane *Plane, maneuver VerticalManeuver) {
+	flight := &plane.FlightLog[len(plane.FlightLog)-1]
+	flight.insertManeuver(maneuver)
+	simState.record(ReplayEntry{Kind: ReplayManeuver, Plane: plane.Serial, Maneuver: &maneuver})
+}
+
+// cancelPendingRAs drops the RA manoeuvres of a plane whose crew has not started them yet, and records it for replays.
+// It must be called with simState.Mu held.
+func cancelPendingRAs(simState *SimulationState, plane *Plane) {
+	flight := &plane.FlightLog[len(plane.FlightLog)-1]
+	for _, maneuver := range flight.pendingManeuvers(simState.CurrentSimTime) {
+		if !isRAManeuver(maneuver.Reason) {
+			continue
+		}
+		flight.removeManeuver(maneuver)
+		simState.record(ReplayEntry{Kind: ReplayManeuverCancelled, Plane: plane.Serial, Maneuver: &maneuver})
+	}
+}
+
+// clearOfConflict ends the RA between two planes: its record is closed and each plane that manoeuvred, and has no
+// other RA going on, returns to its cleared altitude: its cruising altitude en route, otherwise the one the phase of
+// its flight plan takes it to, see Flight.phases.
+// It must be called with simState.Mu held.
+func clearOfConflict(simState *SimulationState, plane1, plane2 *Plane, engagement *TCASEngagement) {
+	simTime := simState.CurrentSimTime
+	if record, ok := simState.Engagements[engagement.EngagementID]; ok {
+		record.ClearOfConflict = simTime
+	}
+
+	for _, p := range []*Plane{plane1, plane2} {
+		if hasActiveRA(simState, p) {
+			continue
+		}
+		// The conflict is over before the crew responded, the response is no longer needed
+		cancelPendingRAs(simState, p)
+		flight := p.FlightLog[len(p.FlightLog)-1]
+		altitude := flight.AltitudeAt(simTime)
+		if phase, ok := flight.phaseAt(simTime); ok && !phase.EnRoute {
+			// The manoeuvre keeps the reason of the phase, which is then under way
+			if maneuver, ok := phase.maneuver(simTime, altitude); ok {
+				addManeuver(simState, p, maneuver)
+			}
+			continue
+		}
+		clearedAltitude, rate := flight.CruisingAltitude, ReturnVerticalRate
+		if math.Abs(altitude-clearedAltitude) < 1 {
+			continue
+		}
+		if altitude > clearedAltitude {
+			rate = -rate
+		}
+		addManeuver(simState, p, VerticalManeuver{Time: simTime, Altitude: altitude, VerticalRate: rate,
+			TargetAltitude: clearedAltitude, Reason: ManeuverClearOfConflict})
+	}
+
+	fmt.Fprintf(simState.TCASLog, "%s TCAS: Clear of conflict between %s and %s.\n\n",
+		simTime.Format("2006-01-02 15:04:05"), plane1.Serial, plane2.Serial)
+}
+
+// hasActiveRA reports whether a plane is in an RA that is not yet clear of conflict on its current flight.
+// It must be called with simState.Mu held.
+func hasActiveRA(simState *SimulationState, plane *Plane) bool {
+	return len(activeRAs(simState, plane)) > 0
+}
+
+// activeRAs returns the records of the RAs of a plane on its current flight that are not yet clear of conflict,
+// one for each intruder.
+// It must be called with simState.Mu held.
+func activeRAs(simState *SimulationState, plane *Plane) []*TCASEngagement {
+	if len(plane.FlightLog) == 0 {
+		return nil
+	}
+	flightID := plane.FlightLog[len(plane.FlightLog)-1].FlightID
+	active := []*TCASEngagement{}
+	for _, rec := range simState.engagementsOf(plane) {
+		if rec.Engaged && rec.ClearOfConflict.IsZero() && rec.involvesFlight(plane.Serial, flightID) {
+			active = append(active, rec)
+		}
+	}
+	return active
+}
@@ -0,0 +1,237 @@
+package aviation
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RAs TCAS II gives a crew, the current one of each plane is kept on its engagement
+const (
+	RAClimb           = "climb"
+	RADescend         = "descend"
+	RAIncreaseClimb   = "increase climb"
+	RAIncreaseDescent = "increase descent"
+	RALevelOff        = "level off"
+)
+
+// Kinds of change of an RA after it was issued
+const (
+	RAStrengthened = "strengthened" // the RA asks for more: climb to increase climb, or level off back to climb
+	RAWeakened     = "weakened"     // enough separation is achieved, the crew may level off
+	RAReversed     = "reversed"     // the senses of the RA are swapped, the plane told to climb now descends
+)
+
+// IncreaseRAVerticalRate is the vertical rate of an increase climb or increase descent RA (2500 ft/min),
+// in meters per simulation second
+const IncreaseRAVerticalRate = 2500 * FeetToMeters / 60 / TCASTimeScale
+
+// RAWeakenMargin is how much further than ALIM, in feet, the planes must be predicted to pass before an RA is weakened,
+// so it is not weakened and strengthened again in turn
+const RAWeakenMargin = 100.0
+
+// RATransition is a change of the RA of a plane after it was issued.
+type RATransition struct {
+	Time  time.Time
+	Plane string // serial of the plane whose RA changed
+	Kind  string // RAStrengthened, RAWeakened, RAReversed or RAMultiThreat
+	From  string // RA before the change, see RAClimb
+	To    string // RA after the change
+}
+
+// raOf returns the current RA and crew response of the plane with the given serial in the engagement.
+func (e *TCASEngagement) raOf(serial string) (ra *string, response string) {
+	if serial == e.PlaneSerial {
+		return &e.PlaneRA, e.PlaneResponse
+	}
+	return &e.OtherPlaneRA, e.OtherPlaneResponse
+}
+
+// raSense returns the RA of the given sense and strength.
+func raSense(climb, increase bool) string {
+	switch {
+	case climb && increase:
+		return RAIncreaseClimb
+	case climb:
+		return RAClimb
+	case increase:
+		return RAIncreaseDescent
+	default:
+		return RADescend
+	}
+}
+
+// raPredictedRate returns the vertical rate TCAS expects of a plane at verticalRate flying the given RA.
+func raPredictedRate(verticalRate float64, ra string) float64 {
+	switch ra {
+	case RAClimb:
+		return raVerticalRate(verticalRate, true)
+	case RADescend:
+		return raVerticalRate(verticalRate, false)
+	case RAIncreaseClimb:
+		return math.Max(verticalRate, IncreaseRAVerticalRate)
+	case RAIncreaseDescent:
+		return math.Min(verticalRate, -IncreaseRAVerticalRate)
+	default:
+		return 0
+	}
+}
+
+// reviseRA runs one cycle of the RA monitoring of TCAS II on an RA going on between two planes, once their crews
+// had time to respond to it. The vertical separation at the closest approach is predicted from the tracks, each plane
+// with an RA flying it and the other carrying on at its vertical rate:
+//   - when an intruder moves the same way as the plane told to avoid it and separation falls short of ALIM, the senses
+//     are reversed, for both planes of a coordinated RA, if the other sense does better; this happens only once;
+//   - otherwise an RA falling short of ALIM is strengthened, to increase climb or descent, or back from a level off;
+//   - an RA predicted to clear ALIM with margin even if the plane levelled off now is weakened to level off.
+//
+// The RA of a plane in several RAs at once is left to resolveMultiThreats, and is never reversed here.
+// Each change is recorded on the engagement, and the crews who complied with the RA follow it after their
+// strengthened response delay; crews who ignored the RA or went the other way carry on with what they chose.
+// It must be called with simState.Mu held.
+func reviseRA(simState *SimulationState, plane1, plane2 *Plane, engagement *TCASEngagement, track1, track2 Track) {
+	simTime := simState.CurrentSimTime
+	planes := []*Plane{plane1, plane2}
+	tracks := map[*Plane]Track{plane1: track1, plane2: track2}
+	others := map[*Plane]*Plane{plane1: plane2, plane2: plane1}
+
+	// The crews need time to respond to the last change before it can be judged
+	lastChange := engagement.TimeOfEngagement
+	if n := len(engagement.Transitions); n > 0 {
+		lastChange = engagement.Transitions[n-1].Time
+	}
+	for _, p := range planes {
+		delay := p.Pilot.responseDelay()
+		if len(engagement.Transitions) > 0 {
+			delay = p.Pilot.strengthenedDelay()
+		}
+		if simTime.Before(lastChange.Add(delay)) {
+			return
+		}
+	}
+	timeToCPA, _ := closestApproach(track1, track2)
+	if timeToCPA <= 0 {
+		return
+	}
+
+	// separation predicts how far, in feet, p passes on the side of its sense (1 to climb, -1 to descend)
+	// if it flies at rate from now on
+	separation := func(p *Plane, rate, sense float64) float64 {
+		own, intruder := tracks[p], tracks[others[p]]
+		ownAltitude := own.Altitude + math.Max(-RAAltitudeDeviation, math.Min(RAAltitudeDeviation, rate*timeToCPA))
+		intruderAltitude := intruder.Altitude + intruder.VerticalRate*timeToCPA
+		return sense * (ownAltitude - intruderAltitude) / FeetToMeters
+	}
+	sense := func(p *Plane) float64 {
+		if engagement.ClimbingPlane == p.Serial {
+			return 1
+		}
+		return -1
+	}
+
+	multiThreat := map[*Plane]bool{}
+	for _, p := range planes {
+		multiThreat[p] = len(activeRAs(simState, p)) > 1
+	}
+
+	revised := *engagement
+	revised.Transitions = append([]RATransition{}, engagement.Transitions...)
+	changes := map[*Plane]string{}
+
+	if !revised.Reversed && !multiThreat[plane1] && !multiThreat[plane2] {
+		for _, p := range planes {
+			ra, _ := revised.raOf(p.Serial)
+			if *ra == "" {
+				continue
+			}
+			s := sense(p)
+			alim := simState.VerticalThresholds.thresholdsAt(tracks[p].Altitude).ALIM
+			current := separation(p, raPredictedRate(tracks[p].VerticalRate, *ra), s)
+			reversed := separation(p, raPredictedRate(tracks[p].VerticalRate, raSense(s < 0, false)), -s)
+			if tracks[others[p]].VerticalRate*s > 0 && current < alim && reversed > current {
+				revised.Reversed = true
+			}
+		}
+	}
+
+	if revised.Reversed && !engagement.Reversed {
+		revised.ClimbingPlane = plane1.Serial
+		if engagement.ClimbingPlane == plane1.Serial {
+			revised.ClimbingPlane = plane2.Serial
+		}
+		for _, p := range planes {
+			if ra, _ := revised.raOf(p.Serial); *ra != "" {
+				changes[p] = RAReversed
+				revised.Transitions = append(revised.Transitions, RATransition{Time: simTime, Plane: p.Serial,
+					Kind: RAReversed, From: *ra, To: raSense(revised.ClimbingPlane == p.Serial, false)})
+				*ra = raSense(revised.ClimbingPlane == p.Serial, false)
+			}
+		}
+	} else {
+		for _, p := range planes {
+			ra, _ := revised.raOf(p.Serial)
+			if *ra == "" || multiThreat[p] {
+				continue
+			}
+			s := sense(p)
+			alim := simState.VerticalThresholds.thresholdsAt(tracks[p].Altitude).ALIM
+			current := separation(p, raPredictedRate(tracks[p].VerticalRate, *ra), s)
+			levelled := separation(p, 0, s)
+
+			next, kind := *ra, ""
+			switch {
+			case *ra == RALevelOff && levelled < alim:
+				next, kind = raSense(s > 0, false), RAStrengthened
+			case (*ra == RAClimb || *ra == RADescend) && current < alim:
+				next, kind = raSense(s > 0, true), RAStrengthened
+			case *ra != RALevelOff && levelled >= alim+RAWeakenMargin:
+				next, kind = RALevelOff, RAWeakened
+			}
+			if kind == "" {
+				continue
+			}
+			changes[p] = kind
+			revised.Transitions = append(revised.Transitions, RATransition{Time: simTime, Plane: p.Serial, Kind: kind, From: *ra, To: next})
+			*ra = next
+		}
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	for _, p := range planes {
+		if kind, ok := changes[p]; ok {
+			ra, response := revised.raOf(p.Serial)
+			followRA(simState, p, kind, *ra, response)
+		}
+	}
+	storeRevisedRA(simState, plane1, plane2, revised)
+}
+
+// followRA makes the crew of a plane follow its revised RA after its strengthened response delay, if it complied
+// with the RA; crews who ignored the RA or went the other way carry on with what they chose.
+// It must be called with simState.Mu held.
+func followRA(simState *SimulationState, plane *Plane, kind, ra, response string) {
+	simTime := simState.CurrentSimTime
+	if response != ResponseComplied {
+		fmt.Fprintf(simState.TCASLog, "%s TCAS: RA %s, %s, %s. The crew carries on (%s).\n\n",
+			simTime.Format("2006-01-02 15:04:05"), kind, ra, plane.Serial, response)
+		return
+	}
+	// The new RA replaces whatever the crew had not started yet
+	cancelPendingRAs(simState, plane)
+	maneuver := raManeuver(plane, simTime.Add(plane.Pilot.strengthenedDelay()), ra)
+	addManeuver(simState, plane, maneuver)
+	fmt.Fprintf(simState.TCASLog, "%s TCAS: RA %s, %s, %s. The crew follows after %.1f s at %.0f ft/min.\n\n",
+		simTime.Format("2006-01-02 15:04:05"), kind, ra, plane.Serial, plane.Pilot.StrengthenedDelay,
+		math.Abs(verticalSpeedFPM(maneuver.VerticalRate)))
+}
+
+// storeRevisedRA replaces the record of the engagement, and records the revision for replays.
+// It must be called with simState.Mu held.
+func storeRevisedRA(simState *SimulationState, plane1, plane2 *Plane, revised TCASEngagement) {
+	if record, ok := simState.Engagements[revised.EngagementID]; ok {
+		*record = revised
+	}
+	simState.record(ReplayEntry{Kind: ReplayRARevised, Plane: plane1.Serial, OtherPlane: plane2.Serial, Engagement: &revised})
+}
@@ -0,0 +1,94 @@
+package aviation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReviseRA(t *testing.T) {
+	// Head-on, a simulation second from the closest approach
+	track := func(x, velocityX, altitude, verticalRate float64) Track {
+		return Track{Position: Coordinate{X: x}, VelocityX: velocityX, Altitude: altitude, VerticalRate: verticalRate}
+	}
+	tests := []struct {
+		name             string
+		track1, track2   Track
+		issued           time.Duration // before now
+		reversed         bool
+		wantRA1, wantRA2 string
+		wantClimbing     string
+		wantKind         string // of the last transition, empty for no change
+	}{
+		{
+			name:   "short of ALIM is strengthened",
+			track1: track(0, 10, 10000, 0), track2: track(20, -10, 10000, 0),
+			issued:  time.Second,
+			wantRA1: RAIncreaseClimb, wantRA2: RAIncreaseDescent, wantClimbing: "p1", wantKind: RAStrengthened,
+		},
+		{
+			name:   "clear of ALIM levelling off is weakened",
+			track1: track(0, 10, 10000, RAVerticalRate), track2: track(20, -10, 9700, 0),
+			issued:  time.Second,
+			wantRA1: RALevelOff, wantRA2: RALevelOff, wantClimbing: "p1", wantKind: RAWeakened,
+		},
+		{
+			name:   "intruder moving the same way is reversed",
+			track1: track(0, 10, 10000, 0), track2: track(20, -10, 9950, 100),
+			issued:  time.Second,
+			wantRA1: RADescend, wantRA2: RAClimb, wantClimbing: "p2", wantKind: RAReversed,
+		},
+		{
+			name:   "reversed only once",
+			track1: track(0, 10, 10000, 0), track2: track(20, -10, 9950, 100),
+			issued: time.Second, reversed: true,
+			wantRA1: RAIncreaseClimb, wantRA2: RAIncreaseDescent, wantClimbing: "p1", wantKind: RAStrengthened,
+		},
+		{
+			name:   "crews still responding",
+			track1: track(0, 10, 10000, 0), track2: track(20, -10, 10000, 0),
+			issued:  0,
+			wantRA1: RAClimb, wantRA2: RADescend, wantClimbing: "p1",
+		},
+	}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	// plane flies on from now as its track does vertically, with the default crew
+	plane := func(serial string, track Track) *Plane {
+		return &Plane{Serial: serial, TCASCapability: TCASPerfect, Pilot: DefaultPilotModel,
+			TCASEngagementIDs: []string{"e1"}, FlightLog: []Flight{{FlightID: serial + "-f1",
+				VerticalProfile: []VerticalManeuver{{Time: now, Altitude: track.Altitude, InitialRate: track.VerticalRate,
+					VerticalRate: track.VerticalRate, TargetAltitude: track.Altitude + track.VerticalRate*60}}}}}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plane1, plane2 := plane("p1", tt.track1), plane("p2", tt.track2)
+			engagement := &TCASEngagement{EngagementID: "e1", FlightID: "p1-f1", OtherFlightID: "p2-f1",
+				PlaneSerial: "p1", OtherPlaneSerial: "p2", TimeOfEngagement: now.Add(-tt.issued), Engaged: true,
+				ClimbingPlane: "p1", PlaneRA: RAClimb, OtherPlaneRA: RADescend, Reversed: tt.reversed,
+				PlaneResponse: ResponseComplied, OtherPlaneResponse: ResponseComplied}
+			simState := &SimulationState{CurrentSimTime: now, VerticalThresholds: NewVerticalThresholds(0, 0),
+				Engagements: map[string]*TCASEngagement{"e1": engagement}}
+
+			reviseRA(simState, plane1, plane2, engagement, tt.track1, tt.track2)
+
+			got := simState.Engagements["e1"]
+			if got.PlaneRA != tt.wantRA1 || got.OtherPlaneRA != tt.wantRA2 || got.ClimbingPlane != tt.wantClimbing {
+				t.Errorf("RAs = %q, %q, climbing %s, want %q, %q, climbing %s", got.PlaneRA, got.OtherPlaneRA,
+					got.ClimbingPlane, tt.wantRA1, tt.wantRA2, tt.wantClimbing)
+			}
+			kind := ""
+			if n := len(got.Transitions); n > 0 {
+				kind = got.Transitions[n-1].Kind
+			}
+			if kind != tt.wantKind {
+				t.Errorf("last transition = %q, want %q", kind, tt.wantKind)
+			}
+			if tt.wantKind != "" {
+				// The crew of the plane given a new RA follows it
+				last := plane1.FlightLog[0].VerticalProfile[len(plane1.FlightLog[0].VerticalProfile)-1]
+				if !last.Time.Equal(now.Add(plane1.Pilot.strengthenedDelay())) {
+					t.Errorf("the crew follows at %v, want after the strengthened delay", last.Time.Sub(now))
+				}
+			}
+		})
+	}
+}
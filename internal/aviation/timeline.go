@@ -0,0 +1,175 @@
+package aviation
+
+import (
+	"sync"
+	"time"
+)
+
+// Timeline is the history of a simulation kept in memory, so the simulation window can show the state
+// of the simulation at any earlier time. It holds the same entries as the replay file.
+type Timeline struct {
+	mu      sync.Mutex
+	start   time.Time
+	initial []TimelineFlight // planes already in flight when the recording started
+	entries []ReplayEntry
+}
+
+// TimelineFlight is a plane in flight at a given time of a timeline, with its TCAS state and STCA conflicts at
+// that time.
+type TimelineFlight struct {
+	Plane  string
+	Flight Flight
+	TCAS   *TCASEngagement
+	STCA   []string
+}
+
+// TimelineState is the state of a simulation at a given time of its timeline.
+type TimelineState struct {
+	Time          time.Time
+	Flights       []TimelineFlight
+	CrashedPlanes []string
+}
+
+// TimelineMarker is a notable moment of a timeline. Its kind is ReplayTakeoff, ReplayTCAS (a TCAS warning),
+// ReplayEngagement, ReplaySTCA (a ground alert raised) or ReplayCrash.
+type TimelineMarker struct {
+	Time time.Time
+	Kind string
+}
+
+// newTimeline creates the timeline of a simulation starting at start with the given planes in flight.
+// The flights are copied, so the timeline does not change as the planes carry on.
+func newTimeline(start time.Time, inFlight []*Plane) *Timeline {
+	tl := &Timeline{start: start}
+	for _, p := range inFlight {
+		if len(p.FlightLog) == 0 {
+			continue
+		}
+		flight := TimelineFlight{Plane: p.Serial, Flight: p.FlightLog[len(p.FlightLog)-1], STCA: p.STCAConflicts}
+		if p.CurrentTCASEngagement != nil {
+			engagement := *p.CurrentTCASEngagement
+			flight.TCAS = &engagement
+		}
+		tl.initial = append(tl.initial, flight)
+	}
+	return tl
+}
+
+// add appends a state change to the timeline, entries are added in order of simulation time.
+// The engagement and alert are copied, so the timeline keeps the TCAS and STCA state of that moment.
+func (tl *Timeline) add(entry ReplayEntry) {
+	if entry.Engagement != nil {
+		engagement := *entry.Engagement
+		entry.Engagement = &engagement
+	}
+	if entry.STCA != nil {
+		alert := *entry.STCA
+		entry.STCA = &alert
+	}
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.entries = append(tl.entries, entry)
+}
+
+// Start returns the simulation time the timeline starts at.
+func (tl *Timeline) Start() time.Time {
+	return tl.start
+}
+
+// End returns the simulation time of the last state change of the timeline.
+func (tl *Timeline) End() time.Time {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if len(tl.entries) == 0 {
+		return tl.start
+	}
+	return tl.entries[len(tl.entries)-1].Time
+}
+
+// StateAt rebuilds the planes in flight, their TCAS state and STCA conflicts and the crashed planes at simulation
+// time t by applying every state change recorded up to t.
+func (tl *Timeline) StateAt(t time.Time) TimelineState {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	flights := append([]TimelineFlight{}, tl.initial...)
+	index := map[string]int{}
+	for i, f := range flights {
+		index[f.Plane] = i
+	}
+
+	state := TimelineState{Time: t}
+	for _, entry := range tl.entries {
+		if entry.Time.After(t) {
+			break
+		}
+		switch entry.Kind {
+		case ReplayFlight:
+			index[entry.Plane] = len(flights)
+			flights = append(flights, TimelineFlight{Plane: entry.Plane, Flight: *entry.Flight})
+		case ReplayLanded:
+			if i, ok := index[entry.Plane]; ok {
+				flights[i].Plane = "" // landed, dropped below
+				delete(index, entry.Plane)
+			}
+		case ReplayTCAS:
+			if i, ok := index[entry.Plane]; ok {
+				flights[i].TCAS = entry.Engagement
+			}
+		case ReplaySTCA:
+			for n, serial := range entry.STCA.Planes {
+				if i, ok := index[serial]; ok {
+					flights[i].STCA = stcaConflictsAfter(flights[i].STCA, entry.STCA.Planes[1-n], entry.STCA.Active())
+				}
+			}
+		case ReplayManeuver:
+			// The profile is copied by insertManeuver, the entries of the timeline are shared by every state built from it
+			if i, ok := index[entry.Plane]; ok {
+				flights[i].Flight.insertManeuver(*entry.Maneuver)
+			}
+		case ReplayManeuverCancelled:
+			if i, ok := index[entry.Plane]; ok {
+				flights[i].Flight.removeManeuver(*entry.Maneuver)
+			}
+		case ReplayCrash:
+			state.CrashedPlanes = []string{entry.Plane, entry.OtherPlane}
+		}
+	}
+
+	for _, f := range flights {
+		if f.Plane != "" {
+			state.Flights = append(state.Flights, f)
+		}
+	}
+	return state
+}
+
+// Markers returns the takeoffs, TCAS warnings, engagements, STCA alerts and crashes of the timeline.
+func (tl *Timeline) Markers() []TimelineMarker {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	markers := []TimelineMarker{}
+	for _, entry := range tl.entries {
+		switch entry.Kind {
+		case ReplayTakeoff, ReplayEngagement, ReplayCrash:
+			markers = append(markers, TimelineMarker{Time: entry.Time, Kind: entry.Kind})
+		case ReplaySTCA:
+			if entry.STCA.Raised.Equal(entry.Time) {
+				markers = append(markers, TimelineMarker{Time: entry.Time, Kind: entry.Kind})
+			}
+		case ReplayTCAS:
+			if entry.Engagement != nil && entry.Engagement.WarningTriggered && !entry.Engagement.Engaged {
+				markers = append(markers, TimelineMarker{Time: entry.Time, Kind: entry.Kind})
+			}
+		}
+	}
+	return markers
+}
+
+// Timeline returns the timeline of the current simulation or replay, nil when none is recorded.
+func (simState *SimulationState) Timeline() *Timeline {
+	simState.Mu.Lock()
+	defer simState.Mu.Unlock()
+	return simState.timeline
+}
@@ -2,7 +2,27 @@ package config
 
 // Config holds the simulation's configuration parameters.
 type Config struct {
-	NoOfAirplanes      int
-	DifferentAltitudes bool
-	FirstRun           bool // must be true only in the first oppening of the application, otherwise trying to open another instance of the fyne application will crash the program
+	NoOfAirplanes          int
+	DifferentAltitudes     bool
+	FaultyTCASRatio        float64            // share of the fleet, between 0 and 1, fitted with a faulty TCAS
+	FleetMix               map[string]float64 // share of the fleet fitted with each equipage, by aviation.TCASCapability name, empty takes FaultyTCASRatio
+	AvoidanceMix           map[string]float64 // share of the fleet flying with each collision avoidance logic, by name, empty takes aviation.DefaultCollisionAvoidance
+	CostTablePath          string             // cost table file of the acasx collision avoidance logic, empty takes aviation.DefaultCostTablePath
+	SimSpeed               float64            // simulated seconds per wall clock second, see aviation.SimSpeedRealTime and aviation.SimSpeedMax
+	CollisionRadius        float64            // horizontal size of the collision volume in map units, 0 takes the default
+	CollisionHeight        float64            // vertical size of the collision volume in feet, 0 takes the default
+	TAZTHR                 float64            // altitude threshold of the TCAS traffic advisories in feet, 0 takes the TCAS II one
+	RAZTHR                 float64            // altitude threshold of the TCAS resolution advisories in feet, 0 takes the TCAS II one
+	PilotResponseDelay     float64            // seconds before the crews start flying an initial RA
+	PilotStrengthenedDelay float64            // seconds before the crews follow a strengthened or reversed RA
+	PilotAcceleration      float64            // g, vertical acceleration of the crews during an RA, 0 takes the default
+	PilotVerticalRate      float64            // ft/min, vertical rate the crews achieve during an RA, 0 takes the default
+	PilotNonCompliance     float64            // chance, between 0 and 1, that a crew ignores an RA
+	PilotOpposite          float64            // chance, between 0 and 1, that a crew manoeuvres against the sense of an RA
+	PilotDelaySpread       float64            // seconds, standard deviation of the response delay between crews, 0 gives every crew the same delays
+	PilotComplianceSpread  float64            // how far, between 0 and 1, the chances of a crew ignoring or opposing an RA may be from the configured ones
+	STCARadarInterval      float64            // seconds between two sweeps of the ground radar, 0 takes the default
+	STCALookAhead          float64            // seconds ahead the ground STCA predicts the tracks, 0 takes the default
+	Seed                   int64              // seed for every random choice of the simulation, 0 picks a new seed for every run
+	FirstRun               bool               // must be true only in the first oppening of the application, otherwise trying to open another instance of the fyne application will crash the program
 }
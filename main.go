@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 
@@ -11,17 +12,144 @@ import (
 )
 
 func main() {
+	seed := flag.Int64("seed", 0, "seed for every random choice of the simulation, the same seed and configuration reproduce the same run (0 picks a new seed for every run)")
+	faultyRatio := flag.Float64("faulty", aviation.DefaultFaultyTCASRatio, "share of the fleet, between 0 and 1, fitted with a faulty TCAS")
+	fleetMix := flag.String("fleet", "", "share of the fleet fitted with each equipage, such as perfect=0.6,mode_s=0.3,none=0.1 (replaces -faulty), "+
+		"equipages are perfect, faulty, none, mode_c, mode_s, tcas_i and ta_only")
+	avoidanceMix := flag.String("avoidance", aviation.DefaultCollisionAvoidance, "collision avoidance logic of the fleet, or the share of the fleet flying with each, such as tcas2=0.5,acasx=0.5")
+	costTable := flag.String("acasx-table", aviation.DefaultCostTablePath, "cost table file of the acasx collision avoidance logic")
+	collisionRadius := flag.Float64("collision-radius", aviation.DefaultCollisionRadius, "horizontal size, in map units, of the collision volume around each plane")
+	collisionHeight := flag.Float64("collision-height", aviation.DefaultCollisionHeight, "vertical size, in feet, of the collision volume around each plane")
+	taZTHR := flag.Float64("ta-zthr", aviation.DefaultTAZTHR, "altitude separation, in feet, below which TCAS traffic advisories ignore the vertical rates at cruising levels (SL7)")
+	raZTHR := flag.Float64("ra-zthr", aviation.DefaultRAZTHR, "altitude separation, in feet, below which TCAS resolution advisories ignore the vertical rates at cruising levels (SL7)")
+
+	// Pilot model of the average crew and how the crews differ from it, times and rates are those of real flight
+	pilotDelay := flag.Float64("pilot-delay", aviation.DefaultPilotModel.ResponseDelay, "seconds before a crew starts flying an initial RA")
+	pilotStrengthenedDelay := flag.Float64("pilot-strengthened-delay", aviation.DefaultPilotModel.StrengthenedDelay, "seconds before a crew follows a strengthened or reversed RA")
+	pilotAcceleration := flag.Float64("pilot-accel", aviation.DefaultPilotModel.VerticalAcceleration, "vertical acceleration, in g, of a crew responding to an RA")
+	pilotRate := flag.Float64("pilot-rate", aviation.DefaultPilotModel.VerticalRate, "vertical rate, in ft/min, a crew achieves during an RA")
+	pilotNonCompliance := flag.Float64("pilot-noncompliance", 0, "chance, between 0 and 1, that a crew ignores an RA")
+	pilotOpposite := flag.Float64("pilot-opposite", 0, "chance, between 0 and 1, that a crew manoeuvres against the sense of an RA")
+	pilotDelaySpread := flag.Float64("pilot-delay-spread", 0, "standard deviation, in seconds, of the response delay of each crew around -pilot-delay")
+	pilotComplianceSpread := flag.Float64("pilot-compliance-spread", 0, "how far, between 0 and 1, the chances of each crew ignoring or opposing an RA may be from -pilot-noncompliance and -pilot-opposite")
+
+	// Short-Term Conflict Alert of the ground system, in real seconds
+	radarInterval := flag.Float64("radar-interval", aviation.DefaultSTCAConfig.RadarInterval, "seconds between two sweeps of the ground radar feeding the STCA")
+	stcaLookAhead := flag.Float64("stca-lookahead", aviation.DefaultSTCAConfig.LookAhead, "seconds ahead the ground STCA predicts the tracks for a loss of separation")
+
+	// Monte Carlo batch mode, runs many headless simulations and exits
+	batchRuns := flag.Int("batch", 0, "run this many headless simulations with consecutive seeds, write their statistics and exit")
+	batchPlanes := flag.Int("planes", 20, "number of planes of each batch run")
+	batchDuration := flag.Int("duration", 30, "duration in minutes of each batch run")
+	batchAltitudes := flag.Bool("altitudes", false, "use varying cruise altitudes in batch runs")
+	batchOut := flag.String("out", "logs/batch", "path prefix of the batch result files")
+	flag.Parse()
+
+	scenario := config.Config{
+		FaultyTCASRatio:        *faultyRatio,
+		CollisionRadius:        *collisionRadius,
+		CollisionHeight:        *collisionHeight,
+		TAZTHR:                 *taZTHR,
+		RAZTHR:                 *raZTHR,
+		PilotResponseDelay:     *pilotDelay,
+		PilotStrengthenedDelay: *pilotStrengthenedDelay,
+		PilotAcceleration:      *pilotAcceleration,
+		PilotVerticalRate:      *pilotRate,
+		PilotNonCompliance:     *pilotNonCompliance,
+		PilotOpposite:          *pilotOpposite,
+		PilotDelaySpread:       *pilotDelaySpread,
+		PilotComplianceSpread:  *pilotComplianceSpread,
+		STCARadarInterval:      *radarInterval,
+		STCALookAhead:          *stcaLookAhead,
+	}
+
+	if *fleetMix != "" {
+		mix, err := aviation.ParseFleetMix(*fleetMix)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		scenario.FleetMix = mix
+	}
+
+	avoidance, err := aviation.ParseAvoidanceMix(*avoidanceMix)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	scenario.AvoidanceMix = avoidance
+	scenario.CostTablePath = *costTable
+	table, err := aviation.NewCostTable(&scenario)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *batchRuns > 0 {
+		scenario.NoOfAirplanes = *batchPlanes
+		scenario.DifferentAltitudes = *batchAltitudes
+		batch := aviation.BatchConfig{
+			Runs:            *batchRuns,
+			DurationMinutes: *batchDuration,
+			BaseSeed:        *seed,
+			Scenario:        scenario,
+			CostTable:       table,
+		}
+		if err := runBatch(batch, *batchOut); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := validateScenario(scenario); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	util.ResetLog()
-	start()
+	scenario.FirstRun = true
+	scenario.SimSpeed = aviation.SimSpeedRealTime
+	scenario.Seed = *seed
+	start(&scenario, table)
 }
 
-// start initializes the TCAS simulator, loads configurations, and enters a continuous command-line interaction loop.
-func start() {
-	scanner := bufio.NewScanner(os.Stdin)
-	initialize := &config.Config{
-		FirstRun: true,
+// validateScenario checks the settings of the command line shared by interactive and batch runs.
+func validateScenario(scenario config.Config) error {
+	if scenario.FaultyTCASRatio < 0 || scenario.FaultyTCASRatio > 1 {
+		return fmt.Errorf("the faulty TCAS ratio must be between 0 and 1, got %v", scenario.FaultyTCASRatio)
+	}
+	if err := aviation.NewFleetMix(&scenario).Validate(); err != nil {
+		return err
+	}
+	if err := aviation.AvoidanceMix(scenario.AvoidanceMix).Validate(); err != nil {
+		return err
 	}
-	simState := &aviation.SimulationState{}
+	if scenario.CollisionRadius <= 0 || scenario.CollisionHeight <= 0 {
+		return fmt.Errorf("the collision volume must have a positive radius and height, got %v units and %v ft",
+			scenario.CollisionRadius, scenario.CollisionHeight)
+	}
+	if scenario.RAZTHR <= 0 || scenario.TAZTHR < scenario.RAZTHR {
+		return fmt.Errorf("the TCAS vertical thresholds must be positive, with the TA threshold at least the RA one, got %v ft and %v ft",
+			scenario.TAZTHR, scenario.RAZTHR)
+	}
+	if scenario.STCARadarInterval <= 0 || scenario.STCALookAhead <= 0 {
+		return fmt.Errorf("the radar interval and STCA look-ahead must be positive, got %v s and %v s",
+			scenario.STCARadarInterval, scenario.STCALookAhead)
+	}
+	if err := aviation.NewSTCAConfig(&scenario).Validate(); err != nil {
+		return err
+	}
+	return aviation.NewPilotPopulation(&scenario).Validate()
+}
+
+// start initializes the TCAS simulator from the configuration given on the command line,
+// and enters a continuous command-line interaction loop.
+// costTable is the cost table of the acasx logic read from the command line, shared by every simulation of the session.
+func start(initialize *config.Config, costTable *aviation.CostTable) {
+	scanner := bufio.NewScanner(os.Stdin)
+	simState := &aviation.SimulationState{CostTable: costTable}
+	gui := &fyneGUI{}
 
 	aviation.GetNumberOfPlanes(initialize)
 
@@ -40,7 +168,7 @@ func start() {
 			continue
 		}
 
-		cmd, ok := getCommand(initialize, simState, argument2)[input[0]]
+		cmd, ok := getCommand(initialize, simState, gui, argument2)[input[0]]
 		if !ok {
 			fmt.Println("Unknown command, type <help> for usage")
 			continue